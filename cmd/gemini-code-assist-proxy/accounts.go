@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/credentials"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/env"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
+)
+
+// accountConfig is a configured Code Assist account.
+type accountConfig struct {
+	name     string
+	provider *credentials.FileProvider
+	// envProjectID is the CLOUDCODE_GCP_PROJECT_ID override; it only applies to a single account
+	envProjectID string
+}
+
+// configuredAccounts returns the accounts from CLOUDCODE_OAUTH_CREDS_PATHS (one credentials file
+// per account) or the single default account.
+func configuredAccounts() ([]accountConfig, error) {
+	if paths, ok := env.Get("CLOUDCODE_OAUTH_CREDS_PATHS"); ok && strings.TrimSpace(paths) != "" {
+		var configured []accountConfig
+		for _, path := range strings.Split(paths, ",") {
+			path = strings.TrimSpace(path)
+			if path == "" {
+				continue
+			}
+			configured = append(configured, accountConfig{
+				name:     strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+				provider: credentials.NewFileProviderWithPath(path),
+			})
+		}
+		return configured, nil
+	}
+
+	provider, err := credentials.NewFileProvider()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create credentials provider: %w", err)
+	}
+	envProjectID, _ := env.Get("CLOUDCODE_GCP_PROJECT_ID")
+	return []accountConfig{{name: "default", provider: provider, envProjectID: envProjectID}}, nil
+}
+
+// accountInfo is what the startup auth check learned about an account.
+type accountInfo struct {
+	creds      *credentials.OAuthCredentials
+	credsErr   error
+	loadAssist *gemini.LoadCodeAssistResponse
+	loadErr    error
+	tier       string
+}
+
+// checkAccount loads the credentials of an account and calls LoadCodeAssist with them.
+func checkAccount(acct accountConfig) accountInfo {
+	var info accountInfo
+	info.creds, info.credsErr = acct.provider.GetCredentials()
+	if info.credsErr != nil {
+		info.loadErr = info.credsErr
+		return info
+	}
+
+	info.loadAssist, info.loadErr = gemini.NewClient(acct.provider).LoadCodeAssist()
+	if info.loadErr == nil {
+		info.tier = fmt.Sprintf("%s (%s)", info.loadAssist.CurrentTier.Name, info.loadAssist.CurrentTier.ID)
+		// LoadCodeAssist may have refreshed the token
+		if creds, err := acct.provider.GetCredentials(); err == nil {
+			info.creds = creds
+		}
+	}
+	return info
+}
+
+// expiry returns the expiry time of creds, or the zero time if unknown.
+func expiry(creds *credentials.OAuthCredentials) time.Time {
+	if creds == nil || creds.ExpiryDate <= 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(creds.ExpiryDate)
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
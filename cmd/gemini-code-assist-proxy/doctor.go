@@ -0,0 +1,154 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/apikeys"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/env"
+)
+
+const (
+	checkOK   = "ok"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+type doctorCheck struct {
+	Name    string `json:"name"`
+	Account string `json:"account,omitempty"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// runDoctor implements `doctor`: it checks the configuration for problems that would make
+// the proxy fail at startup or on the first request.
+func runDoctor(args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	jsonOut := fs.Bool("json", false, "print JSON")
+	port := fs.String("port", env.GetOrDefault("PORT", "9877"), "port the proxy will listen on (PORT)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s doctor [flags]\n\nCheck the configuration for common problems.\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var checks []doctorCheck
+	add := func(name, account, status, format string, a ...interface{}) {
+		checks = append(checks, doctorCheck{Name: name, Account: account, Status: status, Message: fmt.Sprintf(format, a...)})
+	}
+
+	configured, err := configuredAccounts()
+	if err != nil {
+		add("credentials", "", checkFail, "%v", err)
+	}
+	for _, acct := range configured {
+		info := checkAccount(acct)
+
+		// Credentials file and its permissions
+		switch {
+		case info.credsErr != nil:
+			add("credentials", acct.name, checkFail, "%v (run '%s login')", info.credsErr, os.Args[0])
+		case acct.provider.Path() == "":
+			add("credentials", acct.name, checkOK, "read from CLOUDCODE_OAUTH_CREDS")
+		default:
+			path := acct.provider.Path()
+			if fi, err := os.Stat(path); err != nil {
+				add("credentials", acct.name, checkFail, "%v", err)
+			} else if perm := fi.Mode().Perm(); perm&0o077 != 0 {
+				add("credentials", acct.name, checkWarn, "%s is readable by other users (mode %04o), run 'chmod 600 %s'", path, perm, path)
+			} else {
+				add("credentials", acct.name, checkOK, "%s (mode %04o)", path, perm)
+			}
+		}
+		if info.credsErr != nil {
+			continue
+		}
+
+		// Token validity
+		exp := expiry(info.creds)
+		switch {
+		case info.creds.RefreshToken == "":
+			add("token", acct.name, checkFail, "no refresh token; the access token cannot be renewed (run '%s login')", os.Args[0])
+		case exp.IsZero():
+			add("token", acct.name, checkWarn, "no expiry date; it is refreshed when the upstream rejects it")
+		case time.Now().After(exp):
+			add("token", acct.name, checkWarn, "expired at %s, it is refreshed on startup", exp.Local().Format(time.RFC3339))
+		default:
+			add("token", acct.name, checkOK, "valid for %s", time.Until(exp).Round(time.Second))
+		}
+
+		// Code Assist access and project
+		if info.loadErr != nil {
+			add("code assist", acct.name, checkFail, "%v", info.loadErr)
+			continue
+		}
+		add("code assist", acct.name, checkOK, "tier %s", info.tier)
+
+		switch {
+		case acct.envProjectID != "":
+			add("project", acct.name, checkOK, "%s (CLOUDCODE_GCP_PROJECT_ID)", acct.envProjectID)
+		case info.loadAssist.CloudAICompanionProject != "":
+			add("project", acct.name, checkOK, "%s", info.loadAssist.CloudAICompanionProject)
+		default:
+			add("project", acct.name, checkWarn, "no project yet; onboarding runs when the proxy starts")
+		}
+	}
+
+	// Port availability
+	if ln, err := net.Listen("tcp", ":"+*port); err != nil {
+		add("port", "", checkFail, "port %s is not available: %v", *port, err)
+	} else {
+		ln.Close()
+		add("port", "", checkOK, "port %s is available", *port)
+	}
+
+	// Admin key and client API keys
+	apiKeyCount := 0
+	if store, err := apikeys.NewDefaultStore(); err != nil {
+		add("api keys", "", checkWarn, "%v", err)
+	} else if keys, err := store.List(); err != nil {
+		add("api keys", "", checkFail, "%v", err)
+	} else {
+		apiKeyCount = len(keys)
+		add("api keys", "", checkOK, "%d API key(s) in %s", apiKeyCount, store.Name())
+	}
+	if _, ok := env.Get("ADMIN_API_KEY"); ok {
+		add("admin key", "", checkOK, "ADMIN_API_KEY is set")
+	} else if apiKeyCount > 0 {
+		add("admin key", "", checkWarn, "ADMIN_API_KEY is not set; only API keys with the admin scope can manage the proxy")
+	} else {
+		add("admin key", "", checkFail, "ADMIN_API_KEY is not set and no API keys exist; all authenticated endpoints reject requests")
+	}
+
+	failed := false
+	for _, c := range checks {
+		if c.Status == checkFail {
+			failed = true
+		}
+	}
+
+	if *jsonOut {
+		if err := printJSON(map[string]interface{}{"ok": !failed, "checks": checks}); err != nil {
+			return err
+		}
+	} else {
+		for _, c := range checks {
+			name := c.Name
+			if c.Account != "" && len(configured) > 1 {
+				name += " (" + c.Account + ")"
+			}
+			fmt.Printf("[%-4s] %-28s %s\n", c.Status, name, c.Message)
+		}
+	}
+
+	if failed {
+		return silentError{fmt.Errorf("doctor found problems")}
+	}
+	return nil
+}
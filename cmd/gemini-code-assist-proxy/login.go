@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"time"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/credentials"
+)
+
+// runLogin implements `login`: it obtains OAuth credentials for Code Assist and saves them
+// where the proxy reads them from.
+func runLogin(args []string) error {
+	fs := flag.NewFlagSet("login", flag.ContinueOnError)
+	noBrowser := fs.Bool("no-browser", false, "print the login URL and paste the redirect URL back instead of using a local listener (e.g. over SSH)")
+	credsPath := fs.String("creds", "", "file to write the credentials to (default: CLOUDCODE_OAUTH_CREDS_PATH or ~/.gemini/oauth_creds.json)")
+	timeout := fs.Duration("timeout", 5*time.Minute, "how long to wait for the login to complete")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s login [flags]\n\nSign in with Google and save OAuth credentials for the proxy.\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var provider *credentials.FileProvider
+	if *credsPath != "" {
+		provider = credentials.NewFileProviderWithPath(*credsPath)
+	} else {
+		p, err := credentials.NewFileProvider()
+		if err != nil {
+			return err
+		}
+		provider = p
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	ctx, cancelTimeout := context.WithTimeout(ctx, *timeout)
+	defer cancelTimeout()
+
+	cfg := credentials.DefaultLoginConfig()
+	var creds *credentials.OAuthCredentials
+	var err error
+	if *noBrowser {
+		creds, err = credentials.LoginManual(ctx, cfg, os.Stdout, os.Stdin)
+	} else {
+		creds, err = credentials.LoginLoopback(ctx, cfg, func(authURL string) {
+			fmt.Printf("Opening the browser to sign in. If it does not open, visit:\n\n  %s\n\n", authURL)
+			if err := openBrowser(authURL); err != nil {
+				fmt.Println("Could not open a browser; use `login --no-browser` on machines without one.")
+			}
+			fmt.Println("Waiting for the login to complete...")
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+
+	if err := provider.SaveCredentials(creds); err != nil {
+		return err
+	}
+	fmt.Printf("Logged in. Credentials saved with %s\n", provider.Name())
+	return nil
+}
+
+func openBrowser(u string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", u)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", u)
+	default:
+		cmd = exec.Command("xdg-open", u)
+	}
+	return cmd.Start()
+}
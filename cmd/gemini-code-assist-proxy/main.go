@@ -1,52 +1,70 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
-
-	"github.com/dvcrn/gemini-code-assist-proxy/internal/credentials"
-	"github.com/dvcrn/gemini-code-assist-proxy/internal/env"
-	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
-	"github.com/dvcrn/gemini-code-assist-proxy/internal/logger"
-	"github.com/dvcrn/gemini-code-assist-proxy/internal/project"
-	"github.com/dvcrn/gemini-code-assist-proxy/internal/server"
+	"os"
+	"strings"
 )
 
+// command is a subcommand of the CLI.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands = []command{
+	{"serve", "Start the proxy server (default)", runServe},
+	{"login", "Sign in with Google and save OAuth credentials", runLogin},
+	{"status", "Show credential expiry, tier and project of each account", runStatus},
+	{"models", "List the models served by the proxy", runModels},
+	{"refresh", "Refresh the OAuth access token of each account", runRefresh},
+	{"doctor", "Check the configuration for common problems", runDoctor},
+}
+
 func main() {
-	port := env.GetOrDefault("PORT", "9877")
+	args := os.Args[1:]
 
-	// Create file provider
-	provider, err := credentials.NewFileProvider()
-	if err != nil {
-		logger.Get().Fatal().Err(err).Msg("Failed to create credentials provider")
+	// Without a subcommand (or with flags only) the proxy starts, as before subcommands existed
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
 	}
 
-	// Perform startup auth check
-	logger.Get().Info().Msg("Performing startup authentication check...")
-	geminiClient := gemini.NewClient(provider)
-	loadAssistResponse, err := geminiClient.LoadCodeAssist()
-	if err != nil {
-		logger.Get().Warn().Err(err).Msg("Startup authentication check failed.")
-	} else {
-		tier := fmt.Sprintf("%s (%s)", loadAssistResponse.CurrentTier.Name, loadAssistResponse.CurrentTier.ID)
-		logger.Get().Info().
-			Str("tier", tier).
-			Str("project_id", loadAssistResponse.CloudAICompanionProject).
-			Bool("gcp_managed", loadAssistResponse.GCPManaged).
-			Msg("Startup authentication check successful.")
+	if name == "help" {
+		usage()
+		return
 	}
 
-	// Discover project ID
-	envProjectID, _ := env.Get("CLOUDCODE_GCP_PROJECT_ID")
-	projectID, err := project.Discover(provider, envProjectID, loadAssistResponse)
-	if err != nil {
-		logger.Get().Fatal().Err(err).Msg("Failed to discover project ID")
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		if err := cmd.run(args); err != nil && !errors.Is(err, flag.ErrHelp) {
+			var silent silentError
+			if !errors.As(err, &silent) {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+			}
+			os.Exit(1)
+		}
+		return
 	}
 
-	// Create server with provider and project ID
-	srv := server.NewServer(provider, projectID)
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+	usage()
+	os.Exit(2)
+}
 
-	// Start server
-	if err := srv.Start(":" + port); err != nil {
-		logger.Get().Fatal().Err(err).Msg("Failed to start server")
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", cmd.name, cmd.summary)
 	}
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for the flags of a command.\n", os.Args[0])
 }
+
+// silentError makes the command exit with status 1 without printing an error,
+// e.g. when its output already describes the failure.
+type silentError struct{ error }
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/server"
+)
+
+// runModels implements `models`: the models served by the proxy.
+func runModels(args []string) error {
+	fs := flag.NewFlagSet("models", flag.ContinueOnError)
+	jsonOut := fs.Bool("json", false, "print JSON (the /v1/models response)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s models [flags]\n\nList the models served by the proxy.\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	models := server.SupportedModels()
+	if *jsonOut {
+		return printJSON(server.ModelsListResponse{Object: "list", Data: models})
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tCONTEXT\tOUTPUT")
+	for _, m := range models {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", m.ID, m.Name, m.Capabilities.Limits.MaxContextWindowTokens, m.Capabilities.Limits.MaxOutputTokens)
+	}
+	return w.Flush()
+}
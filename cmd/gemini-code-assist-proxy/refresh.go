@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+type refreshResult struct {
+	Name      string     `json:"name"`
+	Provider  string     `json:"provider"`
+	Refreshed bool       `json:"refreshed"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// runRefresh implements `refresh`: it refreshes the access token of every account and saves it.
+func runRefresh(args []string) error {
+	fs := flag.NewFlagSet("refresh", flag.ContinueOnError)
+	jsonOut := fs.Bool("json", false, "print JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s refresh [flags]\n\nRefresh the OAuth access token of each account.\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	configured, err := configuredAccounts()
+	if err != nil {
+		return err
+	}
+
+	var results []refreshResult
+	failed := false
+	for _, acct := range configured {
+		res := refreshResult{Name: acct.name, Provider: acct.provider.Name()}
+		if err := acct.provider.RefreshToken(); err != nil {
+			res.Error = err.Error()
+			failed = true
+		} else {
+			res.Refreshed = true
+			if creds, err := acct.provider.GetCredentials(); err == nil {
+				if exp := expiry(creds); !exp.IsZero() {
+					res.ExpiresAt = &exp
+				}
+			}
+		}
+		results = append(results, res)
+	}
+
+	if *jsonOut {
+		if err := printJSON(map[string]interface{}{"accounts": results}); err != nil {
+			return err
+		}
+	} else {
+		for _, res := range results {
+			switch {
+			case res.Error != "":
+				fmt.Printf("%s: refresh failed: %s\n", res.Name, res.Error)
+			case res.ExpiresAt != nil:
+				fmt.Printf("%s: refreshed, valid until %s\n", res.Name, res.ExpiresAt.Local().Format(time.RFC3339))
+			default:
+				fmt.Printf("%s: refreshed\n", res.Name)
+			}
+		}
+	}
+
+	if failed {
+		return silentError{fmt.Errorf("refresh failed")}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/accounts"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/env"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/logger"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/project"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/server"
+)
+
+// runServe implements `serve`: it discovers the project of every account and starts the proxy.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	port := fs.String("port", env.GetOrDefault("PORT", "9877"), "port to listen on (PORT)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s serve [flags]\n\nStart the proxy server.\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	configured, err := configuredAccounts()
+	if err != nil {
+		return err
+	}
+
+	var pool []*accounts.Account
+	for _, acct := range configured {
+		info := checkAccount(acct)
+		if info.loadErr != nil {
+			logger.Get().Warn().Err(info.loadErr).Str("account", acct.name).Msg("Startup authentication check failed.")
+		} else {
+			logger.Get().Info().
+				Str("account", acct.name).
+				Str("tier", info.tier).
+				Str("project_id", info.loadAssist.CloudAICompanionProject).
+				Bool("gcp_managed", info.loadAssist.GCPManaged).
+				Msg("Startup authentication check successful.")
+		}
+
+		// Discover project ID (env override, LoadCodeAssist response, or onboarding flow)
+		projectID, err := project.Discover(acct.provider, acct.envProjectID, info.loadAssist)
+		if err != nil {
+			if len(configured) == 1 {
+				return fmt.Errorf("failed to discover project ID: %w", err)
+			}
+			logger.Get().Warn().Err(err).Str("account", acct.name).Msg("Skipping account")
+			continue
+		}
+		pool = append(pool, accounts.NewAccount(acct.name, acct.provider, projectID))
+	}
+	if len(pool) == 0 {
+		return fmt.Errorf("no usable account in CLOUDCODE_OAUTH_CREDS_PATHS")
+	}
+	if len(pool) > 1 {
+		logger.Get().Info().Int("accounts", len(pool)).Msg("Using account pool")
+	}
+
+	srv := server.NewServerWithAccounts(pool)
+	return srv.Start(":" + *port)
+}
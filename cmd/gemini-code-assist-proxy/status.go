@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+type accountStatus struct {
+	Name            string     `json:"name"`
+	Provider        string     `json:"provider"`
+	HasCredentials  bool       `json:"has_credentials"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+	Expired         bool       `json:"expired"`
+	HasRefreshToken bool       `json:"has_refresh_token"`
+	Tier            string     `json:"tier,omitempty"`
+	ProjectID       string     `json:"project_id,omitempty"`
+	GCPManaged      bool       `json:"gcp_managed"`
+	Error           string     `json:"error,omitempty"`
+}
+
+// runStatus implements `status`: credential expiry, tier and project of each account.
+// Unlike serve it never onboards; a missing project is reported as such.
+func runStatus(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	jsonOut := fs.Bool("json", false, "print JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s status [flags]\n\nShow credential expiry, tier and project of each account.\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	configured, err := configuredAccounts()
+	if err != nil {
+		return err
+	}
+
+	var statuses []accountStatus
+	failed := false
+	for _, acct := range configured {
+		info := checkAccount(acct)
+		st := accountStatus{
+			Name:           acct.name,
+			Provider:       acct.provider.Name(),
+			HasCredentials: info.creds != nil,
+		}
+		if info.creds != nil {
+			st.HasRefreshToken = info.creds.RefreshToken != ""
+			if exp := expiry(info.creds); !exp.IsZero() {
+				st.ExpiresAt = &exp
+				st.Expired = time.Now().After(exp)
+			}
+		}
+		if info.loadErr != nil {
+			st.Error = info.loadErr.Error()
+			failed = true
+		} else {
+			st.Tier = info.tier
+			st.GCPManaged = info.loadAssist.GCPManaged
+			st.ProjectID = info.loadAssist.CloudAICompanionProject
+		}
+		if acct.envProjectID != "" {
+			st.ProjectID = acct.envProjectID
+		}
+		statuses = append(statuses, st)
+	}
+
+	if *jsonOut {
+		if err := printJSON(map[string]interface{}{"accounts": statuses}); err != nil {
+			return err
+		}
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for i, st := range statuses {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "Account:\t%s\n", st.Name)
+			fmt.Fprintf(w, "Provider:\t%s\n", st.Provider)
+			switch {
+			case !st.HasCredentials:
+				fmt.Fprintf(w, "Token:\tmissing\n")
+			case st.ExpiresAt == nil:
+				fmt.Fprintf(w, "Token:\tno expiry date\n")
+			case st.Expired:
+				fmt.Fprintf(w, "Token:\texpired at %s\n", st.ExpiresAt.Local().Format(time.RFC3339))
+			default:
+				fmt.Fprintf(w, "Token:\tvalid until %s (%s)\n", st.ExpiresAt.Local().Format(time.RFC3339), time.Until(*st.ExpiresAt).Round(time.Second))
+			}
+			if st.HasCredentials {
+				fmt.Fprintf(w, "Refresh token:\t%s\n", yesNo(st.HasRefreshToken))
+			}
+			if st.Error != "" {
+				fmt.Fprintf(w, "Error:\t%s\n", st.Error)
+				continue
+			}
+			fmt.Fprintf(w, "Tier:\t%s\n", st.Tier)
+			project := st.ProjectID
+			if project == "" {
+				project = "(none, onboarding runs on serve)"
+			}
+			fmt.Fprintf(w, "Project:\t%s\n", project)
+		}
+		w.Flush()
+	}
+
+	if failed {
+		return silentError{fmt.Errorf("status check failed")}
+	}
+	return nil
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
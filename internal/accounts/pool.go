@@ -0,0 +1,294 @@
+// Package accounts schedules upstream requests across a pool of Code Assist accounts.
+//
+// Each account is an OAuth identity with its own project. Requests go to the next
+// available account according to the pool's strategy; an account that answers with
+// 429 RESOURCE_EXHAUSTED is put on cooldown (for the delay the upstream asked for,
+// or the pool's default) and the request is retried on another account. A pool with a
+// single account never cools it down and passes the upstream error on instead.
+package accounts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/credentials"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/logger"
+)
+
+// Strategy selects the next account for a request.
+type Strategy string
+
+const (
+	// StrategyRoundRobin cycles through the accounts in order
+	StrategyRoundRobin Strategy = "round_robin"
+	// StrategyLeastUsed prefers the account that served the fewest requests
+	StrategyLeastUsed Strategy = "least_used"
+)
+
+// Account is a single OAuth identity with the project it sends requests for.
+type Account struct {
+	Name      string
+	Provider  credentials.CredentialsProvider
+	ProjectID string
+	Client    *gemini.Client
+
+	// Scheduling state, guarded by the pool's mutex
+	requests      int
+	inFlight      int
+	quotaHits     int
+	failures      int
+	cooldownUntil time.Time
+	lastError     string
+	lastUsed      time.Time
+}
+
+// NewAccount creates an account that sends requests for projectID with the given credentials.
+func NewAccount(name string, provider credentials.CredentialsProvider, projectID string) *Account {
+	if provider != nil {
+		provider = &observedProvider{CredentialsProvider: provider, account: name}
+	}
+	return &Account{
+		Name:      name,
+		Provider:  provider,
+		ProjectID: projectID,
+		Client:    gemini.NewClient(provider),
+	}
+}
+
+// Status is a snapshot of an account's health for the admin API.
+type Status struct {
+	Name          string     `json:"name"`
+	Provider      string     `json:"provider"`
+	ProjectID     string     `json:"project_id"`
+	Healthy       bool       `json:"healthy"`
+	Requests      int        `json:"requests"`
+	InFlight      int        `json:"in_flight"`
+	QuotaHits     int        `json:"quota_hits"`
+	Failures      int        `json:"failures"`
+	CooldownUntil *time.Time `json:"cooldown_until,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastUsed      *time.Time `json:"last_used,omitempty"`
+}
+
+// UnavailableError is returned when every account is cooling down.
+type UnavailableError struct {
+	RetryAfter time.Duration
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("all accounts are rate limited, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// Pool schedules requests across accounts.
+type Pool struct {
+	mu              sync.Mutex
+	accounts        []*Account
+	strategy        Strategy
+	defaultCooldown time.Duration
+	next            int // round robin cursor
+
+	now func() time.Time
+}
+
+// NewPool creates a pool over accounts. defaultCooldown is used for 429s without a retry hint.
+func NewPool(accounts []*Account, strategy Strategy, defaultCooldown time.Duration) *Pool {
+	if strategy != StrategyLeastUsed {
+		strategy = StrategyRoundRobin
+	}
+	return &Pool{
+		accounts:        accounts,
+		strategy:        strategy,
+		defaultCooldown: defaultCooldown,
+		now:             time.Now,
+	}
+}
+
+// Accounts returns the pool members in configuration order.
+func (p *Pool) Accounts() []*Account {
+	return p.accounts
+}
+
+// Primary returns the first account, which receives credentials set through the admin API.
+func (p *Pool) Primary() *Account {
+	if len(p.accounts) == 0 {
+		return nil
+	}
+	return p.accounts[0]
+}
+
+// Do runs fn with the next available account. When fn fails with a quota error the account
+// is put on cooldown and fn is retried with another account, until all accounts were tried.
+// fn must not have written anything to the client when it returns a quota error. The context
+// passed to fn is marked with gemini.WithQuotaFailover while another account is available,
+// so that the client leaves quota errors to the pool instead of retrying them.
+func (p *Pool) Do(ctx context.Context, fn func(context.Context, *Account) error) error {
+	tried := make(map[*Account]bool, len(p.accounts))
+	var lastErr error
+	for {
+		account, err := p.acquire(tried)
+		if err != nil {
+			if lastErr != nil {
+				return lastErr
+			}
+			return err
+		}
+		tried[account] = true
+
+		callCtx := ctx
+		if p.canFailOver(tried) {
+			callCtx = gemini.WithQuotaFailover(ctx)
+		}
+		err = fn(callCtx, account)
+		p.release(account, err)
+		if err == nil || !isQuotaError(err) {
+			return err
+		}
+		lastErr = err
+		logger.Get().Warn().
+			Str("account", account.Name).
+			Msg("Account quota exhausted, failing over to the next account")
+	}
+}
+
+// Status returns the health of all accounts.
+func (p *Pool) Status() []Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	statuses := make([]Status, 0, len(p.accounts))
+	for _, a := range p.accounts {
+		st := Status{
+			Name:      a.Name,
+			ProjectID: a.ProjectID,
+			Healthy:   !now.Before(a.cooldownUntil),
+			Requests:  a.requests,
+			InFlight:  a.inFlight,
+			QuotaHits: a.quotaHits,
+			Failures:  a.failures,
+			LastError: a.lastError,
+		}
+		if a.Provider != nil {
+			st.Provider = a.Provider.Name()
+		}
+		if !st.Healthy {
+			until := a.cooldownUntil
+			st.CooldownUntil = &until
+		}
+		if !a.lastUsed.IsZero() {
+			used := a.lastUsed
+			st.LastUsed = &used
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses
+}
+
+// acquire picks the next account that is not cooling down and has not been tried yet.
+func (p *Pool) acquire(tried map[*Account]bool) (*Account, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.accounts) == 0 {
+		return nil, errors.New("no accounts configured")
+	}
+
+	now := p.now()
+	var chosen *Account
+	var soonest time.Time
+	for i := range p.accounts {
+		idx := i
+		if p.strategy == StrategyRoundRobin {
+			idx = (p.next + i) % len(p.accounts)
+		}
+		a := p.accounts[idx]
+		if tried[a] {
+			continue
+		}
+		if now.Before(a.cooldownUntil) {
+			if soonest.IsZero() || a.cooldownUntil.Before(soonest) {
+				soonest = a.cooldownUntil
+			}
+			continue
+		}
+		if p.strategy == StrategyRoundRobin {
+			chosen = a
+			p.next = (idx + 1) % len(p.accounts)
+			break
+		}
+		if chosen == nil || a.inFlight < chosen.inFlight ||
+			(a.inFlight == chosen.inFlight && a.requests < chosen.requests) {
+			chosen = a
+		}
+	}
+	if chosen == nil {
+		if soonest.IsZero() {
+			return nil, errors.New("no account available")
+		}
+		return nil, &UnavailableError{RetryAfter: soonest.Sub(now)}
+	}
+
+	chosen.requests++
+	chosen.inFlight++
+	chosen.lastUsed = now
+	return chosen, nil
+}
+
+// canFailOver reports whether an account that was not tried yet is available.
+func (p *Pool) canFailOver(tried map[*Account]bool) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	for _, a := range p.accounts {
+		if !tried[a] && !now.Before(a.cooldownUntil) {
+			return true
+		}
+	}
+	return false
+}
+
+// release records the outcome of a request on account.
+func (p *Pool) release(account *Account, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	account.inFlight--
+	if err == nil {
+		return
+	}
+	account.lastError = err.Error()
+	if !isQuotaError(err) {
+		account.failures++
+		return
+	}
+
+	account.quotaHits++
+	if len(p.accounts) == 1 {
+		// Nothing to fail over to: the upstream error is passed on and the next request still
+		// reaches Code Assist, instead of failing locally for the whole cooldown
+		return
+	}
+
+	cooldown := p.defaultCooldown
+	var apiErr *gemini.APIError
+	if errors.As(err, &apiErr) {
+		if delay, ok := apiErr.RetryDelay(); ok {
+			cooldown = delay
+		}
+	}
+	account.cooldownUntil = p.now().Add(cooldown)
+	logger.Get().Info().
+		Str("account", account.Name).
+		Dur("cooldown", cooldown).
+		Msg("Account put on cooldown")
+}
+
+func isQuotaError(err error) bool {
+	var apiErr *gemini.APIError
+	return errors.As(err, &apiErr) && apiErr.IsQuotaExhausted()
+}
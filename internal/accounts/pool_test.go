@@ -0,0 +1,229 @@
+package accounts
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/credentials"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPool(strategy Strategy, names ...string) (*Pool, *time.Time) {
+	var accts []*Account
+	for _, n := range names {
+		accts = append(accts, &Account{Name: n})
+	}
+	p := NewPool(accts, strategy, time.Minute)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	p.now = func() time.Time { return now }
+	return p, &now
+}
+
+func quotaError(retryDelay string) error {
+	body := `{"error":{"code":429,"message":"Resource has been exhausted","status":"RESOURCE_EXHAUSTED"}}`
+	if retryDelay != "" {
+		body = `{"error":{"code":429,"status":"RESOURCE_EXHAUSTED","details":[{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"` + retryDelay + `"}]}}`
+	}
+	return &gemini.APIError{Method: "generateContent", StatusCode: 429, Body: []byte(body)}
+}
+
+// serve runs one request and returns the account that served it.
+func serve(t *testing.T, p *Pool) string {
+	t.Helper()
+	var name string
+	require.NoError(t, p.Do(context.Background(), func(_ context.Context, a *Account) error {
+		name = a.Name
+		return nil
+	}))
+	return name
+}
+
+func TestPool_RoundRobin(t *testing.T) {
+	p, _ := testPool(StrategyRoundRobin, "a", "b", "c")
+	var got []string
+	for i := 0; i < 4; i++ {
+		got = append(got, serve(t, p))
+	}
+	assert.Equal(t, []string{"a", "b", "c", "a"}, got)
+}
+
+func TestPool_LeastUsed(t *testing.T) {
+	p, _ := testPool(StrategyLeastUsed, "a", "b")
+	p.accounts[0].requests = 5
+
+	assert.Equal(t, "b", serve(t, p))
+	assert.Equal(t, "b", serve(t, p))
+	assert.Equal(t, 2, p.accounts[1].requests)
+}
+
+func TestPool_FailoverOnQuotaError(t *testing.T) {
+	p, now := testPool(StrategyRoundRobin, "a", "b")
+
+	var tried []string
+	err := p.Do(context.Background(), func(_ context.Context, a *Account) error {
+		tried = append(tried, a.Name)
+		if a.Name == "a" {
+			return quotaError("30s")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, tried)
+
+	// a rests for the delay from RetryInfo
+	status := p.Status()
+	assert.False(t, status[0].Healthy)
+	require.NotNil(t, status[0].CooldownUntil)
+	assert.Equal(t, now.Add(30*time.Second), *status[0].CooldownUntil)
+	assert.Equal(t, 1, status[0].QuotaHits)
+	assert.True(t, status[1].Healthy)
+
+	// While a cools down, b serves everything
+	assert.Equal(t, "b", serve(t, p))
+	assert.Equal(t, "b", serve(t, p))
+
+	*now = now.Add(31 * time.Second)
+	assert.True(t, p.Status()[0].Healthy)
+	assert.Equal(t, "a", serve(t, p))
+}
+
+func TestPool_SingleAccountIsNotCooledDown(t *testing.T) {
+	p, _ := testPool(StrategyRoundRobin, "a")
+
+	err := p.Do(context.Background(), func(_ context.Context, a *Account) error { return quotaError("") })
+	var apiErr *gemini.APIError
+	require.ErrorAs(t, err, &apiErr, "the upstream error is passed on")
+	assert.Equal(t, 429, apiErr.StatusCode)
+
+	status := p.Status()[0]
+	assert.True(t, status.Healthy)
+	assert.Nil(t, status.CooldownUntil)
+	assert.Equal(t, 1, status.QuotaHits)
+
+	// The next request still goes upstream
+	assert.Equal(t, "a", serve(t, p))
+}
+
+func TestPool_AllAccountsExhausted(t *testing.T) {
+	p, _ := testPool(StrategyRoundRobin, "a", "b")
+
+	calls := 0
+	err := p.Do(context.Background(), func(_ context.Context, a *Account) error {
+		calls++
+		return quotaError("")
+	})
+	assert.Equal(t, 2, calls)
+	var apiErr *gemini.APIError
+	require.ErrorAs(t, err, &apiErr, "the last upstream error is returned")
+	assert.Equal(t, 429, apiErr.StatusCode)
+
+	// Both accounts now rest for the default cooldown
+	err = p.Do(context.Background(), func(_ context.Context, a *Account) error {
+		t.Fatal("no account should be available")
+		return nil
+	})
+	var unavailable *UnavailableError
+	require.ErrorAs(t, err, &unavailable)
+	assert.Equal(t, time.Minute, unavailable.RetryAfter)
+}
+
+func TestPool_OtherErrorsDoNotFailOver(t *testing.T) {
+	p, _ := testPool(StrategyRoundRobin, "a", "b")
+
+	calls := 0
+	err := p.Do(context.Background(), func(_ context.Context, a *Account) error {
+		calls++
+		return errors.New("boom")
+	})
+	assert.EqualError(t, err, "boom")
+	assert.Equal(t, 1, calls)
+
+	status := p.Status()
+	assert.True(t, status[0].Healthy)
+	assert.Equal(t, 1, status[0].Failures)
+	assert.Equal(t, "boom", status[0].LastError)
+	assert.Equal(t, 0, status[0].InFlight)
+}
+
+type staticProvider struct{}
+
+func (staticProvider) GetCredentials() (*credentials.OAuthCredentials, error) {
+	return &credentials.OAuthCredentials{AccessToken: "token"}, nil
+}
+
+func (staticProvider) SaveCredentials(*credentials.OAuthCredentials) error { return nil }
+
+func (staticProvider) RefreshToken() error { return nil }
+
+func (staticProvider) Name() string { return "static" }
+
+// upstream answers every request with the same status and counts the requests.
+type upstream struct {
+	status int
+	body   string
+	calls  int
+}
+
+func (u *upstream) Do(req *http.Request) (*http.Response, error) {
+	u.calls++
+	return &http.Response{StatusCode: u.status, Body: io.NopCloser(strings.NewReader(u.body))}, nil
+}
+
+func TestPool_QuotaErrorsFailOverWithoutClientRetries(t *testing.T) {
+	// The client alone would wait out the short retry delay on the same account
+	exhausted := &upstream{
+		status: http.StatusTooManyRequests,
+		body:   `{"error":{"code":429,"status":"RESOURCE_EXHAUSTED","details":[{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"2s"}]}}`,
+	}
+	healthy := &upstream{status: http.StatusOK, body: `{"response":{"candidates":[]}}`}
+
+	newAccount := func(name string, u *upstream) *Account {
+		account := NewAccount(name, staticProvider{}, "project-"+name)
+		account.Client = gemini.NewClientWithHTTPClient(staticProvider{}, u)
+		return account
+	}
+	// Round robin serves from the exhausted account first
+	accts := []*Account{newAccount("a", exhausted), newAccount("b", healthy)}
+	p := NewPool(accts, StrategyRoundRobin, time.Minute)
+
+	start := time.Now()
+	var served string
+	err := p.Do(context.Background(), func(ctx context.Context, a *Account) error {
+		served = a.Name
+		_, err := a.Client.GenerateContent(ctx, &gemini.GenerateContentRequest{Model: "gemini-2.5-pro", Project: a.ProjectID})
+		return err
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "b", served)
+	assert.Equal(t, 1, exhausted.calls, "the quota error must not be retried on the same account")
+	assert.Equal(t, 1, healthy.calls)
+	assert.Less(t, time.Since(start), time.Second, "failover must not wait for a backoff")
+}
+
+func TestPool_QuotaErrorsAreRetriedWithoutAnotherHealthyAccount(t *testing.T) {
+	exhausted := &upstream{
+		status: http.StatusTooManyRequests,
+		body:   `{"error":{"code":429,"status":"RESOURCE_EXHAUSTED","details":[{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"0.01s"}]}}`,
+	}
+	a := NewAccount("a", staticProvider{}, "project-a")
+	a.Client = gemini.NewClientWithHTTPClient(staticProvider{}, exhausted)
+	b := NewAccount("b", staticProvider{}, "project-b")
+	b.cooldownUntil = time.Now().Add(time.Minute)
+	p := NewPool([]*Account{a, b}, StrategyRoundRobin, time.Minute)
+
+	err := p.Do(context.Background(), func(ctx context.Context, a *Account) error {
+		_, err := a.Client.GenerateContent(ctx, &gemini.GenerateContentRequest{Model: "gemini-2.5-pro", Project: a.ProjectID})
+		return err
+	})
+	var apiErr *gemini.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, 3, exhausted.calls, "with the other account cooling down the client retries the short delay")
+}
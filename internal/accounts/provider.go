@@ -0,0 +1,23 @@
+package accounts
+
+import (
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/credentials"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/metrics"
+)
+
+// observedProvider counts the token refreshes of an account, whether they come from the
+// refresh loop or a 401 retry in the client.
+type observedProvider struct {
+	credentials.CredentialsProvider
+	account string
+}
+
+func (p *observedProvider) RefreshToken() error {
+	err := p.CredentialsProvider.RefreshToken()
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	metrics.TokenRefreshes.Inc(p.account, result)
+	return err
+}
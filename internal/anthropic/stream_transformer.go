@@ -0,0 +1,218 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/logger"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/openai"
+	"github.com/google/uuid"
+)
+
+// Content block types tracked by the stream transformer
+const (
+	blockNone     = ""
+	blockText     = "text"
+	blockThinking = "thinking"
+	blockRedacted = "redacted_thinking"
+	blockToolUse  = "tool_use"
+)
+
+// CreateAnthropicStreamTransformer creates a transformer that converts Gemini StreamChunks
+// (the same chunk vocabulary consumed by the OpenAI transformer) into Anthropic Messages
+// SSE events: message_start, content_block_start/delta/stop, message_delta and message_stop.
+// It returns a function that accepts an input channel and returns an output channel.
+func CreateAnthropicStreamTransformer(model string) func(<-chan openai.StreamChunk) <-chan string {
+	return func(input <-chan openai.StreamChunk) <-chan string {
+		output := make(chan string, 10)
+
+		go func() {
+			defer close(output)
+
+			emit := func(event string, payload interface{}) {
+				jsonBytes, err := json.Marshal(payload)
+				if err != nil {
+					logger.Get().Error().Err(err).Str("event", event).Msg("Failed to marshal Anthropic SSE event")
+					return
+				}
+				output <- fmt.Sprintf("event: %s\ndata: %s\n\n", event, string(jsonBytes))
+			}
+
+			msgID := fmt.Sprintf("msg_%s", uuid.New().String())
+			emit("message_start", map[string]interface{}{
+				"type": "message_start",
+				"message": map[string]interface{}{
+					"id":            msgID,
+					"type":          MessageObject,
+					"role":          "assistant",
+					"content":       []interface{}{},
+					"model":         model,
+					"stop_reason":   nil,
+					"stop_sequence": nil,
+					"usage": map[string]interface{}{
+						"input_tokens":  0,
+						"output_tokens": 0,
+					},
+				},
+			})
+
+			blockIndex := -1
+			openBlock := blockNone
+			sawToolUse := false
+			finishReason := ""
+			var usage Usage
+
+			closeBlock := func() {
+				if openBlock == blockNone {
+					return
+				}
+				emit("content_block_stop", map[string]interface{}{
+					"type":  "content_block_stop",
+					"index": blockIndex,
+				})
+				openBlock = blockNone
+			}
+			startBlock := func(kind string, block map[string]interface{}) {
+				closeBlock()
+				blockIndex++
+				openBlock = kind
+				emit("content_block_start", map[string]interface{}{
+					"type":          "content_block_start",
+					"index":         blockIndex,
+					"content_block": block,
+				})
+			}
+			delta := func(d map[string]interface{}) {
+				emit("content_block_delta", map[string]interface{}{
+					"type":  "content_block_delta",
+					"index": blockIndex,
+					"delta": d,
+				})
+			}
+
+			for chunk := range input {
+				logger.Get().Debug().Interface("chunk", chunk).Msg("Processing Gemini stream chunk (anthropic)")
+
+				switch chunk.Type {
+				case "text", "thinking_content":
+					text, ok := chunk.Data.(string)
+					if !ok || text == "" {
+						continue
+					}
+					if openBlock != blockText {
+						startBlock(blockText, map[string]interface{}{"type": "text", "text": ""})
+					}
+					delta(map[string]interface{}{"type": "text_delta", "text": text})
+
+				case "real_thinking":
+					text, ok := chunk.Data.(string)
+					if !ok || text == "" {
+						continue
+					}
+					if openBlock != blockThinking {
+						startBlock(blockThinking, map[string]interface{}{"type": "thinking", "thinking": ""})
+					}
+					delta(map[string]interface{}{"type": "thinking_delta", "thinking": text})
+
+				case "thought_signature":
+					sig, ok := chunk.Data.(string)
+					if !ok || sig == "" {
+						continue
+					}
+					// Attach the signature to the running thinking block. Without one (e.g. thoughts
+					// were not requested) carry it as an opaque redacted_thinking block so clients
+					// echo it back and it can be restored on the next request.
+					if openBlock == blockThinking {
+						delta(map[string]interface{}{"type": "signature_delta", "signature": sig})
+						continue
+					}
+					startBlock(blockRedacted, map[string]interface{}{"type": "redacted_thinking", "data": sig})
+					closeBlock()
+
+				case "tool_code":
+					name, args, ok := toFunctionCall(chunk.Data)
+					if !ok {
+						continue
+					}
+					startBlock(blockToolUse, map[string]interface{}{
+						"type":  "tool_use",
+						"id":    fmt.Sprintf("toolu_%s", uuid.New().String()),
+						"name":  name,
+						"input": map[string]interface{}{},
+					})
+					argsJSON, _ := json.Marshal(args)
+					delta(map[string]interface{}{"type": "input_json_delta", "partial_json": string(argsJSON)})
+					closeBlock()
+					sawToolUse = true
+
+				case "finish_reason":
+					if fr, ok := chunk.Data.(string); ok && fr != "" {
+						finishReason = fr
+					}
+
+				case "usage":
+					if m, ok := chunk.Data.(map[string]interface{}); ok {
+						usage.InputTokens = toInt(m["inputTokens"])
+						usage.OutputTokens = toInt(m["outputTokens"])
+					}
+				}
+			}
+
+			closeBlock()
+
+			stopReason := StopReasonFromGemini(finishReason)
+			if sawToolUse {
+				stopReason = StopReasonToolUse
+			}
+
+			emit("message_delta", map[string]interface{}{
+				"type": "message_delta",
+				"delta": map[string]interface{}{
+					"stop_reason":   stopReason,
+					"stop_sequence": nil,
+				},
+				"usage": map[string]interface{}{
+					"input_tokens":  usage.InputTokens,
+					"output_tokens": usage.OutputTokens,
+				},
+			})
+			emit("message_stop", map[string]interface{}{"type": "message_stop"})
+		}()
+
+		return output
+	}
+}
+
+// FormatErrorEvent renders an Anthropic "error" SSE event.
+func FormatErrorEvent(errType, message string) string {
+	jsonBytes, _ := json.Marshal(ErrorResponse{
+		Type:  "error",
+		Error: ErrorDetail{Type: errType, Message: message},
+	})
+	return fmt.Sprintf("event: error\ndata: %s\n\n", string(jsonBytes))
+}
+
+// Type conversion helpers
+
+func toFunctionCall(data interface{}) (string, map[string]interface{}, bool) {
+	m, ok := data.(map[string]interface{})
+	if !ok {
+		return "", nil, false
+	}
+	name, _ := m["name"].(string)
+	args, _ := m["args"].(map[string]interface{})
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	return name, args, name != ""
+}
+
+func toInt(v interface{}) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	case float64:
+		return int(n)
+	}
+	return 0
+}
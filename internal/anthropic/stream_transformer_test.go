@@ -0,0 +1,169 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/openai"
+)
+
+type sseEvent struct {
+	Event string
+	Data  map[string]interface{}
+}
+
+func collectEvents(t *testing.T, chunks []openai.StreamChunk) []sseEvent {
+	t.Helper()
+
+	input := make(chan openai.StreamChunk, len(chunks))
+	for _, c := range chunks {
+		input <- c
+	}
+	close(input)
+
+	var events []sseEvent
+	for raw := range CreateAnthropicStreamTransformer("gemini-2.5-pro")(input) {
+		lines := strings.Split(strings.TrimSpace(raw), "\n")
+		if len(lines) != 2 || !strings.HasPrefix(lines[0], "event: ") || !strings.HasPrefix(lines[1], "data: ") {
+			t.Fatalf("unexpected SSE frame: %q", raw)
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &data); err != nil {
+			t.Fatalf("failed to parse event data: %v", err)
+		}
+		event := strings.TrimPrefix(lines[0], "event: ")
+		if data["type"] != event {
+			t.Errorf("event name %q does not match data type %v", event, data["type"])
+		}
+		events = append(events, sseEvent{Event: event, Data: data})
+	}
+	return events
+}
+
+func eventNames(events []sseEvent) []string {
+	names := make([]string, 0, len(events))
+	for _, e := range events {
+		names = append(names, e.Event)
+	}
+	return names
+}
+
+func TestCreateAnthropicStreamTransformer_TextSequence(t *testing.T) {
+	events := collectEvents(t, []openai.StreamChunk{
+		{Type: "text", Data: "Hello"},
+		{Type: "text", Data: ", world"},
+		{Type: "finish_reason", Data: "STOP"},
+		{Type: "usage", Data: map[string]interface{}{"inputTokens": float64(7), "outputTokens": float64(3)}},
+	})
+
+	expected := []string{
+		"message_start",
+		"content_block_start",
+		"content_block_delta",
+		"content_block_delta",
+		"content_block_stop",
+		"message_delta",
+		"message_stop",
+	}
+	if got := eventNames(events); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Fatalf("unexpected event sequence:\n got: %v\nwant: %v", got, expected)
+	}
+
+	delta := events[5].Data["delta"].(map[string]interface{})
+	if delta["stop_reason"] != StopReasonEndTurn {
+		t.Errorf("expected stop_reason end_turn, got %v", delta["stop_reason"])
+	}
+	usage := events[5].Data["usage"].(map[string]interface{})
+	if usage["output_tokens"] != float64(3) || usage["input_tokens"] != float64(7) {
+		t.Errorf("unexpected usage: %v", usage)
+	}
+}
+
+func TestCreateAnthropicStreamTransformer_ThinkingThenToolUse(t *testing.T) {
+	events := collectEvents(t, []openai.StreamChunk{
+		{Type: "real_thinking", Data: "Let me check the weather."},
+		{Type: "thought_signature", Data: "sig-123"},
+		{Type: "tool_code", Data: map[string]interface{}{
+			"name": "get_weather",
+			"args": map[string]interface{}{"location": "Tokyo"},
+		}},
+		{Type: "finish_reason", Data: "STOP"},
+	})
+
+	var (
+		sawSignature bool
+		toolStart    map[string]interface{}
+		toolJSON     string
+		stopReason   interface{}
+	)
+	for _, e := range events {
+		switch e.Event {
+		case "content_block_start":
+			block := e.Data["content_block"].(map[string]interface{})
+			if block["type"] == "tool_use" {
+				toolStart = block
+				if e.Data["index"] != float64(1) {
+					t.Errorf("expected tool_use at index 1, got %v", e.Data["index"])
+				}
+			}
+		case "content_block_delta":
+			d := e.Data["delta"].(map[string]interface{})
+			switch d["type"] {
+			case "signature_delta":
+				sawSignature = d["signature"] == "sig-123"
+			case "input_json_delta":
+				toolJSON += d["partial_json"].(string)
+			}
+		case "message_delta":
+			stopReason = e.Data["delta"].(map[string]interface{})["stop_reason"]
+		}
+	}
+
+	if !sawSignature {
+		t.Error("expected signature_delta on the thinking block")
+	}
+	if toolStart == nil || toolStart["name"] != "get_weather" || !strings.HasPrefix(toolStart["id"].(string), "toolu_") {
+		t.Fatalf("unexpected tool_use block: %v", toolStart)
+	}
+	if toolJSON != `{"location":"Tokyo"}` {
+		t.Errorf("unexpected tool input JSON: %s", toolJSON)
+	}
+	if stopReason != StopReasonToolUse {
+		t.Errorf("expected stop_reason tool_use, got %v", stopReason)
+	}
+}
+
+func TestCreateAnthropicStreamTransformer_SignatureWithoutThinking(t *testing.T) {
+	events := collectEvents(t, []openai.StreamChunk{
+		{Type: "thought_signature", Data: "sig-abc"},
+		{Type: "text", Data: "Done."},
+		{Type: "finish_reason", Data: "MAX_TOKENS"},
+	})
+
+	var redacted map[string]interface{}
+	for _, e := range events {
+		if e.Event == "content_block_start" {
+			block := e.Data["content_block"].(map[string]interface{})
+			if block["type"] == "redacted_thinking" {
+				redacted = block
+			}
+		}
+		if e.Event == "message_delta" {
+			if sr := e.Data["delta"].(map[string]interface{})["stop_reason"]; sr != StopReasonMaxTokens {
+				t.Errorf("expected stop_reason max_tokens, got %v", sr)
+			}
+		}
+	}
+	if redacted == nil || redacted["data"] != "sig-abc" {
+		t.Fatalf("expected redacted_thinking block carrying the signature, got %v", redacted)
+	}
+}
+
+func TestCreateAnthropicStreamTransformer_EmptyChannel(t *testing.T) {
+	events := collectEvents(t, nil)
+	expected := []string{"message_start", "message_delta", "message_stop"}
+	if got := eventNames(events); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Fatalf("unexpected event sequence: %v", got)
+	}
+}
@@ -0,0 +1,164 @@
+package anthropic
+
+import (
+	"encoding/json"
+)
+
+// MessagesRequest represents a request payload for the Anthropic Messages API (/v1/messages).
+type MessagesRequest struct {
+	Model         string                 `json:"model"`
+	Messages      []Message              `json:"messages"`
+	System        Content                `json:"system,omitempty"` // Can be a string or a slice of ContentBlock
+	MaxTokens     int                    `json:"max_tokens"`
+	Temperature   *float64               `json:"temperature,omitempty"`
+	TopP          *float64               `json:"top_p,omitempty"`
+	TopK          *int                   `json:"top_k,omitempty"`
+	StopSequences []string               `json:"stop_sequences,omitempty"`
+	Stream        bool                   `json:"stream"`
+	Tools         []Tool                 `json:"tools,omitempty"`
+	ToolChoice    *ToolChoice            `json:"tool_choice,omitempty"`
+	Thinking      *ThinkingConfig        `json:"thinking,omitempty"`
+	Metadata      map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// Message represents a single turn in the conversation.
+type Message struct {
+	Role    string  `json:"role"`
+	Content Content `json:"content"`
+}
+
+// Content is a list of content blocks. On the wire it may also be a plain string,
+// which is treated as a single text block.
+type Content []ContentBlock
+
+// UnmarshalJSON: accept a plain string as shorthand for a single text block.
+func (c *Content) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		if s == "" {
+			*c = nil
+			return nil
+		}
+		*c = Content{{Type: "text", Text: s}}
+		return nil
+	}
+	var blocks []ContentBlock
+	if err := json.Unmarshal(b, &blocks); err != nil {
+		return err
+	}
+	*c = blocks
+	return nil
+}
+
+// ContentBlock is a single typed block inside a message.
+//
+// Supported types: text, image, document, tool_use, tool_result, thinking, redacted_thinking.
+// Only the fields relevant to the block type are populated.
+type ContentBlock struct {
+	Type string `json:"type"`
+
+	// text
+	Text string `json:"text,omitempty"`
+
+	// image, document
+	Source *ImageSource `json:"source,omitempty"`
+
+	// tool_use
+	ID    string      `json:"id,omitempty"`
+	Name  string      `json:"name,omitempty"`
+	Input interface{} `json:"input,omitempty"`
+
+	// tool_result
+	ToolUseID string  `json:"tool_use_id,omitempty"`
+	Content   Content `json:"content,omitempty"`
+	IsError   bool    `json:"is_error,omitempty"`
+
+	// thinking
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+
+	// redacted_thinking
+	Data string `json:"data,omitempty"`
+}
+
+// ImageSource describes the payload of an image or document block.
+type ImageSource struct {
+	Type      string `json:"type"` // "base64", "url" or "text" (documents only)
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// Tool represents a client tool the model can call.
+type Tool struct {
+	Type        string      `json:"type,omitempty"` // empty or "custom" for client tools
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	InputSchema interface{} `json:"input_schema,omitempty"`
+}
+
+// ToolChoice controls whether and which tools the model must use.
+type ToolChoice struct {
+	Type                   string `json:"type"` // auto, any, tool, none
+	Name                   string `json:"name,omitempty"`
+	DisableParallelToolUse bool   `json:"disable_parallel_tool_use,omitempty"`
+}
+
+// ThinkingConfig enables extended thinking.
+type ThinkingConfig struct {
+	Type         string `json:"type"` // enabled, disabled
+	BudgetTokens int    `json:"budget_tokens,omitempty"`
+}
+
+// MessagesResponse represents a non-streaming response from the Messages API.
+type MessagesResponse struct {
+	ID           string         `json:"id"`
+	Type         string         `json:"type"`
+	Role         string         `json:"role"`
+	Model        string         `json:"model"`
+	Content      []ContentBlock `json:"content"`
+	StopReason   string         `json:"stop_reason"`
+	StopSequence *string        `json:"stop_sequence"`
+	Usage        Usage          `json:"usage"`
+}
+
+// Usage represents the token usage for a request.
+type Usage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+}
+
+// ErrorResponse is the error envelope returned by the Messages API.
+type ErrorResponse struct {
+	Type  string      `json:"type"`
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail carries the error type and message.
+type ErrorDetail struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+const (
+	MessageObject = "message"
+
+	StopReasonEndTurn   = "end_turn"
+	StopReasonMaxTokens = "max_tokens"
+	StopReasonToolUse   = "tool_use"
+	StopReasonRefusal   = "refusal"
+)
+
+// StopReasonFromGemini maps a Gemini candidate finishReason to an Anthropic stop_reason.
+func StopReasonFromGemini(finishReason string) string {
+	switch finishReason {
+	case "MAX_TOKENS":
+		return StopReasonMaxTokens
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+		return StopReasonRefusal
+	default:
+		return StopReasonEndTurn
+	}
+}
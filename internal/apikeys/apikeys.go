@@ -0,0 +1,181 @@
+// Package apikeys manages the API keys clients use to authenticate against the proxy.
+//
+// Keys are random tokens shown once when created; only their SHA-256 hash is stored.
+// Each key has scopes (inference, admin), an optional model allowlist and an optional expiry.
+package apikeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/ratelimit"
+)
+
+// Scope grants access to a group of endpoints.
+type Scope string
+
+const (
+	// ScopeInference allows the model endpoints (/v1/chat/completions, /v1/messages, /v1beta/models/...)
+	ScopeInference Scope = "inference"
+	// ScopeAdmin allows the /admin endpoints
+	ScopeAdmin Scope = "admin"
+)
+
+// tokenPrefix marks proxy API keys so they are recognizable in configs and secret scanners.
+const tokenPrefix = "gcap_"
+
+var (
+	// ErrNotFound is returned when a key does not exist
+	ErrNotFound = errors.New("api key not found")
+	// ErrInvalidKey is returned when a token matches no key
+	ErrInvalidKey = errors.New("invalid api key")
+	// ErrExpired is returned when a token matches an expired key
+	ErrExpired = errors.New("api key expired")
+)
+
+// Key is a stored API key. The token itself is never stored, only its hash.
+type Key struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Hash      string     `json:"hash"`
+	Prefix    string     `json:"prefix"` // first characters of the token, to recognize it
+	Scopes    []Scope    `json:"scopes"`
+	Models    []string   `json:"models,omitempty"` // allowed models; empty allows all
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	// RateLimits overrides the global rate limits for this key; nil uses the defaults
+	RateLimits *ratelimit.Limits `json:"rate_limits,omitempty"`
+}
+
+// ValidScope reports whether s is a known scope.
+func ValidScope(s Scope) bool {
+	return s == ScopeInference || s == ScopeAdmin
+}
+
+// HasScope reports whether the key grants scope.
+func (k *Key) HasScope(scope Scope) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsModel reports whether the key may use model. Entries ending in "*" match by prefix.
+func (k *Key) AllowsModel(model string) bool {
+	if len(k.Models) == 0 {
+		return true
+	}
+	for _, m := range k.Models {
+		if m == model || (strings.HasSuffix(m, "*") && strings.HasPrefix(model, strings.TrimSuffix(m, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// Expired reports whether the key has expired at now.
+func (k *Key) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// Generate creates a new key and returns it with its token. The token is not recoverable later.
+func Generate(name string, scopes []Scope, models []string, expiresAt *time.Time) (*Key, string, error) {
+	for _, s := range scopes {
+		if !ValidScope(s) {
+			return nil, "", fmt.Errorf("unknown scope %q", s)
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	token := tokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, "", fmt.Errorf("failed to generate api key id: %w", err)
+	}
+
+	return &Key{
+		ID:        "key_" + hex.EncodeToString(id),
+		Name:      name,
+		Hash:      Hash(token),
+		Prefix:    token[:len(tokenPrefix)+6],
+		Scopes:    scopes,
+		Models:    models,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now().UTC(),
+	}, token, nil
+}
+
+// Hash returns the hex SHA-256 of token. Tokens are random, so a plain hash is enough at rest.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authenticate returns the key matching token. Hashes are compared in constant time.
+func Authenticate(store Store, token string, now time.Time) (*Key, error) {
+	keys, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	hash := []byte(Hash(token))
+	var match *Key
+	for _, k := range keys {
+		if subtle.ConstantTimeCompare(hash, []byte(k.Hash)) == 1 {
+			match = k
+		}
+	}
+	if match == nil {
+		return nil, ErrInvalidKey
+	}
+	if match.Expired(now) {
+		return nil, ErrExpired
+	}
+	return match, nil
+}
+
+// Store persists API keys.
+type Store interface {
+	// List returns all keys
+	List() ([]*Key, error)
+
+	// Get returns the key with the given ID or ErrNotFound
+	Get(id string) (*Key, error)
+
+	// Put creates or replaces a key
+	Put(key *Key) error
+
+	// Delete removes a key or returns ErrNotFound
+	Delete(id string) error
+
+	// Name returns the name of the store for logging
+	Name() string
+}
+
+// keyFile is the JSON document holding all keys, in a file or a KV value.
+type keyFile struct {
+	Keys []*Key `json:"keys"`
+}
+
+func findKey(keys []*Key, id string) int {
+	for i, k := range keys {
+		if k.ID == id {
+			return i
+		}
+	}
+	return -1
+}
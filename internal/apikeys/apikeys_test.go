@@ -0,0 +1,99 @@
+package apikeys
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	key, token, err := Generate("ci", []Scope{ScopeInference}, nil, nil)
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(token, tokenPrefix))
+	assert.True(t, strings.HasPrefix(key.ID, "key_"))
+	assert.True(t, strings.HasPrefix(token, key.Prefix))
+	assert.Equal(t, Hash(token), key.Hash)
+	assert.NotContains(t, key.Hash, token)
+
+	_, other, err := Generate("ci", []Scope{ScopeInference}, nil, nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, token, other)
+
+	_, _, err = Generate("bad", []Scope{"superuser"}, nil, nil)
+	assert.Error(t, err)
+}
+
+func TestAuthenticate(t *testing.T) {
+	store := &memStore{}
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	key, token, err := Generate("ci", []Scope{ScopeInference}, nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, store.Put(key))
+
+	expiresAt := now.Add(-time.Minute)
+	expired, expiredToken, err := Generate("old", []Scope{ScopeInference}, nil, &expiresAt)
+	require.NoError(t, err)
+	require.NoError(t, store.Put(expired))
+
+	got, err := Authenticate(store, token, now)
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, got.ID)
+
+	_, err = Authenticate(store, "gcap_wrong", now)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	_, err = Authenticate(store, expiredToken, now)
+	assert.ErrorIs(t, err, ErrExpired)
+}
+
+func TestKeyScopesAndModels(t *testing.T) {
+	key := &Key{Scopes: []Scope{ScopeInference}, Models: []string{"gemini-2.5-flash", "gemini-3-*"}}
+
+	assert.True(t, key.HasScope(ScopeInference))
+	assert.False(t, key.HasScope(ScopeAdmin))
+
+	assert.True(t, key.AllowsModel("gemini-2.5-flash"))
+	assert.True(t, key.AllowsModel("gemini-3-pro-preview"))
+	assert.False(t, key.AllowsModel("gemini-2.5-pro"))
+
+	assert.True(t, (&Key{}).AllowsModel("anything"), "an empty allowlist allows all models")
+}
+
+// memStore is an in-memory Store for tests.
+type memStore struct {
+	keys []*Key
+}
+
+func (m *memStore) List() ([]*Key, error) { return m.keys, nil }
+
+func (m *memStore) Get(id string) (*Key, error) {
+	if i := findKey(m.keys, id); i >= 0 {
+		return m.keys[i], nil
+	}
+	return nil, ErrNotFound
+}
+
+func (m *memStore) Put(key *Key) error {
+	if i := findKey(m.keys, key.ID); i >= 0 {
+		m.keys[i] = key
+	} else {
+		m.keys = append(m.keys, key)
+	}
+	return nil
+}
+
+func (m *memStore) Delete(id string) error {
+	i := findKey(m.keys, id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	m.keys = append(m.keys[:i], m.keys[i+1:]...)
+	return nil
+}
+
+func (m *memStore) Name() string { return "memStore" }
@@ -0,0 +1,128 @@
+//go:build !js || !wasm
+
+package apikeys
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/env"
+)
+
+// FileStore keeps API keys in a JSON file, readable only by the owner.
+type FileStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileStore creates a store backed by the file at path. The file is created on the first write.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// NewDefaultStore returns the store for this platform: a file at API_KEYS_PATH,
+// defaulting to ~/.gemini/proxy_api_keys.json.
+func NewDefaultStore() (Store, error) {
+	if path, ok := env.Get("API_KEYS_PATH"); ok {
+		return NewFileStore(path), nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return NewFileStore(filepath.Join(homeDir, ".gemini", "proxy_api_keys.json")), nil
+}
+
+// The file is read on every call so keys added or revoked by another process (e.g. the CLI) apply immediately.
+func (f *FileStore) load() ([]*Key, error) {
+	data, err := os.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read api keys: %w", err)
+	}
+	var file keyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse api keys from %s: %w", f.path, err)
+	}
+	return file.Keys, nil
+}
+
+func (f *FileStore) save(keys []*Key) error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create directory for api keys: %w", err)
+	}
+	data, err := json.MarshalIndent(keyFile{Keys: keys}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal api keys: %w", err)
+	}
+
+	// Write atomically so a concurrent reader never sees a partial file
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write api keys: %w", err)
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		return fmt.Errorf("failed to write api keys: %w", err)
+	}
+	return nil
+}
+
+func (f *FileStore) List() ([]*Key, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.load()
+}
+
+func (f *FileStore) Get(id string) (*Key, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keys, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	if i := findKey(keys, id); i >= 0 {
+		return keys[i], nil
+	}
+	return nil, ErrNotFound
+}
+
+func (f *FileStore) Put(key *Key) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keys, err := f.load()
+	if err != nil {
+		return err
+	}
+	if i := findKey(keys, key.ID); i >= 0 {
+		keys[i] = key
+	} else {
+		keys = append(keys, key)
+	}
+	return f.save(keys)
+}
+
+func (f *FileStore) Delete(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	keys, err := f.load()
+	if err != nil {
+		return err
+	}
+	i := findKey(keys, id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	return f.save(append(keys[:i], keys[i+1:]...))
+}
+
+func (f *FileStore) Name() string {
+	return fmt.Sprintf("FileStore(%s)", f.path)
+}
@@ -0,0 +1,46 @@
+//go:build !js || !wasm
+
+package apikeys
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "keys.json")
+	store := NewFileStore(path)
+
+	keys, err := store.List()
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	key, _, err := Generate("ci", []Scope{ScopeInference}, []string{"gemini-2.5-pro"}, nil)
+	require.NoError(t, err)
+	require.NoError(t, store.Put(key))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	got, err := store.Get(key.ID)
+	require.NoError(t, err)
+	assert.Equal(t, key.Name, got.Name)
+	assert.Equal(t, key.Models, got.Models)
+
+	got.Name = "renamed"
+	require.NoError(t, store.Put(got))
+	keys, err = store.List()
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, "renamed", keys[0].Name)
+
+	require.NoError(t, store.Delete(key.ID))
+	_, err = store.Get(key.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, store.Delete(key.ID), ErrNotFound)
+}
@@ -0,0 +1,98 @@
+//go:build js && wasm
+
+package apikeys
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/syumai/workers/cloudflare/kv"
+)
+
+// kvKey is the KV entry holding all API keys.
+const kvKey = "proxy_api_keys"
+
+// KVStore keeps API keys in Cloudflare KV, next to the OAuth credentials.
+// It is not cached: another isolate may have revoked a key.
+type KVStore struct {
+	kvStore *kv.Namespace
+}
+
+// NewDefaultStore returns the store for this platform: the proxy's KV namespace.
+func NewDefaultStore() (Store, error) {
+	kvStore, err := kv.NewNamespace("gemini_code_assist_proxy_kv")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize KV namespace: %w", err)
+	}
+	return &KVStore{kvStore: kvStore}, nil
+}
+
+func (s *KVStore) load() ([]*Key, error) {
+	data, err := s.kvStore.GetString(kvKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api keys from KV: %w", err)
+	}
+	if data == "" {
+		return nil, nil
+	}
+	var file keyFile
+	if err := json.Unmarshal([]byte(data), &file); err != nil {
+		return nil, fmt.Errorf("failed to parse api keys from KV: %w", err)
+	}
+	return file.Keys, nil
+}
+
+func (s *KVStore) save(keys []*Key) error {
+	data, err := json.Marshal(keyFile{Keys: keys})
+	if err != nil {
+		return fmt.Errorf("failed to marshal api keys: %w", err)
+	}
+	if err := s.kvStore.PutString(kvKey, string(data), nil); err != nil {
+		return fmt.Errorf("failed to store api keys in KV: %w", err)
+	}
+	return nil
+}
+
+func (s *KVStore) List() ([]*Key, error) {
+	return s.load()
+}
+
+func (s *KVStore) Get(id string) (*Key, error) {
+	keys, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	if i := findKey(keys, id); i >= 0 {
+		return keys[i], nil
+	}
+	return nil, ErrNotFound
+}
+
+func (s *KVStore) Put(key *Key) error {
+	keys, err := s.load()
+	if err != nil {
+		return err
+	}
+	if i := findKey(keys, key.ID); i >= 0 {
+		keys[i] = key
+	} else {
+		keys = append(keys, key)
+	}
+	return s.save(keys)
+}
+
+func (s *KVStore) Delete(id string) error {
+	keys, err := s.load()
+	if err != nil {
+		return err
+	}
+	i := findKey(keys, id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	return s.save(append(keys[:i], keys[i+1:]...))
+}
+
+func (s *KVStore) Name() string {
+	return "KVStore"
+}
@@ -96,7 +96,7 @@ func (c *CloudflareKVProvider) RefreshToken() error {
 	form.Add("grant_type", "refresh_token")
 
 	// Create fetch request for Workers
-	fetchReq, err := fetch.NewRequest(context.Background(), "POST", "https://oauth2.googleapis.com/token", strings.NewReader(form.Encode()))
+	fetchReq, err := fetch.NewRequest(context.Background(), "POST", tokenURL(), strings.NewReader(form.Encode()))
 	if err != nil {
 		return err
 	}
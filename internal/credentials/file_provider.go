@@ -19,6 +19,9 @@ import (
 type FileProvider struct {
 	filePath   string
 	httpClient *http.Client
+
+	// fileOnly disables the CLOUDCODE_OAUTH_CREDS fallback for providers bound to one file
+	fileOnly bool
 }
 
 // NewFileProvider creates a new file-based credentials provider
@@ -37,6 +40,18 @@ func NewFileProvider() (*FileProvider, error) {
 	return provider, nil
 }
 
+// NewFileProviderWithPath creates a file-based credentials provider reading a specific file,
+// e.g. one account of a multi-account pool
+func NewFileProviderWithPath(path string) *FileProvider {
+	return &FileProvider{
+		filePath: path,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		fileOnly: true,
+	}
+}
+
 // determineFilePath sets the file path based on environment variables or defaults
 func (f *FileProvider) determineFilePath() error {
 	// 1. Check for file path in environment variable
@@ -67,7 +82,7 @@ func (f *FileProvider) GetCredentials() (*OAuthCredentials, error) {
 			return creds, nil
 		}
 		// If file doesn't exist, continue to check environment variable
-		if !os.IsNotExist(err) {
+		if !os.IsNotExist(err) || f.fileOnly {
 			return nil, fmt.Errorf("failed to read credentials file: %w", err)
 		}
 	}
@@ -109,7 +124,6 @@ func (f *FileProvider) SaveCredentials(creds *OAuthCredentials) error {
 	// Write to file
 	if err := ioutil.WriteFile(f.filePath, data, 0o600); err != nil {
 		return fmt.Errorf("failed to write credentials to %s: %w", f.filePath, err)
-		return fmt.Errorf("failed to write credentials to file: %w", err)
 	}
 
 	logger.Get().Info().Msgf("Saved credentials to %s", f.filePath)
@@ -134,7 +148,7 @@ func (f *FileProvider) RefreshToken() error {
 	form.Add("refresh_token", creds.RefreshToken)
 	form.Add("grant_type", "refresh_token")
 
-	req, err := http.NewRequest("POST", "https://oauth2.googleapis.com/token", strings.NewReader(form.Encode()))
+	req, err := http.NewRequest("POST", tokenURL(), strings.NewReader(form.Encode()))
 	if err != nil {
 		return err
 	}
@@ -182,6 +196,11 @@ func (f *FileProvider) RefreshToken() error {
 	return nil
 }
 
+// Path returns the credentials file, or an empty string when credentials come from CLOUDCODE_OAUTH_CREDS
+func (f *FileProvider) Path() string {
+	return f.filePath
+}
+
 // Name returns the provider name
 func (f *FileProvider) Name() string {
 	if f.filePath != "" {
@@ -0,0 +1,277 @@
+package credentials
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/env"
+)
+
+// tokenURL returns the OAuth token endpoint, overridable with OAUTH_TOKEN_URL (e.g. for a local fake server)
+func tokenURL() string {
+	return env.GetOrDefault("OAUTH_TOKEN_URL", OAuthTokenURL)
+}
+
+// LoginConfig configures the OAuth authorization code flow.
+type LoginConfig struct {
+	AuthURL      string
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	RedirectURI  string
+	Scopes       []string
+	HTTPClient   *http.Client
+}
+
+// DefaultLoginConfig returns the Gemini CLI OAuth client. The endpoints can be overridden with
+// OAUTH_AUTH_URL, OAUTH_TOKEN_URL and OAUTH_REDIRECT_URI.
+func DefaultLoginConfig() LoginConfig {
+	return LoginConfig{
+		AuthURL:      env.GetOrDefault("OAUTH_AUTH_URL", OAuthAuthURL),
+		TokenURL:     tokenURL(),
+		ClientID:     OAuthClientID,
+		ClientSecret: OAuthClientSecret,
+		RedirectURI:  env.GetOrDefault("OAUTH_REDIRECT_URI", OAuthRedirectURI),
+		Scopes:       OAuthScopes,
+		HTTPClient:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// PKCE holds a code verifier and its S256 challenge (RFC 7636).
+type PKCE struct {
+	Verifier  string
+	Challenge string
+}
+
+// NewPKCE generates a random code verifier.
+func NewPKCE() (PKCE, error) {
+	verifier, err := randomString(32)
+	if err != nil {
+		return PKCE{}, err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return PKCE{
+		Verifier:  verifier,
+		Challenge: base64.RawURLEncoding.EncodeToString(sum[:]),
+	}, nil
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random value: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// AuthCodeURL returns the URL the user opens to grant access.
+func (c LoginConfig) AuthCodeURL(state string, pkce PKCE, redirectURI string) string {
+	q := url.Values{}
+	q.Set("client_id", c.ClientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("response_type", "code")
+	q.Set("scope", strings.Join(c.Scopes, " "))
+	q.Set("state", state)
+	q.Set("code_challenge", pkce.Challenge)
+	q.Set("code_challenge_method", "S256")
+	// Always return a refresh token, also when the user granted access before
+	q.Set("access_type", "offline")
+	q.Set("prompt", "consent")
+
+	sep := "?"
+	if strings.Contains(c.AuthURL, "?") {
+		sep = "&"
+	}
+	return c.AuthURL + sep + q.Encode()
+}
+
+// Exchange trades an authorization code for credentials.
+func (c LoginConfig) Exchange(ctx context.Context, code string, pkce PKCE, redirectURI string) (*OAuthCredentials, error) {
+	form := url.Values{}
+	form.Set("client_id", c.ClientID)
+	form.Set("client_secret", c.ClientSecret)
+	form.Set("code", code)
+	form.Set("code_verifier", pkce.Verifier)
+	form.Set("redirect_uri", redirectURI)
+	form.Set("grant_type", "authorization_code")
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token exchange failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var tokenResp TokenResponse
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return nil, fmt.Errorf("failed to parse token response: %w", err)
+	}
+	if tokenResp.AccessToken == "" {
+		return nil, errors.New("token response contains no access token")
+	}
+
+	return &OAuthCredentials{
+		AccessToken:  tokenResp.AccessToken,
+		RefreshToken: tokenResp.RefreshToken,
+		ExpiryDate:   time.Now().Add(time.Duration(tokenResp.ExpiresIn)*time.Second).Unix() * 1000,
+		TokenType:    tokenResp.TokenType,
+		Scope:        tokenResp.Scope,
+		IDToken:      tokenResp.IDToken,
+	}, nil
+}
+
+// LoginLoopback runs the authorization code flow with a local listener on the redirect URI.
+// openURL is called with the URL the user has to open (typically in a browser).
+// A redirect URI with port 0 listens on a random port.
+func LoginLoopback(ctx context.Context, cfg LoginConfig, openURL func(string)) (*OAuthCredentials, error) {
+	redirect, err := url.Parse(cfg.RedirectURI)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redirect URI: %w", err)
+	}
+	listener, err := net.Listen("tcp", redirect.Host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", redirect.Host, err)
+	}
+	defer listener.Close()
+	if redirect.Port() == "0" {
+		redirect.Host = net.JoinHostPort(redirect.Hostname(), fmt.Sprint(listener.Addr().(*net.TCPAddr).Port))
+	}
+	redirectURI := redirect.String()
+
+	pkce, err := NewPKCE()
+	if err != nil {
+		return nil, err
+	}
+	state, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		code string
+		err  error
+	}
+	results := make(chan result, 1)
+	path := redirect.Path
+	if path == "" {
+		path = "/"
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		code, err := codeFromQuery(r.URL.Query(), state)
+		if err != nil {
+			http.Error(w, "Login failed: "+err.Error(), http.StatusBadRequest)
+		} else {
+			fmt.Fprintln(w, "Login successful. You can close this window and return to the terminal.")
+		}
+		select {
+		case results <- result{code: code, err: err}:
+		default:
+		}
+	})
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(listener)
+	defer srv.Close()
+
+	openURL(cfg.AuthCodeURL(state, pkce, redirectURI))
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-results:
+		if res.err != nil {
+			return nil, res.err
+		}
+		return cfg.Exchange(ctx, res.code, pkce, redirectURI)
+	}
+}
+
+// LoginManual runs the authorization code flow without a local listener, for machines without a
+// browser (e.g. over SSH). The user opens the printed URL elsewhere and pastes the URL they were
+// redirected to (or just its code parameter) into input.
+func LoginManual(ctx context.Context, cfg LoginConfig, output io.Writer, input io.Reader) (*OAuthCredentials, error) {
+	pkce, err := NewPKCE()
+	if err != nil {
+		return nil, err
+	}
+	state, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(output, "Open this URL in a browser and grant access:\n\n  %s\n\n", cfg.AuthCodeURL(state, pkce, cfg.RedirectURI))
+	fmt.Fprintln(output, "The browser is then redirected to a page that fails to load. Paste its full URL (or the code parameter) here:")
+
+	line, err := bufio.NewReader(input).ReadString('\n')
+	if err != nil && line == "" {
+		return nil, fmt.Errorf("failed to read authorization code: %w", err)
+	}
+	code, err := parsePastedCode(strings.TrimSpace(line), state)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Exchange(ctx, code, pkce, cfg.RedirectURI)
+}
+
+// parsePastedCode accepts a redirect URL, a query string or a bare authorization code.
+func parsePastedCode(pasted, state string) (string, error) {
+	if pasted == "" {
+		return "", errors.New("no authorization code entered")
+	}
+	if !strings.Contains(pasted, "code=") && !strings.Contains(pasted, "error=") {
+		return pasted, nil
+	}
+	query := pasted
+	if i := strings.Index(pasted, "?"); i >= 0 {
+		query = pasted[i+1:]
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return "", fmt.Errorf("invalid redirect URL: %w", err)
+	}
+	return codeFromQuery(values, state)
+}
+
+// codeFromQuery validates the redirect parameters and returns the authorization code.
+func codeFromQuery(q url.Values, state string) (string, error) {
+	if e := q.Get("error"); e != "" {
+		return "", fmt.Errorf("authorization failed: %s", e)
+	}
+	if q.Get("state") != state {
+		return "", errors.New("state mismatch")
+	}
+	code := q.Get("code")
+	if code == "" {
+		return "", errors.New("no authorization code in redirect")
+	}
+	return code, nil
+}
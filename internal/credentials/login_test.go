@@ -0,0 +1,161 @@
+package credentials
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOAuthServer approves every authorization request and issues tokens for the code it handed out
+// if the PKCE verifier matches the challenge.
+func fakeOAuthServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	challenges := map[string]string{} // code -> challenge
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/auth", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		assert.Equal(t, "S256", q.Get("code_challenge_method"))
+		assert.Equal(t, "offline", q.Get("access_type"))
+		challenges["code-123"] = q.Get("code_challenge")
+
+		redirect, err := url.Parse(q.Get("redirect_uri"))
+		require.NoError(t, err)
+		redirect.RawQuery = url.Values{"code": {"code-123"}, "state": {q.Get("state")}}.Encode()
+		http.Redirect(w, r, redirect.String(), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "authorization_code", r.Form.Get("grant_type"))
+		assert.Equal(t, "client-id", r.Form.Get("client_id"))
+
+		sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+		if challenges[r.Form.Get("code")] != base64.RawURLEncoding.EncodeToString(sum[:]) {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  "access-abc",
+			"refresh_token": "refresh-xyz",
+			"expires_in":    3600,
+			"token_type":    "Bearer",
+			"scope":         "openid",
+		})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func testLoginConfig(srv *httptest.Server, redirectURI string) LoginConfig {
+	return LoginConfig{
+		AuthURL:      srv.URL + "/auth",
+		TokenURL:     srv.URL + "/token",
+		ClientID:     "client-id",
+		ClientSecret: "secret",
+		RedirectURI:  redirectURI,
+		Scopes:       OAuthScopes,
+		HTTPClient:   srv.Client(),
+	}
+}
+
+func TestLoginLoopback(t *testing.T) {
+	srv := fakeOAuthServer(t)
+	cfg := testLoginConfig(srv, "http://127.0.0.1:0/oauth2callback")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// The "browser" follows the redirect from the auth endpoint to the loopback listener
+	creds, err := LoginLoopback(ctx, cfg, func(authURL string) {
+		go func() {
+			resp, err := http.Get(authURL)
+			if assert.NoError(t, err) {
+				resp.Body.Close()
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+			}
+		}()
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "access-abc", creds.AccessToken)
+	assert.Equal(t, "refresh-xyz", creds.RefreshToken)
+	assert.Greater(t, creds.ExpiryDate, time.Now().UnixMilli())
+}
+
+func TestLoginLoopback_RejectsWrongState(t *testing.T) {
+	srv := fakeOAuthServer(t)
+	cfg := testLoginConfig(srv, "http://127.0.0.1:0/")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := LoginLoopback(ctx, cfg, func(authURL string) {
+		u, _ := url.Parse(authURL)
+		redirect := u.Query().Get("redirect_uri") + "?code=code-123&state=forged"
+		go func() {
+			if resp, err := http.Get(redirect); err == nil {
+				resp.Body.Close()
+			}
+		}()
+	})
+	assert.ErrorContains(t, err, "state mismatch")
+}
+
+func TestLoginManual(t *testing.T) {
+	srv := fakeOAuthServer(t)
+	cfg := testLoginConfig(srv, "http://localhost:45289")
+
+	// Simulate the user: open the printed URL and paste the URL the browser was redirected to
+	outR, outW := io.Pipe()
+	inR, inW := io.Pipe()
+	go func() {
+		scanner := bufio.NewScanner(outR)
+		for scanner.Scan() {
+			authURL := strings.TrimSpace(scanner.Text())
+			if !strings.HasPrefix(authURL, "http") {
+				continue
+			}
+			client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+			resp, err := client.Get(authURL)
+			if !assert.NoError(t, err) {
+				inW.Close()
+				break
+			}
+			resp.Body.Close()
+			go inW.Write([]byte(resp.Header.Get("Location") + "\n"))
+		}
+		io.Copy(io.Discard, outR)
+	}()
+
+	creds, err := LoginManual(context.Background(), cfg, outW, inR)
+	require.NoError(t, err)
+	assert.Equal(t, "access-abc", creds.AccessToken)
+}
+
+func TestParsePastedCode(t *testing.T) {
+	code, err := parsePastedCode("http://localhost:45289/?state=s1&code=4/abc&scope=x", "s1")
+	require.NoError(t, err)
+	assert.Equal(t, "4/abc", code)
+
+	code, err = parsePastedCode("4/bare-code", "s1")
+	require.NoError(t, err)
+	assert.Equal(t, "4/bare-code", code)
+
+	_, err = parsePastedCode("http://localhost:45289/?state=other&code=abc", "s1")
+	assert.ErrorContains(t, err, "state mismatch")
+
+	_, err = parsePastedCode("http://localhost:45289/?error=access_denied", "s1")
+	assert.ErrorContains(t, err, "access_denied")
+}
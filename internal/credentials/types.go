@@ -10,6 +10,16 @@ type OAuthCredentials struct {
 	IDToken      string `json:"id_token,omitempty"`
 }
 
+// TokenResponse represents the response from the token endpoint for an authorization code
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	Scope        string `json:"scope"`
+	TokenType    string `json:"token_type"`
+	IDToken      string `json:"id_token"`
+}
+
 // TokenRefreshResponse represents the response from the token refresh endpoint
 type TokenRefreshResponse struct {
 	AccessToken string `json:"access_token"`
@@ -25,4 +35,13 @@ const (
 	OAuthClientID        = "681255809395-oo8ft2oprdrnp9e3aqf6av3hmdib135j.apps.googleusercontent.com"
 	OAuthClientSecret    = "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"
 	OAuthRedirectURI     = "http://localhost:45289"
+	OAuthAuthURL         = "https://accounts.google.com/o/oauth2/v2/auth"
+	OAuthTokenURL        = "https://oauth2.googleapis.com/token"
 )
+
+// OAuthScopes are the scopes requested by the login flow (the same as Gemini CLI)
+var OAuthScopes = []string{
+	"https://www.googleapis.com/auth/cloud-platform",
+	"https://www.googleapis.com/auth/userinfo.email",
+	"https://www.googleapis.com/auth/userinfo.profile",
+}
@@ -5,27 +5,212 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"time"
 
 	"github.com/dvcrn/gemini-code-assist-proxy/internal/credentials"
 	serverhttp "github.com/dvcrn/gemini-code-assist-proxy/internal/http"
 	"github.com/dvcrn/gemini-code-assist-proxy/internal/logger"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/retry"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/tracing"
 )
 
 // Client is a client for the Gemini API.
 type Client struct {
 	httpClient serverhttp.HTTPClient
 	provider   credentials.CredentialsProvider
+
+	// policies are the retry policies by Code Assist method
+	policies map[string]retry.Policy
+}
+
+// defaultRetryPolicies are the retry policies of the Code Assist methods unless overridden
+// through the RETRY_* environment variables. Onboarding happens at startup and can afford
+// to wait longer.
+var defaultRetryPolicies = map[string]retry.Policy{
+	"generateContent":       {MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 10 * time.Second},
+	"streamGenerateContent": {MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 10 * time.Second},
+	"countTokens":           {MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 10 * time.Second},
+	"loadCodeAssist":        {MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 10 * time.Second},
+	"onboardUser":           {MaxAttempts: 5, BaseDelay: 2 * time.Second, MaxDelay: 30 * time.Second},
 }
 
 // NewClient creates a new Gemini API client.
 func NewClient(provider credentials.CredentialsProvider) *Client {
+	policies := make(map[string]retry.Policy, len(defaultRetryPolicies))
+	for method, def := range defaultRetryPolicies {
+		policies[method] = retry.FromEnv(method, def)
+	}
 	return &Client{
 		httpClient: serverhttp.NewHTTPClient(),
 		provider:   provider,
+		policies:   policies,
+	}
+}
+
+// NewClientWithHTTPClient creates a Gemini API client that sends its requests through httpClient.
+func NewClientWithHTTPClient(provider credentials.CredentialsProvider, httpClient serverhttp.HTTPClient) *Client {
+	c := NewClient(provider)
+	c.httpClient = httpClient
+	return c
+}
+
+// quotaFailoverKey marks the context of a call that another account can take over.
+type quotaFailoverKey struct{}
+
+// WithQuotaFailover marks ctx as belonging to a call that can fail over to another account
+// when this one runs out of quota, so that the client returns a 429 right away instead of
+// waiting for the delay the upstream asked for.
+func WithQuotaFailover(ctx context.Context) context.Context {
+	return context.WithValue(ctx, quotaFailoverKey{}, true)
+}
+
+func hasQuotaFailover(ctx context.Context) bool {
+	failover, _ := ctx.Value(quotaFailoverKey{}).(bool)
+	return failover
+}
+
+// do sends an upstream request in a client span, retrying transient failures (transport
+// errors, 408 and 5xx) according to the retry policy of the method. A quota error (429) is
+// retried only when the upstream asked for a delay within the policy and the call cannot fail
+// over to another account (see WithQuotaFailover). It returns the response of a successful
+// call, or an *APIError with the body of the last failed attempt.
+func (c *Client) do(ctx context.Context, method string, httpReq *http.Request) (*http.Response, error) {
+	ctx, span := tracing.Start(ctx, "gemini."+method, tracing.KindClient,
+		tracing.String("rpc.method", method),
+		tracing.String("server.address", httpReq.URL.Host))
+	defer span.End()
+
+	var resp *http.Response
+	err := retry.Do(ctx, c.policy(method), func(attempt int) error {
+		if attempt > 1 {
+			span.AddEvent("retry", tracing.Int("attempt", attempt))
+			span.SetAttributes(tracing.Int("gcap.attempts", attempt))
+			if err := rewindBody(httpReq); err != nil {
+				return err
+			}
+		}
+
+		r, err := c.send(ctx, span, httpReq)
+		if err != nil {
+			return err
+		}
+		span.SetAttributes(tracing.Int("http.response.status_code", r.StatusCode))
+		if r.StatusCode == http.StatusOK {
+			resp = r
+			return nil
+		}
+
+		defer r.Body.Close()
+		respBody, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return fmt.Errorf("could not read response body: %w", err)
+		}
+		apiErr := newAPIError(method, r.StatusCode, r.Header, respBody)
+		if !isRetryableStatus(r.StatusCode) {
+			return apiErr
+		}
+		delay, hinted := apiErr.RetryDelay()
+		if apiErr.IsQuotaExhausted() && (!hinted || hasQuotaFailover(ctx)) {
+			return apiErr
+		}
+		return retry.Retryable(apiErr, delay)
+	})
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	return resp, nil
+}
+
+// send makes one attempt of an upstream request. On 401 Unauthorized it refreshes the token
+// and sends the request again; the refresh is traced as a child span of span.
+func (c *Client) send(ctx context.Context, span *tracing.Span, httpReq *http.Request) (*http.Response, error) {
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, transportError(ctx, fmt.Errorf("request execution error: %w", err))
+	}
+
+	// Check for 401 Unauthorized and attempt a token refresh
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close() // Close the first response body
+		span.AddEvent("unauthorized")
+
+		_, refreshSpan := tracing.Start(ctx, "gemini.refresh_token", tracing.KindInternal,
+			tracing.String("credentials.provider", c.provider.Name()))
+		err := c.provider.RefreshToken()
+		refreshSpan.RecordError(err)
+		refreshSpan.End()
+		if err != nil {
+			return nil, fmt.Errorf("failed to refresh token: %w", err)
+		}
+
+		// Reload credentials after refresh
+		refreshedCreds, err := c.provider.GetCredentials()
+		if err != nil {
+			return nil, fmt.Errorf("failed to reload credentials after refresh: %w", err)
+		}
+
+		// Re-create the request with the new token
+		if err := rewindBody(httpReq); err != nil {
+			return nil, err
+		}
+		httpReq.Header.Set("Authorization", "Bearer "+refreshedCreds.AccessToken)
+
+		// Retry the request
+		span.AddEvent("retry", tracing.String("reason", "token_refreshed"))
+		span.SetAttributes(tracing.Bool("gcap.retried_after_refresh", true))
+		resp, err = c.httpClient.Do(httpReq)
+		if err != nil {
+			return nil, transportError(ctx, fmt.Errorf("request execution error after refresh: %w", err))
+		}
 	}
+	return resp, nil
+}
+
+// policy returns the retry policy of a Code Assist method.
+func (c *Client) policy(method string) retry.Policy {
+	if p, ok := c.policies[method]; ok {
+		return p
+	}
+	return retry.Policy{MaxAttempts: 1}
+}
+
+// rewindBody resets the body of a request that was already sent.
+func rewindBody(httpReq *http.Request) error {
+	if httpReq.GetBody == nil {
+		return nil
+	}
+	body, err := httpReq.GetBody()
+	if err != nil {
+		return fmt.Errorf("could not rewind request body: %w", err)
+	}
+	httpReq.Body = body
+	return nil
+}
+
+// transportError marks a failure to reach the upstream as retryable, unless the request
+// itself was canceled.
+func transportError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return err
+	}
+	return retry.Retryable(err, 0)
+}
+
+// isRetryableStatus reports whether a status is worth retrying: timeouts, rate limits and
+// server errors.
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests,
+		http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
 }
 
 // LoadCodeAssist performs a request to the Gemini API to check if the credentials are valid.
@@ -63,33 +248,49 @@ func (c *Client) LoadCodeAssist() (*LoadCodeAssistResponse, error) {
 	req.Header.Set("x-goog-api-client", "gl-node/23.5.0")
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(context.Background(), "loadCodeAssist", req)
 	if err != nil {
-		return nil, fmt.Errorf("request execution error: %w", err)
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	// Check for 401 Unauthorized and attempt a token refresh
-	if resp.StatusCode == http.StatusUnauthorized {
-		resp.Body.Close() // Close the first response body
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read response body: %w", err)
+	}
 
-		if err := c.provider.RefreshToken(); err != nil {
-			return nil, fmt.Errorf("failed to refresh token: %w", err)
-		}
+	var result LoadCodeAssistResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("could not unmarshal response body: %w", err)
+	}
 
-		// Reload credentials after refresh
-		refreshedCreds, err := c.provider.GetCredentials()
-		if err != nil {
-			return nil, fmt.Errorf("failed to reload credentials after refresh: %w", err)
-		}
+	return &result, nil
+}
 
-		// Re-create the request with the new token
-		req.Header.Set("Authorization", "Bearer "+refreshedCreds.AccessToken)
+// OnboardUser performs a request to onboard the user to a tier. The response is a long running
+// operation; calling it again with the same request polls it until "done" is true.
+func (c *Client) OnboardUser(ctx context.Context, req interface{}) (map[string]interface{}, error) {
+	creds, err := c.provider.GetCredentials()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get credentials: %w", err)
+	}
 
-		// Retry the request
-		resp, err = c.httpClient.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("request execution error after refresh: %w", err)
-		}
+	bodyBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal request body: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/%s:onboardUser", credentials.CodeAssistEndpoint, credentials.CodeAssistAPIVersion), bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+
+	httpReq.Header.Set("Authorization", "Bearer "+creds.AccessToken)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.do(ctx, "onboardUser", httpReq)
+	if err != nil {
+		return nil, err
 	}
 	defer resp.Body.Close()
 
@@ -98,20 +299,16 @@ func (c *Client) LoadCodeAssist() (*LoadCodeAssistResponse, error) {
 		return nil, fmt.Errorf("could not read response body: %w", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("auth check failed with status %d: %s", resp.StatusCode, string(respBody))
-	}
-
-	var result LoadCodeAssistResponse
+	var result map[string]interface{}
 	if err := json.Unmarshal(respBody, &result); err != nil {
 		return nil, fmt.Errorf("could not unmarshal response body: %w", err)
 	}
 
-	return &result, nil
+	return result, nil
 }
 
 // GenerateContent performs a request to the Gemini API to generate content.
-func (c *Client) GenerateContent(req *GenerateContentRequest) (*GenerateContentResponse, error) {
+func (c *Client) GenerateContent(ctx context.Context, req *GenerateContentRequest) (*GenerateContentResponse, error) {
 	creds, err := c.provider.GetCredentials()
 	if err != nil {
 		return nil, fmt.Errorf("unable to get credentials: %w", err)
@@ -126,7 +323,7 @@ func (c *Client) GenerateContent(req *GenerateContentRequest) (*GenerateContentR
 		return nil, fmt.Errorf("could not marshal request body: %w", err)
 	}
 
-	httpReq, err := http.NewRequest("POST", fmt.Sprintf("%s/v1internal:generateContent", credentials.CodeAssistEndpoint), bytes.NewReader(bodyBytes))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/v1internal:generateContent", credentials.CodeAssistEndpoint), bytes.NewReader(bodyBytes))
 	if err != nil {
 		return nil, fmt.Errorf("could not create request: %w", err)
 	}
@@ -137,33 +334,55 @@ func (c *Client) GenerateContent(req *GenerateContentRequest) (*GenerateContentR
 	httpReq.Header.Set("x-goog-api-client", "gl-node/23.5.0")
 	httpReq.Header.Set("Accept", "application/json")
 
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(ctx, "generateContent", httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("request execution error: %w", err)
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	// Check for 401 Unauthorized and attempt a token refresh
-	if resp.StatusCode == http.StatusUnauthorized {
-		resp.Body.Close() // Close the first response body
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read response body: %w", err)
+	}
 
-		if err := c.provider.RefreshToken(); err != nil {
-			return nil, fmt.Errorf("failed to refresh token: %w", err)
-		}
+	var result GenerateContentResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("could not unmarshal response body: %w", err)
+	}
 
-		// Reload credentials after refresh
-		refreshedCreds, err := c.provider.GetCredentials()
-		if err != nil {
-			return nil, fmt.Errorf("failed to reload credentials after refresh: %w", err)
-		}
+	return &result, nil
+}
 
-		// Re-create the request with the new token
-		httpReq.Header.Set("Authorization", "Bearer "+refreshedCreds.AccessToken)
+// CountTokens performs a request to the Gemini API to count the tokens of the given contents.
+func (c *Client) CountTokens(ctx context.Context, req *CountTokensRequest) (*CountTokensResponse, error) {
+	creds, err := c.provider.GetCredentials()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get credentials: %w", err)
+	}
 
-		// Retry the request
-		resp, err = c.httpClient.Do(httpReq)
-		if err != nil {
-			return nil, fmt.Errorf("request execution error after refresh: %w", err)
-		}
+	if creds.AccessToken == "" {
+		return nil, fmt.Errorf("access token is empty")
+	}
+
+	bodyBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal request body: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/v1internal:countTokens", credentials.CodeAssistEndpoint), bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+
+	httpReq.Header.Set("Authorization", "Bearer "+creds.AccessToken)
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("User-Agent", "GeminiCLI/v23.5.0 (darwin; arm64) google-api-nodejs-client/9.15.1")
+	httpReq.Header.Set("x-goog-api-client", "gl-node/23.5.0")
+	httpReq.Header.Set("Accept", "application/json")
+
+	resp, err := c.do(ctx, "countTokens", httpReq)
+	if err != nil {
+		return nil, err
 	}
 	defer resp.Body.Close()
 
@@ -172,11 +391,7 @@ func (c *Client) GenerateContent(req *GenerateContentRequest) (*GenerateContentR
 		return nil, fmt.Errorf("could not read response body: %w", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("generateContent failed with status %d: %s", resp.StatusCode, string(respBody))
-	}
-
-	var result GenerateContentResponse
+	var result CountTokensResponse
 	if err := json.Unmarshal(respBody, &result); err != nil {
 		return nil, fmt.Errorf("could not unmarshal response body: %w", err)
 	}
@@ -213,60 +428,29 @@ func (c *Client) StreamGenerateContent(ctx context.Context, req *GenerateContent
 	httpReq.Header.Set("x-goog-api-client", "gl-node/23.5.0")
 	httpReq.Header.Set("Accept", "text/event-stream")
 
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(ctx, "streamGenerateContent", httpReq)
 	if err != nil {
-		return fmt.Errorf("request execution error: %w", err)
-	}
-
-	// Check for 401 Unauthorized and attempt a token refresh
-	if resp.StatusCode == http.StatusUnauthorized {
-		resp.Body.Close() // Close the first response body
-
-		if err := c.provider.RefreshToken(); err != nil {
-			return fmt.Errorf("failed to refresh token: %w", err)
-		}
-
-		// Reload credentials after refresh
-		refreshedCreds, err := c.provider.GetCredentials()
-		if err != nil {
-			return fmt.Errorf("failed to reload credentials after refresh: %w", err)
-		}
-
-		// Re-create the request with the new token
-		httpReq.Header.Set("Authorization", "Bearer "+refreshedCreds.AccessToken)
-
-		// Retry the request
-		resp, err = c.httpClient.Do(httpReq)
-		if err != nil {
-			return fmt.Errorf("request execution error after refresh: %w", err)
-		}
-	}
-
-	// Non-OK status: read body and return error (with concise debug logs)
-	if resp.StatusCode != http.StatusOK {
-		defer resp.Body.Close()
-		respBody, readErr := ioutil.ReadAll(resp.Body)
-		if readErr != nil {
-			return fmt.Errorf("streamGenerateContent failed with status %d and read error: %v", resp.StatusCode, readErr)
-		}
-		// Log truncated previews to avoid flooding logs
-		const maxPreview = 1024
-		rprev := string(respBody)
-		if len(rprev) > maxPreview {
-			rprev = rprev[:maxPreview] + "..."
-		}
-		qprev := string(bodyBytes)
-		if len(qprev) > maxPreview {
-			qprev = qprev[:maxPreview] + "..."
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			// Log truncated previews to avoid flooding logs
+			const maxPreview = 1024
+			rprev := string(apiErr.Body)
+			if len(rprev) > maxPreview {
+				rprev = rprev[:maxPreview] + "..."
+			}
+			qprev := string(bodyBytes)
+			if len(qprev) > maxPreview {
+				qprev = qprev[:maxPreview] + "..."
+			}
+			logger.Get().Error().
+				Int("status", apiErr.StatusCode).
+				Int("response_body_len", len(apiErr.Body)).
+				Str("response_body_preview", rprev).
+				Int("request_body_len", len(bodyBytes)).
+				Str("request_body_preview", qprev).
+				Msg("Upstream error on streamGenerateContent")
 		}
-		logger.Get().Error().
-			Int("status", resp.StatusCode).
-			Int("response_body_len", len(respBody)).
-			Str("response_body_preview", rprev).
-			Int("request_body_len", len(bodyBytes)).
-			Str("request_body_preview", qprev).
-			Msg("Upstream error on streamGenerateContent")
-		return fmt.Errorf("streamGenerateContent failed with status %d: %s", resp.StatusCode, string(respBody))
+		return err
 	}
 
 	// Start a goroutine to stream lines to the provided channel.
@@ -0,0 +1,196 @@
+package gemini
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/credentials"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/retry"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeProvider struct {
+	token     string
+	refreshes int
+}
+
+func (p *fakeProvider) GetCredentials() (*credentials.OAuthCredentials, error) {
+	return &credentials.OAuthCredentials{AccessToken: p.token}, nil
+}
+
+func (p *fakeProvider) SaveCredentials(*credentials.OAuthCredentials) error { return nil }
+
+func (p *fakeProvider) RefreshToken() error {
+	p.refreshes++
+	p.token = "refreshed"
+	return nil
+}
+
+func (p *fakeProvider) Name() string { return "fake" }
+
+// fakeHTTPClient answers with the given statuses in order and records the requests it saw.
+// Error responses carry errorBody.
+type fakeHTTPClient struct {
+	statuses    []int
+	errorBody   string
+	errorHeader http.Header
+	auth        []string
+	bodies      []string
+}
+
+func (c *fakeHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	body, _ := io.ReadAll(req.Body)
+	req.Body.Close()
+	c.auth = append(c.auth, req.Header.Get("Authorization"))
+	c.bodies = append(c.bodies, string(body))
+
+	status := c.statuses[0]
+	c.statuses = c.statuses[1:]
+	respBody, header := `{"response":{"candidates":[]}}`, http.Header{}
+	if status != http.StatusOK {
+		respBody = c.errorBody
+		if c.errorHeader != nil {
+			header = c.errorHeader
+		}
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     header,
+		Body:       io.NopCloser(bytes.NewBufferString(respBody)),
+	}, nil
+}
+
+type countingExporter struct {
+	mu    sync.Mutex
+	spans int
+}
+
+func (e *countingExporter) Export(ctx context.Context, spans []*tracing.Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans += len(spans)
+	return nil
+}
+
+func TestClient_RetriesAfterTokenRefresh(t *testing.T) {
+	exporter := &countingExporter{}
+	tracer := tracing.NewTracer(exporter, tracing.Config{SampleRatio: 1, FlushInterval: time.Hour})
+	tracing.SetTracer(tracer)
+	defer tracing.SetTracer(nil)
+
+	provider := &fakeProvider{token: "expired"}
+	httpClient := &fakeHTTPClient{statuses: []int{http.StatusUnauthorized, http.StatusOK}}
+	client := &Client{httpClient: httpClient, provider: provider}
+
+	_, err := client.GenerateContent(context.Background(), &GenerateContentRequest{Model: "gemini-2.5-pro"})
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, provider.refreshes)
+	assert.Equal(t, []string{"Bearer expired", "Bearer refreshed"}, httpClient.auth)
+	require.Len(t, httpClient.bodies, 2)
+	assert.NotEmpty(t, httpClient.bodies[1], "the retry must resend the request body")
+	assert.Equal(t, httpClient.bodies[0], httpClient.bodies[1])
+
+	// The upstream call and the token refresh
+	require.NoError(t, tracer.Shutdown(context.Background()))
+	assert.Equal(t, 2, exporter.spans)
+}
+
+func TestClient_RetryPolicy(t *testing.T) {
+	fast := retry.Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second}
+
+	tests := []struct {
+		name        string
+		statuses    []int
+		errorBody   string
+		errorHeader http.Header
+		failover    bool // whether the account pool can fail over to another account
+		wantErr     int  // status of the returned APIError, 0 for success
+		attempts    int
+	}{
+		{name: "retries 503", statuses: []int{503, 503, 200}, attempts: 3},
+		{name: "gives up after max attempts", statuses: []int{503, 503, 503}, wantErr: 503, attempts: 3},
+		{name: "does not retry 400", statuses: []int{400}, wantErr: 400, attempts: 1},
+		{
+			name:      "honors a short RetryInfo",
+			statuses:  []int{503, 200},
+			errorBody: `{"error":{"code":503,"status":"UNAVAILABLE","details":[{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"0.01s"}]}}`,
+			attempts:  2,
+		},
+		{
+			name:      "gives up on a long RetryInfo",
+			statuses:  []int{503},
+			errorBody: `{"error":{"code":503,"status":"UNAVAILABLE","details":[{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"3600s"}]}}`,
+			wantErr:   503,
+			attempts:  1,
+		},
+		{
+			name:      "does not retry quota errors without a retry delay",
+			statuses:  []int{429},
+			errorBody: `{"error":{"code":429,"message":"Resource has been exhausted","status":"RESOURCE_EXHAUSTED"}}`,
+			wantErr:   429,
+			attempts:  1,
+		},
+		{
+			name:      "retries quota errors with a short RetryInfo",
+			statuses:  []int{429, 200},
+			errorBody: `{"error":{"code":429,"status":"RESOURCE_EXHAUSTED","details":[{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"0.01s"}]}}`,
+			attempts:  2,
+		},
+		{
+			name:        "retries quota errors with a short Retry-After",
+			statuses:    []int{429, 200},
+			errorBody:   `{"error":{"code":429,"message":"Resource has been exhausted","status":"RESOURCE_EXHAUSTED"}}`,
+			errorHeader: http.Header{"Retry-After": []string{"0"}},
+			attempts:    2,
+		},
+		{
+			name:      "gives up on quota errors with a long RetryInfo",
+			statuses:  []int{429},
+			errorBody: `{"error":{"code":429,"status":"RESOURCE_EXHAUSTED","details":[{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"3600s"}]}}`,
+			wantErr:   429,
+			attempts:  1,
+		},
+		{
+			name:      "leaves quota errors to the account pool when it can fail over",
+			statuses:  []int{429},
+			errorBody: `{"error":{"code":429,"status":"RESOURCE_EXHAUSTED","details":[{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"0.01s"}]}}`,
+			failover:  true,
+			wantErr:   429,
+			attempts:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpClient := &fakeHTTPClient{statuses: tt.statuses, errorBody: tt.errorBody, errorHeader: tt.errorHeader}
+			client := &Client{
+				httpClient: httpClient,
+				provider:   &fakeProvider{token: "token"},
+				policies:   map[string]retry.Policy{"generateContent": fast},
+			}
+
+			ctx := context.Background()
+			if tt.failover {
+				ctx = WithQuotaFailover(ctx)
+			}
+			_, err := client.GenerateContent(ctx, &GenerateContentRequest{Model: "gemini-2.5-pro"})
+			assert.Len(t, httpClient.bodies, tt.attempts)
+			if tt.wantErr == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			var apiErr *APIError
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, tt.wantErr, apiErr.StatusCode)
+			assert.Equal(t, tt.errorBody, string(apiErr.Body))
+		})
+	}
+}
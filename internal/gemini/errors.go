@@ -0,0 +1,115 @@
+package gemini
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/metrics"
+)
+
+// APIError is returned when a Code Assist endpoint answers with a non-200 status.
+type APIError struct {
+	// Method is the Code Assist method that failed, e.g. "generateContent"
+	Method     string
+	StatusCode int
+	// Header holds the response headers, e.g. Retry-After
+	Header http.Header
+	Body   []byte
+}
+
+// newAPIError builds the error of a failed call and counts it in the upstream error metrics.
+func newAPIError(method string, statusCode int, header http.Header, body []byte) *APIError {
+	metrics.UpstreamErrors.Inc(method, strconv.Itoa(statusCode))
+	return &APIError{Method: method, StatusCode: statusCode, Header: header, Body: body}
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s failed with status %d: %s", e.Method, e.StatusCode, string(e.Body))
+}
+
+// IsQuotaExhausted reports whether the error is a 429 (RESOURCE_EXHAUSTED) for the account.
+func (e *APIError) IsQuotaExhausted() bool {
+	return e.StatusCode == http.StatusTooManyRequests
+}
+
+// GoogleStatus is the error body Google APIs return (google.rpc.Status).
+type GoogleStatus struct {
+	Code    int               `json:"code"`
+	Message string            `json:"message"`
+	Status  string            `json:"status"`
+	Details []json.RawMessage `json:"details,omitempty"`
+}
+
+// googleErrorDetail holds the fields of the google.rpc details the proxy reads.
+type googleErrorDetail struct {
+	Type       string            `json:"@type"`
+	RetryDelay string            `json:"retryDelay"`
+	Metadata   map[string]string `json:"metadata"`
+}
+
+// GoogleStatus parses the error body. Code Assist sometimes wraps it in a JSON array.
+func (e *APIError) GoogleStatus() (*GoogleStatus, bool) {
+	type envelope struct {
+		Error *GoogleStatus `json:"error"`
+	}
+	var single envelope
+	if err := json.Unmarshal(e.Body, &single); err == nil && single.Error != nil {
+		return single.Error, true
+	}
+	var list []envelope
+	if err := json.Unmarshal(e.Body, &list); err == nil && len(list) > 0 && list[0].Error != nil {
+		return list[0].Error, true
+	}
+	return nil, false
+}
+
+// RetryDelay returns how long the upstream asked to wait before retrying, taken from a
+// google.rpc.RetryInfo detail, the quotaResetDelay of a google.rpc.ErrorInfo detail or the
+// Retry-After header.
+func (e *APIError) RetryDelay() (time.Duration, bool) {
+	gs, ok := e.GoogleStatus()
+	if !ok {
+		return e.retryAfter()
+	}
+	details := make([]googleErrorDetail, 0, len(gs.Details))
+	for _, raw := range gs.Details {
+		var d googleErrorDetail
+		if err := json.Unmarshal(raw, &d); err == nil {
+			details = append(details, d)
+		}
+	}
+	for _, d := range details {
+		if strings.HasSuffix(d.Type, "google.rpc.RetryInfo") && d.RetryDelay != "" {
+			if delay, err := time.ParseDuration(d.RetryDelay); err == nil {
+				return delay, true
+			}
+		}
+	}
+	for _, d := range details {
+		if strings.HasSuffix(d.Type, "google.rpc.ErrorInfo") && d.Metadata["quotaResetDelay"] != "" {
+			if delay, err := time.ParseDuration(d.Metadata["quotaResetDelay"]); err == nil {
+				return delay, true
+			}
+		}
+	}
+	return e.retryAfter()
+}
+
+// retryAfter parses the Retry-After header, given either in seconds or as an HTTP date.
+func (e *APIError) retryAfter() (time.Duration, bool) {
+	value := e.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,70 @@
+package gemini
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIError_RetryDelay(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		header http.Header
+		delay  time.Duration
+		ok     bool
+	}{
+		{
+			name:  "retry info",
+			body:  `{"error":{"code":429,"status":"RESOURCE_EXHAUSTED","details":[{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"12.5s"}]}}`,
+			delay: 12500 * time.Millisecond,
+			ok:    true,
+		},
+		{
+			name:  "quota reset delay in error info",
+			body:  `[{"error":{"code":429,"status":"RESOURCE_EXHAUSTED","details":[{"@type":"type.googleapis.com/google.rpc.ErrorInfo","reason":"QUOTA_EXHAUSTED","metadata":{"quotaResetDelay":"1h2m3s"}}]}}]`,
+			delay: time.Hour + 2*time.Minute + 3*time.Second,
+			ok:    true,
+		},
+		{
+			name: "no hint",
+			body: `{"error":{"code":429,"status":"RESOURCE_EXHAUSTED"}}`,
+		},
+		{
+			name: "not json",
+			body: `Too Many Requests`,
+		},
+		{
+			name:   "retry-after header",
+			body:   `Too Many Requests`,
+			header: http.Header{"Retry-After": []string{"7"}},
+			delay:  7 * time.Second,
+			ok:     true,
+		},
+		{
+			name:   "retry info before retry-after header",
+			body:   `{"error":{"code":429,"status":"RESOURCE_EXHAUSTED","details":[{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"2s"}]}}`,
+			header: http.Header{"Retry-After": []string{"7"}},
+			delay:  2 * time.Second,
+			ok:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := &APIError{Method: "generateContent", StatusCode: 429, Header: tt.header, Body: []byte(tt.body)}
+			delay, ok := err.RetryDelay()
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.delay, delay)
+			assert.True(t, err.IsQuotaExhausted())
+		})
+	}
+}
+
+func TestAPIError_Error(t *testing.T) {
+	err := &APIError{Method: "generateContent", StatusCode: 400, Body: []byte("bad")}
+	assert.EqualError(t, err, "generateContent failed with status 400: bad")
+	assert.False(t, err.IsQuotaExhausted())
+}
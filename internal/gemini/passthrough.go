@@ -0,0 +1,130 @@
+package gemini
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// The native /v1beta path forwards client payloads to CloudCode through the typed
+// request structs in this package. To stay transparent, every struct that a client
+// can send carries an Extra map holding the JSON keys it does not model; those keys
+// are written back verbatim on marshal. Only the fields the proxy explicitly rewrites
+// are typed.
+//
+// Typed fields tagged omitempty would also lose empty values the client sent on purpose
+// ("args": {}, "properties": {}, "includeThoughts": false), so known keys with an empty
+// value are kept in Extra as well. Typed fields always win on marshal, so rewriting such
+// a field still takes effect.
+
+// knownFieldsCache maps a struct type to the lower-cased JSON names of its fields.
+var knownFieldsCache sync.Map
+
+// knownJSONFields returns the lower-cased JSON keys that encoding/json maps onto t.
+// Keys are lower-cased because encoding/json matches object keys case-insensitively.
+func knownJSONFields(t reflect.Type) map[string]bool {
+	if cached, ok := knownFieldsCache.Load(t); ok {
+		return cached.(map[string]bool)
+	}
+
+	fields := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+		fields[strings.ToLower(name)] = true
+	}
+
+	knownFieldsCache.Store(t, fields)
+	return fields
+}
+
+// unmarshalWithExtra decodes b into v (a pointer to a struct) and returns the keys of b
+// that v does not declare. consumed lists additional keys that the caller handles
+// itself (e.g. snake_case aliases) and that must therefore not be re-emitted.
+func unmarshalWithExtra(b []byte, v interface{}, consumed ...string) (map[string]json.RawMessage, error) {
+	if err := json.Unmarshal(b, v); err != nil {
+		return nil, err
+	}
+	return extraFields(b, reflect.TypeOf(v).Elem(), consumed...)
+}
+
+// extraFields returns the keys of the JSON object b that are neither fields of t nor consumed.
+func extraFields(b []byte, t reflect.Type, consumed ...string) (map[string]json.RawMessage, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+
+	known := knownJSONFields(t)
+	for k, v := range raw {
+		if known[strings.ToLower(k)] {
+			if !isEmptyJSON(v) {
+				delete(raw, k)
+			}
+			continue
+		}
+		for _, c := range consumed {
+			if k == c {
+				delete(raw, k)
+				break
+			}
+		}
+	}
+
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	return raw, nil
+}
+
+// isEmptyJSON reports whether v is {}, [], "", false or null, i.e. a value omitempty would drop.
+// Zero numbers are not included; numeric fields that must keep an explicit 0 are pointers.
+func isEmptyJSON(v json.RawMessage) bool {
+	v = bytes.TrimSpace(v)
+	if len(v) < 2 {
+		return false
+	}
+	switch v[0] {
+	case '{', '[':
+		return len(bytes.TrimSpace(v[1:len(v)-1])) == 0
+	case '"':
+		return len(v) == 2
+	case 'f', 'n':
+		return string(v) == "false" || string(v) == "null"
+	}
+	return false
+}
+
+// marshalWithExtra marshals v and merges the extra keys into the resulting object.
+// Typed fields win over extra keys of the same name.
+func marshalWithExtra(v interface{}, extra map[string]json.RawMessage) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return b, err
+	}
+
+	var merged map[string]json.RawMessage
+	if err := json.Unmarshal(b, &merged); err != nil {
+		return nil, err
+	}
+	for k, val := range extra {
+		if _, exists := merged[k]; !exists {
+			merged[k] = val
+		}
+	}
+	return json.Marshal(merged)
+}
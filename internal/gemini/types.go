@@ -2,48 +2,233 @@ package gemini
 
 import (
 	"encoding/json"
+	"reflect"
 )
 
 // ContentPart represents a single part of a content message.
+// Part kinds the proxy does not rewrite (thought, executableCode, ...) are kept in Extra.
 type ContentPart struct {
 	Text             string            `json:"text,omitempty"`
 	ThoughtSignature string            `json:"thoughtSignature,omitempty"`
 	FunctionCall     *FunctionCall     `json:"functionCall,omitempty"`
 	FunctionResponse *FunctionResponse `json:"functionResponse,omitempty"`
+	InlineData       *Blob             `json:"inlineData,omitempty"`
+	FileData         *FileData         `json:"fileData,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+func (p *ContentPart) UnmarshalJSON(b []byte) error {
+	type alias ContentPart
+	var a alias
+	extra, err := unmarshalWithExtra(b, &a)
+	if err != nil {
+		return err
+	}
+	*p = ContentPart(a)
+	p.Extra = extra
+	return nil
+}
+
+func (p ContentPart) MarshalJSON() ([]byte, error) {
+	type alias ContentPart
+	return marshalWithExtra(alias(p), p.Extra)
+}
+
+// Blob is inline binary data (images, audio, PDFs) sent as base64.
+type Blob struct {
+	MimeType string `json:"mimeType,omitempty"`
+	Data     string `json:"data,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+func (b *Blob) UnmarshalJSON(data []byte) error {
+	type alias Blob
+	var a alias
+	extra, err := unmarshalWithExtra(data, &a)
+	if err != nil {
+		return err
+	}
+	*b = Blob(a)
+	b.Extra = extra
+	return nil
+}
+
+func (b Blob) MarshalJSON() ([]byte, error) {
+	type alias Blob
+	return marshalWithExtra(alias(b), b.Extra)
+}
+
+// FileData references media by URI instead of embedding it.
+type FileData struct {
+	MimeType string `json:"mimeType,omitempty"`
+	FileURI  string `json:"fileUri,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+func (f *FileData) UnmarshalJSON(b []byte) error {
+	type alias FileData
+	var a alias
+	extra, err := unmarshalWithExtra(b, &a)
+	if err != nil {
+		return err
+	}
+	*f = FileData(a)
+	f.Extra = extra
+	return nil
+}
+
+func (f FileData) MarshalJSON() ([]byte, error) {
+	type alias FileData
+	return marshalWithExtra(alias(f), f.Extra)
 }
 
 // Content represents a single message in the chat history for Gemini.
 type Content struct {
 	Role  string        `json:"role,omitempty"`
 	Parts []ContentPart `json:"parts,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+func (c *Content) UnmarshalJSON(b []byte) error {
+	type alias Content
+	var a alias
+	extra, err := unmarshalWithExtra(b, &a)
+	if err != nil {
+		return err
+	}
+	*c = Content(a)
+	c.Extra = extra
+	return nil
+}
+
+func (c Content) MarshalJSON() ([]byte, error) {
+	type alias Content
+	return marshalWithExtra(alias(c), c.Extra)
 }
 
 // SystemInstruction defines the system-level instructions for the model.
 type SystemInstruction struct {
 	Role  string        `json:"role,omitempty"`
 	Parts []ContentPart `json:"parts,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+func (si *SystemInstruction) UnmarshalJSON(b []byte) error {
+	type alias SystemInstruction
+	var a alias
+	extra, err := unmarshalWithExtra(b, &a)
+	if err != nil {
+		return err
+	}
+	*si = SystemInstruction(a)
+	si.Extra = extra
+	return nil
+}
+
+func (si SystemInstruction) MarshalJSON() ([]byte, error) {
+	type alias SystemInstruction
+	return marshalWithExtra(alias(si), si.Extra)
 }
 
 // GeminiParameterSchema defines the proprietary schema format for Gemini function parameters.
+// It is the OpenAPI subset Gemini accepts; other schema keywords are kept in Extra.
 type GeminiParameterSchema struct {
 	Type        string                            `json:"type,omitempty"`
+	Format      string                            `json:"format,omitempty"`
+	Title       string                            `json:"title,omitempty"`
 	Description string                            `json:"description,omitempty"`
+	Nullable    bool                              `json:"nullable,omitempty"`
 	Properties  map[string]*GeminiParameterSchema `json:"properties,omitempty"`
 	Items       *GeminiParameterSchema            `json:"items,omitempty"`
 	Required    []string                          `json:"required,omitempty"`
 	Enum        []string                          `json:"enum,omitempty"`
+	AnyOf       []*GeminiParameterSchema          `json:"anyOf,omitempty"`
+	Default     interface{}                       `json:"default,omitempty"`
+
+	// Numeric, string, array and object bounds
+	Minimum       *float64 `json:"minimum,omitempty"`
+	Maximum       *float64 `json:"maximum,omitempty"`
+	MinLength     *int64   `json:"minLength,omitempty"`
+	MaxLength     *int64   `json:"maxLength,omitempty"`
+	Pattern       string   `json:"pattern,omitempty"`
+	MinItems      *int64   `json:"minItems,omitempty"`
+	MaxItems      *int64   `json:"maxItems,omitempty"`
+	MinProperties *int64   `json:"minProperties,omitempty"`
+	MaxProperties *int64   `json:"maxProperties,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+func (s *GeminiParameterSchema) UnmarshalJSON(b []byte) error {
+	type alias GeminiParameterSchema
+	var a alias
+	extra, err := unmarshalWithExtra(b, &a)
+	if err != nil {
+		return err
+	}
+	*s = GeminiParameterSchema(a)
+	s.Extra = extra
+	return nil
+}
+
+func (s GeminiParameterSchema) MarshalJSON() ([]byte, error) {
+	type alias GeminiParameterSchema
+	return marshalWithExtra(alias(s), s.Extra)
 }
 
 // FunctionCall represents a tool call emitted by the model.
 type FunctionCall struct {
 	Name string                 `json:"name,omitempty"`
 	Args map[string]interface{} `json:"args,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+func (fc *FunctionCall) UnmarshalJSON(b []byte) error {
+	type alias FunctionCall
+	var a alias
+	extra, err := unmarshalWithExtra(b, &a)
+	if err != nil {
+		return err
+	}
+	*fc = FunctionCall(a)
+	fc.Extra = extra
+	return nil
+}
+
+func (fc FunctionCall) MarshalJSON() ([]byte, error) {
+	type alias FunctionCall
+	return marshalWithExtra(alias(fc), fc.Extra)
 }
 
 // FunctionResponse represents the tool result returned by the client.
 type FunctionResponse struct {
 	Name     string                 `json:"name,omitempty"`
 	Response map[string]interface{} `json:"response,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+func (fr *FunctionResponse) UnmarshalJSON(b []byte) error {
+	type alias FunctionResponse
+	var a alias
+	extra, err := unmarshalWithExtra(b, &a)
+	if err != nil {
+		return err
+	}
+	*fr = FunctionResponse(a)
+	fr.Extra = extra
+	return nil
+}
+
+func (fr FunctionResponse) MarshalJSON() ([]byte, error) {
+	type alias FunctionResponse
+	return marshalWithExtra(alias(fr), fr.Extra)
 }
 
 // FunctionDeclaration defines a function that can be called by the model.
@@ -51,51 +236,63 @@ type FunctionDeclaration struct {
 	Name        string                 `json:"name,omitempty"`
 	Description string                 `json:"description,omitempty"`
 	Parameters  *GeminiParameterSchema `json:"parameters,omitempty"`
+	// ParametersJSONSchema is a full JSON Schema ($ref, $defs, oneOf, ...). Code Assist only
+	// accepts those keywords here, so it is forwarded as sent instead of becoming Parameters.
+	ParametersJSONSchema json.RawMessage `json:"parametersJsonSchema,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
 }
 
-// UnmarshalJSON: accept parametersJsonSchema (camelCase) and parameters (snake_case).
-// Keeps compatibility with public v1beta tool schemas.
+// UnmarshalJSON: accept parametersJsonSchema (JSON Schema) and parameters (OpenAPI subset).
+// A parameters value that doesn't fit the schema type is forwarded untouched.
 func (f *FunctionDeclaration) UnmarshalJSON(b []byte) error {
-	// Attempt direct unmarshal for camelCase first
-	type alias FunctionDeclaration
-	var a alias
-	if err := json.Unmarshal(b, &a); err == nil {
-		*f = FunctionDeclaration(a)
-		if f.Parameters != nil {
-			return nil
-		}
-	}
 	var raw map[string]json.RawMessage
 	if err := json.Unmarshal(b, &raw); err != nil {
 		return err
 	}
+	*f = FunctionDeclaration{}
 	if v, ok := raw["name"]; ok {
 		_ = json.Unmarshal(v, &f.Name)
 	}
 	if v, ok := raw["description"]; ok {
 		_ = json.Unmarshal(v, &f.Description)
 	}
-	// snake_case key used by generativelanguage public API
 	if v, ok := raw["parameters"]; ok {
 		var schema GeminiParameterSchema
 		if err := json.Unmarshal(v, &schema); err == nil {
 			f.Parameters = &schema
-			return nil
 		}
 	}
-	// or explicit camelCase if present but empty earlier
-	if v, ok := raw["parametersJsonSchema"]; ok && f.Parameters == nil {
-		var schema GeminiParameterSchema
-		if err := json.Unmarshal(v, &schema); err == nil {
-			f.Parameters = &schema
+	if v, ok := raw["parametersJsonSchema"]; ok && !isEmptyJSON(v) {
+		f.ParametersJSONSchema = v
+	}
+
+	type alias FunctionDeclaration
+	extra, err := extraFields(b, reflect.TypeOf(alias{}))
+	if err != nil {
+		return err
+	}
+	if f.Parameters == nil && raw["parameters"] != nil {
+		if extra == nil {
+			extra = map[string]json.RawMessage{}
 		}
+		extra["parameters"] = raw["parameters"]
 	}
+	f.Extra = extra
 	return nil
 }
 
+func (f FunctionDeclaration) MarshalJSON() ([]byte, error) {
+	type alias FunctionDeclaration
+	return marshalWithExtra(alias(f), f.Extra)
+}
+
 // Tool represents a collection of function declarations.
+// Other tool kinds (googleSearch, codeExecution, urlContext, ...) are kept in Extra.
 type Tool struct {
 	FunctionDeclarations []FunctionDeclaration `json:"functionDeclarations,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
 }
 
 // UnmarshalJSON: accept functionDeclarations (camelCase) and function_declarations (snake_case).
@@ -104,8 +301,9 @@ func (t *Tool) UnmarshalJSON(b []byte) error {
 	// Try camelCase first
 	type alias Tool
 	var a alias
-	if err := json.Unmarshal(b, &a); err == nil && len(a.FunctionDeclarations) > 0 {
+	if extra, err := unmarshalWithExtra(b, &a, "function_declarations"); err == nil && len(a.FunctionDeclarations) > 0 {
 		*t = Tool(a)
+		t.Extra = extra
 		return nil
 	}
 	// Fallback to snake_case key function_declarations
@@ -113,6 +311,11 @@ func (t *Tool) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &raw); err != nil {
 		return err
 	}
+	extra, err := extraFields(b, reflect.TypeOf(a), "function_declarations")
+	if err != nil {
+		return err
+	}
+	t.Extra = extra
 	if fdRaw, ok := raw["function_declarations"]; ok {
 		var arr []json.RawMessage
 		if err := json.Unmarshal(fdRaw, &arr); err != nil {
@@ -134,6 +337,62 @@ func (t *Tool) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+func (t Tool) MarshalJSON() ([]byte, error) {
+	type alias Tool
+	return marshalWithExtra(alias(t), t.Extra)
+}
+
+// FunctionCallingConfig controls how the model may call the declared functions.
+//
+// Mode is one of "AUTO", "ANY", "NONE" (or "VALIDATED" on newer models).
+type FunctionCallingConfig struct {
+	Mode                 string   `json:"mode,omitempty"`
+	AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+func (c *FunctionCallingConfig) UnmarshalJSON(b []byte) error {
+	type alias FunctionCallingConfig
+	var a alias
+	extra, err := unmarshalWithExtra(b, &a)
+	if err != nil {
+		return err
+	}
+	*c = FunctionCallingConfig(a)
+	c.Extra = extra
+	return nil
+}
+
+func (c FunctionCallingConfig) MarshalJSON() ([]byte, error) {
+	type alias FunctionCallingConfig
+	return marshalWithExtra(alias(c), c.Extra)
+}
+
+// ToolConfig carries per-request tool settings.
+type ToolConfig struct {
+	FunctionCallingConfig *FunctionCallingConfig `json:"functionCallingConfig,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+func (c *ToolConfig) UnmarshalJSON(b []byte) error {
+	type alias ToolConfig
+	var a alias
+	extra, err := unmarshalWithExtra(b, &a)
+	if err != nil {
+		return err
+	}
+	*c = ToolConfig(a)
+	c.Extra = extra
+	return nil
+}
+
+func (c ToolConfig) MarshalJSON() ([]byte, error) {
+	type alias ToolConfig
+	return marshalWithExtra(alias(c), c.Extra)
+}
+
 // ThinkingConfig configures the model's thinking process.
 //
 // Gemini 3 uses thinkingLevel ("low"/"high"). Gemini 2.5 uses thinkingBudget.
@@ -142,14 +401,68 @@ type ThinkingConfig struct {
 	IncludeThoughts bool   `json:"includeThoughts,omitempty"`
 	ThinkingLevel   string `json:"thinkingLevel,omitempty"`
 	ThinkingBudget  *int   `json:"thinkingBudget,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+func (c *ThinkingConfig) UnmarshalJSON(b []byte) error {
+	type alias ThinkingConfig
+	var a alias
+	extra, err := unmarshalWithExtra(b, &a)
+	if err != nil {
+		return err
+	}
+	*c = ThinkingConfig(a)
+	c.Extra = extra
+	return nil
+}
+
+func (c ThinkingConfig) MarshalJSON() ([]byte, error) {
+	type alias ThinkingConfig
+	return marshalWithExtra(alias(c), c.Extra)
 }
 
 // GeminiGenerationConfig configures the generation process.
+// Temperature and TopP are pointers so that an explicit 0 is forwarded.
+// Settings without a typed field (seed, responseModalities, ...) are kept in Extra.
+//
+// Structured output uses ResponseMimeType "application/json" together with either
+// ResponseSchema (Gemini's OpenAPI subset) or ResponseJsonSchema (plain JSON Schema).
 type GeminiGenerationConfig struct {
-	Temperature     float64         `json:"temperature,omitempty"`
-	TopP            float64         `json:"topP,omitempty"`
-	ThinkingConfig  *ThinkingConfig `json:"thinkingConfig,omitempty"`
-	MaxOutputTokens int             `json:"maxOutputTokens,omitempty"`
+	Temperature        *float64               `json:"temperature,omitempty"`
+	TopP               *float64               `json:"topP,omitempty"`
+	TopK               int                    `json:"topK,omitempty"`
+	StopSequences      []string               `json:"stopSequences,omitempty"`
+	CandidateCount     int                    `json:"candidateCount,omitempty"`
+	Seed               *int                   `json:"seed,omitempty"`
+	PresencePenalty    *float64               `json:"presencePenalty,omitempty"`
+	FrequencyPenalty   *float64               `json:"frequencyPenalty,omitempty"`
+	ResponseLogprobs   bool                   `json:"responseLogprobs,omitempty"`
+	Logprobs           *int                   `json:"logprobs,omitempty"`
+	ThinkingConfig     *ThinkingConfig        `json:"thinkingConfig,omitempty"`
+	MaxOutputTokens    int                    `json:"maxOutputTokens,omitempty"`
+	ResponseMimeType   string                 `json:"responseMimeType,omitempty"`
+	ResponseSchema     *GeminiParameterSchema `json:"responseSchema,omitempty"`
+	ResponseJsonSchema interface{}            `json:"responseJsonSchema,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+func (c *GeminiGenerationConfig) UnmarshalJSON(b []byte) error {
+	type alias GeminiGenerationConfig
+	var a alias
+	extra, err := unmarshalWithExtra(b, &a)
+	if err != nil {
+		return err
+	}
+	*c = GeminiGenerationConfig(a)
+	c.Extra = extra
+	return nil
+}
+
+func (c GeminiGenerationConfig) MarshalJSON() ([]byte, error) {
+	type alias GeminiGenerationConfig
+	return marshalWithExtra(alias(c), c.Extra)
 }
 
 // LoadCodeAssistRequest represents the request body for the loadCodeAssist endpoint.
@@ -192,12 +505,18 @@ type GenerateContentRequest struct {
 	SessionID    string                `json:"session_id,omitempty"`
 }
 
+// GeminiInternalRequest is the request body inside the CloudCode envelope.
+// Top-level fields the proxy does not rewrite (safetySettings, cachedContent, labels, ...)
+// are kept in Extra and forwarded as-is.
 type GeminiInternalRequest struct {
 	Contents          []Content               `json:"contents,omitempty"`
 	SystemInstruction *SystemInstruction      `json:"systemInstruction,omitempty"`
 	Tools             []Tool                  `json:"tools,omitempty"`
+	ToolConfig        *ToolConfig             `json:"toolConfig,omitempty"`
 	GenerationConfig  *GeminiGenerationConfig `json:"generationConfig,omitempty"`
 	SessionID         string                  `json:"session_id,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
 }
 
 // UnmarshalJSON: accept tools as array or single object (v1beta shape).
@@ -208,16 +527,22 @@ func (g *GeminiInternalRequest) UnmarshalJSON(b []byte) error {
 		Contents          []Content               `json:"contents"`
 		SystemInstruction *SystemInstruction      `json:"systemInstruction"`
 		Tools             json.RawMessage         `json:"tools"`
+		ToolConfig        *ToolConfig             `json:"toolConfig"`
 		GenerationConfig  *GeminiGenerationConfig `json:"generationConfig"`
+		SessionID         string                  `json:"session_id"`
 	}
 
-	if err := json.Unmarshal(b, &raw); err != nil {
+	extra, err := unmarshalWithExtra(b, &raw)
+	if err != nil {
 		return err
 	}
 
 	g.Contents = raw.Contents
 	g.SystemInstruction = raw.SystemInstruction
+	g.ToolConfig = raw.ToolConfig
 	g.GenerationConfig = raw.GenerationConfig
+	g.SessionID = raw.SessionID
+	g.Extra = extra
 
 	// If tools is absent or null, we're done
 	if len(raw.Tools) == 0 || string(raw.Tools) == "null" {
@@ -247,9 +572,33 @@ func (g *GeminiInternalRequest) UnmarshalJSON(b []byte) error {
 		return err
 	}
 	*g = GeminiInternalRequest(s)
+	g.Extra = extra
 	return nil
 }
 
+func (g GeminiInternalRequest) MarshalJSON() ([]byte, error) {
+	type alias GeminiInternalRequest
+	return marshalWithExtra(alias(g), g.Extra)
+}
+
+// CountTokensRequest represents the request body for the countTokens endpoint.
+// Unlike generateContent it carries no project; the model goes inside the request.
+type CountTokensRequest struct {
+	Request CountTokensInternalRequest `json:"request"`
+}
+
+// CountTokensInternalRequest is the inner countTokens request.
+// Model must use the "models/<name>" form.
+type CountTokensInternalRequest struct {
+	Model    string    `json:"model"`
+	Contents []Content `json:"contents"`
+}
+
+// CountTokensResponse represents the response from the countTokens endpoint.
+type CountTokensResponse struct {
+	TotalTokens int `json:"totalTokens"`
+}
+
 // GenerateContentResponse represents the response from the generateContent endpoint.
 type GenerateContentResponse struct {
 	Response map[string]interface{} `json:"response"`
@@ -0,0 +1,115 @@
+package gemini
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeminiInternalRequest_RoundTrip(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*_request.json"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			payload, err := os.ReadFile(file)
+			require.NoError(t, err)
+
+			var req GeminiInternalRequest
+			require.NoError(t, json.Unmarshal(payload, &req))
+
+			out, err := json.Marshal(req)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(payload), string(out))
+
+			// The CloudCode envelope must carry the request unchanged as well
+			envelope, err := json.Marshal(GenerateContentRequest{Model: "gemini-2.5-pro", Project: "p", Request: req})
+			require.NoError(t, err)
+			var wrapped struct {
+				Request json.RawMessage `json:"request"`
+			}
+			require.NoError(t, json.Unmarshal(envelope, &wrapped))
+			assert.JSONEq(t, string(payload), string(wrapped.Request))
+		})
+	}
+}
+
+func TestGeminiInternalRequest_TypedFieldsStillApply(t *testing.T) {
+	payload := `{
+		"contents": [{"role": "user", "parts": [{"text": "hi", "thought": false}]}],
+		"systemInstruction": {"parts": [{"text": "sys"}]},
+		"safetySettings": [{"category": "HARM_CATEGORY_HARASSMENT", "threshold": "BLOCK_NONE"}],
+		"generationConfig": {"thinkingConfig": {"thinkingBudget": 0, "includeThoughts": true}, "seed": 7}
+	}`
+
+	var req GeminiInternalRequest
+	require.NoError(t, json.Unmarshal([]byte(payload), &req))
+
+	// Rewrites done by the proxy take precedence over the preserved raw values
+	req.SystemInstruction.Role = "system"
+	req.GenerationConfig.ThinkingConfig.ThinkingBudget = nil
+	req.Contents[0].Parts[0].Text = "hello"
+
+	out, err := json.Marshal(req)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"contents": [{"role": "user", "parts": [{"text": "hello", "thought": false}]}],
+		"systemInstruction": {"role": "system", "parts": [{"text": "sys"}]},
+		"safetySettings": [{"category": "HARM_CATEGORY_HARASSMENT", "threshold": "BLOCK_NONE"}],
+		"generationConfig": {"thinkingConfig": {"includeThoughts": true}, "seed": 7}
+	}`, string(out))
+}
+
+func TestTool_SnakeCaseFunctionDeclarations(t *testing.T) {
+	payload := `{
+		"tools": {"function_declarations": [{"name": "read", "parameters": {"type": "object", "properties": {"path": {"type": "string"}}}}], "googleSearch": {}}
+	}`
+
+	var req GeminiInternalRequest
+	require.NoError(t, json.Unmarshal([]byte(payload), &req))
+	require.Len(t, req.Tools, 1)
+	require.Len(t, req.Tools[0].FunctionDeclarations, 1)
+	require.NotNil(t, req.Tools[0].FunctionDeclarations[0].Parameters)
+
+	// snake_case is rewritten; other tool kinds are kept
+	out, err := json.Marshal(req)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"tools": [{
+			"functionDeclarations": [{"name": "read", "parameters": {"type": "object", "properties": {"path": {"type": "string"}}}}],
+			"googleSearch": {}
+		}]
+	}`, string(out))
+}
+
+func TestFunctionDeclaration_ParametersJSONSchemaIsForwarded(t *testing.T) {
+	// JSON Schema keywords Code Assist rejects inside "parameters"
+	schema := `{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"type": "object",
+		"properties": {
+			"kind": {"const": "file"},
+			"target": {"oneOf": [{"$ref": "#/$defs/path"}, {"type": "integer"}]}
+		},
+		"required": ["kind"],
+		"additionalProperties": false,
+		"$defs": {"path": {"type": "string"}}
+	}`
+
+	var decl FunctionDeclaration
+	require.NoError(t, json.Unmarshal([]byte(`{"name": "open", "parametersJsonSchema": `+schema+`}`), &decl))
+	assert.Nil(t, decl.Parameters)
+	assert.JSONEq(t, schema, string(decl.ParametersJSONSchema))
+
+	out, err := json.Marshal(decl)
+	require.NoError(t, err)
+	var fields map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(out, &fields))
+	assert.NotContains(t, fields, "parameters")
+	assert.JSONEq(t, schema, string(fields["parametersJsonSchema"]))
+}
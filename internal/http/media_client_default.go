@@ -0,0 +1,55 @@
+//go:build !js || !wasm
+
+package http
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// NewMediaHTTPClient creates the client that downloads media URLs sent by API clients.
+// It only connects to public addresses, checked after DNS resolution and for every redirect,
+// so that API keys can't make the proxy reach loopback, private or cloud metadata endpoints.
+func NewMediaHTTPClient() HTTPClient {
+	return &http.Client{
+		Timeout: 60 * time.Second,
+		Transport: &http.Transport{
+			// No Proxy: a forward proxy would dial on our behalf and bypass the address check
+			DialContext: (&net.Dialer{
+				Timeout:   10 * time.Second,
+				KeepAlive: 30 * time.Second,
+				Control:   publicAddressControl,
+			}).DialContext,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 30 * time.Second,
+		},
+		CheckRedirect: checkMediaRedirect,
+	}
+}
+
+// publicAddressControl refuses connections to non-public addresses. It runs after DNS
+// resolution, so hostnames resolving to private addresses are caught as well.
+func publicAddressControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !IsPublicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+	}
+	return nil
+}
+
+// checkMediaRedirect validates every redirect target like the original URL.
+func checkMediaRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxMediaRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxMediaRedirects)
+	}
+	return checkMediaURL(req.URL)
+}
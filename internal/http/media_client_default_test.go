@@ -0,0 +1,85 @@
+//go:build !js || !wasm
+
+package http
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var blockedAddresses = map[string][]string{
+	"loopback":      {"127.0.0.1", "127.1.2.3", "::1", "::ffff:127.0.0.1"},
+	"private":       {"10.0.0.1", "172.16.5.4", "192.168.1.1", "fd00::1"},
+	"link-local":    {"169.254.10.20", "fe80::1"},
+	"metadata":      {"169.254.169.254", "fd00:ec2::254"},
+	"unspecified":   {"0.0.0.0", "::"},
+	"carrier-grade": {"100.64.0.1"},
+	"multicast":     {"224.0.0.1", "ff02::1"},
+	"reserved":      {"240.0.0.1", "255.255.255.255"},
+}
+
+func TestIsPublicIP(t *testing.T) {
+	for class, ips := range blockedAddresses {
+		for _, ip := range ips {
+			assert.False(t, IsPublicIP(net.ParseIP(ip)), "%s address %s", class, ip)
+		}
+	}
+	for _, ip := range []string{"8.8.8.8", "142.250.185.78", "2001:4860:4860::8888"} {
+		assert.True(t, IsPublicIP(net.ParseIP(ip)), ip)
+	}
+}
+
+func TestMediaHTTPClient_BlocksNonPublicAddresses(t *testing.T) {
+	client := NewMediaHTTPClient()
+
+	for class, ips := range blockedAddresses {
+		for _, ip := range ips {
+			t.Run(class+" "+ip, func(t *testing.T) {
+				// The dial is refused before any packet is sent
+				req, err := http.NewRequest(http.MethodGet, "http://"+net.JoinHostPort(ip, "8080")+"/latest/meta-data", nil)
+				require.NoError(t, err)
+				_, err = client.Do(req)
+				assert.ErrorIs(t, err, ErrBlockedAddress)
+			})
+		}
+	}
+}
+
+func TestMediaHTTPClient_BlocksHostnamesResolvingToLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the request must not reach the server")
+	}))
+	defer server.Close()
+
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodGet, "http://localhost:"+port+"/image.png", nil)
+	require.NoError(t, err)
+
+	_, err = NewMediaHTTPClient().Do(req)
+	assert.ErrorIs(t, err, ErrBlockedAddress)
+}
+
+func TestCheckMediaRedirect(t *testing.T) {
+	via := []*http.Request{httptest.NewRequest(http.MethodGet, "https://example.com/image.png", nil)}
+
+	for _, target := range []string{
+		"http://169.254.169.254/latest/meta-data/iam/security-credentials/",
+		"http://10.0.0.1/admin",
+		"http://[::1]:8080/",
+		"http://localhost/",
+		"file:///etc/passwd",
+	} {
+		assert.Error(t, checkMediaRedirect(httptest.NewRequest(http.MethodGet, target, nil), via), target)
+	}
+
+	assert.NoError(t, checkMediaRedirect(httptest.NewRequest(http.MethodGet, "https://cdn.example.com/image.png", nil), via))
+
+	tooMany := make([]*http.Request, maxMediaRedirects)
+	assert.Error(t, checkMediaRedirect(httptest.NewRequest(http.MethodGet, "https://cdn.example.com/image.png", nil), tooMany))
+}
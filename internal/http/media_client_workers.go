@@ -0,0 +1,23 @@
+//go:build js && wasm
+
+package http
+
+import "net/http"
+
+// mediaHTTPClient checks media URLs before handing them to fetch.
+type mediaHTTPClient struct {
+	next HTTPClient
+}
+
+// NewMediaHTTPClient creates the client that downloads media URLs sent by API clients.
+// Workers fetch can't reach private networks, so only URLs naming a non-public host are refused.
+func NewMediaHTTPClient() HTTPClient {
+	return &mediaHTTPClient{next: NewHTTPClient()}
+}
+
+func (c *mediaHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	if err := checkMediaURL(req.URL); err != nil {
+		return nil, err
+	}
+	return c.next.Do(req)
+}
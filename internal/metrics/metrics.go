@@ -0,0 +1,365 @@
+// Package metrics is a minimal Prometheus instrumentation library: counters, gauges and
+// histograms with labels, exposed in the Prometheus text format.
+//
+// It avoids the dependency on the Prometheus client (which does not build for Workers) and
+// only implements what the proxy needs.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are the default latency buckets in seconds, covering fast calls to long streams.
+var DefBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// Registry holds metrics and writes them in the Prometheus text format.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteText writes all metrics in the Prometheus text exposition format.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler serves the metrics of the registry.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
+	})
+}
+
+// desc is the name, help and label names shared by all metric types.
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+func (d *desc) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, d.help, d.name, d.kind)
+}
+
+// seriesKey joins label values into a map key.
+func (d *desc) seriesKey(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// labelString formats labels as {a="1",b="2"}, with extra appended (e.g. le for buckets).
+func (d *desc) labelString(values []string, extra ...string) string {
+	if len(d.labels) == 0 && len(extra) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	pairs := append(append([]string(nil), interleave(d.labels, values)...), extra...)
+	for i := 0; i < len(pairs); i += 2 {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(pairs[i])
+		sb.WriteString(`="`)
+		sb.WriteString(escapeLabel(pairs[i+1]))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+func interleave(names, values []string) []string {
+	out := make([]string, 0, 2*len(names))
+	for i := range names {
+		out = append(out, names[i], values[i])
+	}
+	return out
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+// valueSeries is a labeled series with a single value, used by counters and gauges.
+type valueSeries struct {
+	values []string
+	value  float64
+}
+
+type valueVec struct {
+	desc
+	mu     sync.Mutex
+	series map[string]*valueSeries
+}
+
+func (v *valueVec) add(delta float64, values []string) {
+	key := v.seriesKey(values)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.series[key]
+	if !ok {
+		s = &valueSeries{values: append([]string(nil), values...)}
+		v.series[key] = s
+	}
+	s.value += delta
+}
+
+func (v *valueVec) set(value float64, values []string) {
+	key := v.seriesKey(values)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.series[key]
+	if !ok {
+		s = &valueSeries{values: append([]string(nil), values...)}
+		v.series[key] = s
+	}
+	s.value = value
+}
+
+func (v *valueVec) get(values []string) float64 {
+	key := v.seriesKey(values)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if s, ok := v.series[key]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (v *valueVec) write(w *bufio.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.writeHeader(w)
+	for _, key := range sortedKeys(v.series) {
+		s := v.series[key]
+		fmt.Fprintf(w, "%s%s %s\n", v.name, v.labelString(s.values), formatValue(s.value))
+	}
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Counter is a value that only goes up.
+type Counter struct {
+	vec valueVec
+}
+
+// NewCounter registers a counter with the given label names.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{vec: valueVec{desc: desc{name, help, "counter", labels}, series: map[string]*valueSeries{}}}
+	r.register(&c.vec)
+	return c
+}
+
+// Inc adds 1 to the series with the given label values.
+func (c *Counter) Inc(labelValues ...string) {
+	c.vec.add(1, labelValues)
+}
+
+// Add adds v (which must not be negative) to the series with the given label values.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	c.vec.add(v, labelValues)
+}
+
+// Value returns the current value of the series with the given label values.
+func (c *Counter) Value(labelValues ...string) float64 {
+	return c.vec.get(labelValues)
+}
+
+// Gauge is a value that goes up and down.
+type Gauge struct {
+	vec valueVec
+}
+
+// NewGauge registers a gauge with the given label names.
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{vec: valueVec{desc: desc{name, help, "gauge", labels}, series: map[string]*valueSeries{}}}
+	r.register(&g.vec)
+	return g
+}
+
+// Set sets the series with the given label values to v.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.vec.set(v, labelValues)
+}
+
+// Inc adds 1 to the series with the given label values.
+func (g *Gauge) Inc(labelValues ...string) {
+	g.vec.add(1, labelValues)
+}
+
+// Dec subtracts 1 from the series with the given label values.
+func (g *Gauge) Dec(labelValues ...string) {
+	g.vec.add(-1, labelValues)
+}
+
+// Value returns the current value of the series with the given label values.
+func (g *Gauge) Value(labelValues ...string) float64 {
+	return g.vec.get(labelValues)
+}
+
+// Sample is one series of a GaugeFunc.
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// GaugeFunc is a gauge computed when the metrics are scraped.
+type GaugeFunc struct {
+	desc
+	mu sync.Mutex
+	fn func() []Sample
+}
+
+// NewGaugeFunc registers a gauge whose samples are returned by a function set with Set.
+func (r *Registry) NewGaugeFunc(name, help string, labels ...string) *GaugeFunc {
+	g := &GaugeFunc{desc: desc{name, help, "gauge", labels}}
+	r.register(g)
+	return g
+}
+
+// Set sets the function computing the samples, replacing any previous one.
+func (g *GaugeFunc) Set(fn func() []Sample) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.fn = fn
+}
+
+func (g *GaugeFunc) write(w *bufio.Writer) {
+	g.mu.Lock()
+	fn := g.fn
+	g.mu.Unlock()
+
+	g.writeHeader(w)
+	if fn == nil {
+		return
+	}
+	for _, s := range fn() {
+		g.seriesKey(s.LabelValues) // validates the label count
+		fmt.Fprintf(w, "%s%s %s\n", g.name, g.labelString(s.LabelValues), formatValue(s.Value))
+	}
+}
+
+// Histogram counts observations in buckets.
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	values []string
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram registers a histogram with the given upper bounds (sorted, without +Inf).
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{desc: desc{name, help, "histogram", labels}, buckets: buckets, series: map[string]*histogramSeries{}}
+	r.register(h)
+	return h
+}
+
+// Observe adds v to the series with the given label values.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	key := h.seriesKey(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{values: append([]string(nil), labelValues...), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.sum += v
+	s.count++
+}
+
+// Count returns the number of observations of the series with the given label values.
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	key := h.seriesKey(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.series[key]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writeHeader(w)
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelString(s.values, "le", formatValue(upper)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelString(s.values, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelString(s.values), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelString(s.values), s.count)
+	}
+}
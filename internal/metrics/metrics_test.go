@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scrape(t *testing.T, r *Registry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, r.WriteText(&buf))
+	return buf.String()
+}
+
+func TestCounterAndGauge(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("requests_total", "Requests.", "route", "status")
+	g := r.NewGauge("in_flight", "In flight.")
+
+	c.Inc("/v1/messages", "200")
+	c.Add(2, "/v1/messages", "200")
+	c.Inc("/v1/chat/completions", "429")
+	g.Inc()
+	g.Inc()
+	g.Dec()
+
+	assert.Equal(t, float64(3), c.Value("/v1/messages", "200"))
+	assert.Equal(t, `# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{route="/v1/chat/completions",status="429"} 1
+requests_total{route="/v1/messages",status="200"} 3
+# HELP in_flight In flight.
+# TYPE in_flight gauge
+in_flight 1
+`, scrape(t, r))
+
+	assert.Panics(t, func() { c.Inc("only-one") })
+	assert.Panics(t, func() { c.Add(-1, "a", "b") })
+}
+
+func TestHistogram(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogram("latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+
+	h.Observe(0.05, "/x")
+	h.Observe(0.1, "/x")
+	h.Observe(0.5, "/x")
+	h.Observe(5, "/x")
+
+	assert.Equal(t, uint64(4), h.Count("/x"))
+	assert.Equal(t, `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/x",le="0.1"} 2
+latency_seconds_bucket{route="/x",le="1"} 3
+latency_seconds_bucket{route="/x",le="+Inf"} 4
+latency_seconds_sum{route="/x"} 5.65
+latency_seconds_count{route="/x"} 4
+`, scrape(t, r))
+}
+
+func TestGaugeFunc(t *testing.T) {
+	r := NewRegistry()
+	g := r.NewGaugeFunc("expiry_seconds", "Expiry.", "account")
+	assert.Equal(t, "# HELP expiry_seconds Expiry.\n# TYPE expiry_seconds gauge\n", scrape(t, r))
+
+	g.Set(func() []Sample {
+		return []Sample{{LabelValues: []string{`work "main"`}, Value: -12.5}}
+	})
+	assert.Contains(t, scrape(t, r), `expiry_seconds{account="work \"main\""} -12.5`)
+}
@@ -0,0 +1,39 @@
+package metrics
+
+// Default is the registry served at /metrics.
+var Default = NewRegistry()
+
+// The metrics of the proxy.
+var (
+	// Requests counts handled requests on the model endpoints
+	Requests = Default.NewCounter("gcap_requests_total",
+		"Requests handled on the model endpoints.", "route", "model", "status")
+
+	// RequestDuration is the time to handle a request, including the whole stream
+	RequestDuration = Default.NewHistogram("gcap_request_duration_seconds",
+		"Time to handle a request on the model endpoints, including the whole stream.", DefBuckets, "route", "model", "status")
+
+	// TimeToFirstToken is the time from the request to the first upstream stream event
+	TimeToFirstToken = Default.NewHistogram("gcap_stream_time_to_first_token_seconds",
+		"Time from the request to the first event of the upstream stream.", DefBuckets, "route", "model")
+
+	// StreamsInFlight is the number of streams currently open
+	StreamsInFlight = Default.NewGauge("gcap_streams_in_flight",
+		"Streaming responses currently open.", "route")
+
+	// UpstreamErrors counts error responses from Code Assist
+	UpstreamErrors = Default.NewCounter("gcap_upstream_errors_total",
+		"Error responses from the Code Assist API.", "method", "status")
+
+	// TokenRefreshes counts OAuth token refreshes
+	TokenRefreshes = Default.NewCounter("gcap_token_refreshes_total",
+		"OAuth access token refreshes.", "account", "result")
+
+	// CredentialExpiry is the time until the access token of each account expires
+	CredentialExpiry = Default.NewGaugeFunc("gcap_credential_expiry_seconds",
+		"Seconds until the OAuth access token of the account expires; negative once expired.", "account")
+
+	// Tokens counts the tokens reported in usageMetadata
+	Tokens = Default.NewCounter("gcap_tokens_total",
+		"Tokens consumed, as reported by usageMetadata.", "model", "type")
+)
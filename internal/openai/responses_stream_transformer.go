@@ -0,0 +1,242 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/logger"
+	"github.com/google/uuid"
+)
+
+// NewResponseID returns a fresh Responses API response id.
+func NewResponseID() string {
+	return fmt.Sprintf("resp_%s", strings.ReplaceAll(uuid.New().String(), "-", ""))
+}
+
+// NewResponseItemID returns a fresh output item id with the given prefix (msg, rs, fc).
+func NewResponseItemID(prefix string) string {
+	return fmt.Sprintf("%s_%s", prefix, strings.ReplaceAll(uuid.New().String(), "-", ""))
+}
+
+// CreateResponsesStreamTransformer creates a transformer that converts Gemini StreamChunks
+// into Responses API SSE events (response.created, response.output_item.added,
+// response.output_text.delta, ..., response.completed).
+//
+// base provides the id/model/previous_response_id of the response being streamed.
+// onDone, if set, receives the final response object before the terminal event is emitted.
+func CreateResponsesStreamTransformer(base Response, onDone func(*Response)) func(<-chan StreamChunk) <-chan string {
+	return func(input <-chan StreamChunk) <-chan string {
+		output := make(chan string, 10)
+
+		go func() {
+			defer close(output)
+
+			resp := base
+			resp.Object = ResponseObject
+			if resp.CreatedAt == 0 {
+				resp.CreatedAt = time.Now().Unix()
+			}
+			resp.Status = ResponseStatusInProgress
+			resp.Output = []ResponseItem{}
+
+			seq := 0
+			emit := func(event string, payload map[string]interface{}) {
+				payload["type"] = event
+				payload["sequence_number"] = seq
+				seq++
+				jsonBytes, err := json.Marshal(payload)
+				if err != nil {
+					logger.Get().Error().Err(err).Str("event", event).Msg("Failed to marshal Responses SSE event")
+					return
+				}
+				output <- fmt.Sprintf("event: %s\ndata: %s\n\n", event, string(jsonBytes))
+			}
+
+			snapshot := resp
+			emit("response.created", map[string]interface{}{"response": snapshot})
+			emit("response.in_progress", map[string]interface{}{"response": snapshot})
+
+			// The currently open output item, if any
+			var current *ResponseItem
+			var text strings.Builder
+			finishReason := ""
+			var usage *UsageData
+
+			openItem := func(item ResponseItem) {
+				resp.Output = append(resp.Output, item)
+				current = &resp.Output[len(resp.Output)-1]
+				text.Reset()
+				emit("response.output_item.added", map[string]interface{}{
+					"output_index": len(resp.Output) - 1,
+					"item":         *current,
+				})
+			}
+
+			closeItem := func() {
+				if current == nil {
+					return
+				}
+				idx := len(resp.Output) - 1
+				switch current.Type {
+				case "message":
+					part := ResponseContentPart{Type: "output_text", Text: text.String(), Annotations: []interface{}{}}
+					current.Content = ResponseContent{part}
+					emit("response.output_text.done", map[string]interface{}{
+						"item_id": current.ID, "output_index": idx, "content_index": 0, "text": part.Text,
+					})
+					emit("response.content_part.done", map[string]interface{}{
+						"item_id": current.ID, "output_index": idx, "content_index": 0, "part": part,
+					})
+				case "reasoning":
+					if text.Len() > 0 {
+						part := ResponseSummaryPart{Type: "summary_text", Text: text.String()}
+						current.Summary = []ResponseSummaryPart{part}
+						emit("response.reasoning_summary_text.done", map[string]interface{}{
+							"item_id": current.ID, "output_index": idx, "summary_index": 0, "text": part.Text,
+						})
+						emit("response.reasoning_summary_part.done", map[string]interface{}{
+							"item_id": current.ID, "output_index": idx, "summary_index": 0, "part": part,
+						})
+					}
+				}
+				// Reasoning items carry no status in the Responses API
+				if current.Type != "reasoning" {
+					current.Status = ResponseStatusCompleted
+				}
+				emit("response.output_item.done", map[string]interface{}{
+					"output_index": idx,
+					"item":         *current,
+				})
+				current = nil
+			}
+
+			for chunk := range input {
+				logger.Get().Debug().Interface("chunk", chunk).Msg("Processing Gemini stream chunk (responses)")
+
+				switch chunk.Type {
+				case "text", "thinking_content":
+					txt, ok := chunk.Data.(string)
+					if !ok || txt == "" {
+						continue
+					}
+					if current == nil || current.Type != "message" {
+						closeItem()
+						openItem(ResponseItem{Type: "message", ID: NewResponseItemID("msg"), Status: ResponseStatusInProgress, Role: "assistant", Content: ResponseContent{}})
+						emit("response.content_part.added", map[string]interface{}{
+							"item_id": current.ID, "output_index": len(resp.Output) - 1, "content_index": 0,
+							"part": ResponseContentPart{Type: "output_text", Text: "", Annotations: []interface{}{}},
+						})
+					}
+					text.WriteString(txt)
+					emit("response.output_text.delta", map[string]interface{}{
+						"item_id": current.ID, "output_index": len(resp.Output) - 1, "content_index": 0, "delta": txt,
+					})
+
+				case "real_thinking":
+					txt, ok := chunk.Data.(string)
+					if !ok || txt == "" {
+						continue
+					}
+					if current == nil || current.Type != "reasoning" || current.EncryptedContent != "" {
+						closeItem()
+						openItem(ResponseItem{Type: "reasoning", ID: NewResponseItemID("rs")})
+					}
+					if text.Len() == 0 {
+						emit("response.reasoning_summary_part.added", map[string]interface{}{
+							"item_id": current.ID, "output_index": len(resp.Output) - 1, "summary_index": 0,
+							"part": ResponseSummaryPart{Type: "summary_text", Text: ""},
+						})
+					}
+					text.WriteString(txt)
+					emit("response.reasoning_summary_text.delta", map[string]interface{}{
+						"item_id": current.ID, "output_index": len(resp.Output) - 1, "summary_index": 0, "delta": txt,
+					})
+
+				case "thought_signature":
+					sig, ok := chunk.Data.(string)
+					if !ok || sig == "" {
+						continue
+					}
+					// Gemini thought signatures travel as the reasoning item's encrypted_content
+					// so they are replayed with the history on the next turn.
+					if current != nil && current.Type == "reasoning" && current.EncryptedContent == "" {
+						current.EncryptedContent = sig
+						continue
+					}
+					closeItem()
+					openItem(ResponseItem{Type: "reasoning", ID: NewResponseItemID("rs"), EncryptedContent: sig})
+					closeItem()
+
+				case "tool_code":
+					funcCall, ok := toGeminiFunctionCall(chunk.Data)
+					if !ok {
+						continue
+					}
+					closeItem()
+					argsJSON, _ := json.Marshal(funcCall.Args)
+					openItem(ResponseItem{
+						Type:   "function_call",
+						ID:     NewResponseItemID("fc"),
+						Status: ResponseStatusInProgress,
+						CallID: fmt.Sprintf("call_%s", uuid.New().String()),
+						Name:   funcCall.Name,
+					})
+					idx := len(resp.Output) - 1
+					current.Arguments = string(argsJSON)
+					emit("response.function_call_arguments.delta", map[string]interface{}{
+						"item_id": current.ID, "output_index": idx, "delta": current.Arguments,
+					})
+					emit("response.function_call_arguments.done", map[string]interface{}{
+						"item_id": current.ID, "output_index": idx, "arguments": current.Arguments,
+					})
+					closeItem()
+
+				case "finish_reason":
+					if fr, ok := chunk.Data.(string); ok && fr != "" {
+						finishReason = fr
+					}
+
+				case "usage":
+					if u, ok := toUsageData(chunk.Data); ok {
+						usage = &u
+					}
+				}
+			}
+
+			closeItem()
+
+			resp.Status, resp.IncompleteDetails = ResponseStatusFromGemini(finishReason)
+			if usage != nil {
+				resp.Usage = &ResponseUsage{
+					InputTokens:  usage.InputTokens,
+					OutputTokens: usage.OutputTokens,
+					TotalTokens:  usage.InputTokens + usage.OutputTokens,
+				}
+			}
+
+			if onDone != nil {
+				onDone(&resp)
+			}
+
+			terminal := "response.completed"
+			if resp.Status == ResponseStatusIncomplete {
+				terminal = "response.incomplete"
+			}
+			emit(terminal, map[string]interface{}{"response": resp})
+		}()
+
+		return output
+	}
+}
+
+// FormatResponsesErrorEvent renders a Responses API "error" SSE event.
+func FormatResponsesErrorEvent(code, message string) string {
+	jsonBytes, _ := json.Marshal(map[string]interface{}{
+		"type":    "error",
+		"code":    code,
+		"message": message,
+	})
+	return fmt.Sprintf("event: error\ndata: %s\n\n", string(jsonBytes))
+}
@@ -0,0 +1,155 @@
+package openai
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type responsesEvent struct {
+	Event string
+	Data  map[string]interface{}
+}
+
+func collectResponsesEvents(t *testing.T, chunks []StreamChunk, onDone func(*Response)) []responsesEvent {
+	t.Helper()
+
+	input := make(chan StreamChunk, len(chunks))
+	for _, c := range chunks {
+		input <- c
+	}
+	close(input)
+
+	base := Response{ID: "resp_test", Model: "gemini-2.5-pro"}
+	var events []responsesEvent
+	for raw := range CreateResponsesStreamTransformer(base, onDone)(input) {
+		lines := strings.Split(strings.TrimSpace(raw), "\n")
+		if len(lines) != 2 || !strings.HasPrefix(lines[0], "event: ") || !strings.HasPrefix(lines[1], "data: ") {
+			t.Fatalf("unexpected SSE frame: %q", raw)
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &data); err != nil {
+			t.Fatalf("failed to parse event data: %v", err)
+		}
+		event := strings.TrimPrefix(lines[0], "event: ")
+		if data["type"] != event {
+			t.Errorf("event name %q does not match data type %v", event, data["type"])
+		}
+		if seq, _ := data["sequence_number"].(float64); int(seq) != len(events) {
+			t.Errorf("event %d (%s) has sequence_number %v", len(events), event, data["sequence_number"])
+		}
+		events = append(events, responsesEvent{Event: event, Data: data})
+	}
+	return events
+}
+
+func responsesEventNames(events []responsesEvent) []string {
+	names := make([]string, 0, len(events))
+	for _, e := range events {
+		names = append(names, e.Event)
+	}
+	return names
+}
+
+func TestResponsesStreamTransformer_Text(t *testing.T) {
+	var final *Response
+	events := collectResponsesEvents(t, []StreamChunk{
+		{Type: "text", Data: "Hello"},
+		{Type: "text", Data: ", world"},
+		{Type: "finish_reason", Data: "STOP"},
+		{Type: "usage", Data: map[string]interface{}{"inputTokens": 5, "outputTokens": 3}},
+	}, func(r *Response) { final = r })
+
+	want := []string{
+		"response.created",
+		"response.in_progress",
+		"response.output_item.added",
+		"response.content_part.added",
+		"response.output_text.delta",
+		"response.output_text.delta",
+		"response.output_text.done",
+		"response.content_part.done",
+		"response.output_item.done",
+		"response.completed",
+	}
+	if got := responsesEventNames(events); !reflect.DeepEqual(got, want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+
+	if final == nil {
+		t.Fatal("onDone was not called")
+	}
+	if final.ID != "resp_test" || final.Status != ResponseStatusCompleted {
+		t.Errorf("final response = %s/%s", final.ID, final.Status)
+	}
+	if len(final.Output) != 1 || final.Output[0].Content[0].Text != "Hello, world" {
+		t.Errorf("unexpected final output: %+v", final.Output)
+	}
+	if final.Usage == nil || final.Usage.TotalTokens != 8 {
+		t.Errorf("unexpected usage: %+v", final.Usage)
+	}
+
+	completed := events[len(events)-1].Data["response"].(map[string]interface{})
+	if completed["status"] != "completed" {
+		t.Errorf("completed status = %v", completed["status"])
+	}
+}
+
+func TestResponsesStreamTransformer_ReasoningAndFunctionCall(t *testing.T) {
+	var final *Response
+	events := collectResponsesEvents(t, []StreamChunk{
+		{Type: "real_thinking", Data: "Need to read the file."},
+		{Type: "thought_signature", Data: "sig-1"},
+		{Type: "tool_code", Data: map[string]interface{}{"name": "read", "args": map[string]interface{}{"path": "poem.md"}}},
+		{Type: "finish_reason", Data: "STOP"},
+	}, func(r *Response) { final = r })
+
+	want := []string{
+		"response.created",
+		"response.in_progress",
+		"response.output_item.added",
+		"response.reasoning_summary_part.added",
+		"response.reasoning_summary_text.delta",
+		"response.reasoning_summary_text.done",
+		"response.reasoning_summary_part.done",
+		"response.output_item.done",
+		"response.output_item.added",
+		"response.function_call_arguments.delta",
+		"response.function_call_arguments.done",
+		"response.output_item.done",
+		"response.completed",
+	}
+	if got := responsesEventNames(events); !reflect.DeepEqual(got, want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+
+	if len(final.Output) != 2 {
+		t.Fatalf("expected 2 output items, got %d", len(final.Output))
+	}
+	reasoning := final.Output[0]
+	if reasoning.Type != "reasoning" || reasoning.EncryptedContent != "sig-1" || reasoning.Summary[0].Text != "Need to read the file." {
+		t.Errorf("unexpected reasoning item: %+v", reasoning)
+	}
+	call := final.Output[1]
+	if call.Type != "function_call" || call.Name != "read" || call.Arguments != `{"path":"poem.md"}` || !strings.HasPrefix(call.CallID, "call_") {
+		t.Errorf("unexpected function_call item: %+v", call)
+	}
+}
+
+func TestResponsesStreamTransformer_MaxTokensIsIncomplete(t *testing.T) {
+	events := collectResponsesEvents(t, []StreamChunk{
+		{Type: "text", Data: "Once upon"},
+		{Type: "finish_reason", Data: "MAX_TOKENS"},
+	}, nil)
+
+	last := events[len(events)-1]
+	if last.Event != "response.incomplete" {
+		t.Fatalf("terminal event = %s, want response.incomplete", last.Event)
+	}
+	resp := last.Data["response"].(map[string]interface{})
+	details, _ := resp["incomplete_details"].(map[string]interface{})
+	if details["reason"] != "max_output_tokens" {
+		t.Errorf("incomplete_details = %v", resp["incomplete_details"])
+	}
+}
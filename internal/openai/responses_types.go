@@ -0,0 +1,240 @@
+package openai
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// ResponsesRequest represents a request payload for the OpenAI Responses API (/v1/responses).
+type ResponsesRequest struct {
+	Model              string                 `json:"model"`
+	Input              ResponseInput          `json:"input"` // Can be a string or a slice of ResponseItem
+	Instructions       string                 `json:"instructions,omitempty"`
+	Tools              []ResponsesTool        `json:"tools,omitempty"`
+	ToolChoice         interface{}            `json:"tool_choice,omitempty"` // "none" | "auto" | "required" | {type: "function", name}
+	ParallelToolCalls  *bool                  `json:"parallel_tool_calls,omitempty"`
+	Stream             bool                   `json:"stream"`
+	MaxOutputTokens    int                    `json:"max_output_tokens,omitempty"`
+	Temperature        *float64               `json:"temperature,omitempty"`
+	TopP               *float64               `json:"top_p,omitempty"`
+	PreviousResponseID string                 `json:"previous_response_id,omitempty"`
+	Store              *bool                  `json:"store,omitempty"`
+	Reasoning          *ResponsesReasoning    `json:"reasoning,omitempty"`
+	Metadata           map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// ShouldStore reports whether the response should be kept for previous_response_id lookups.
+// The Responses API stores by default.
+func (r *ResponsesRequest) ShouldStore() bool {
+	return r.Store == nil || *r.Store
+}
+
+// ResponsesReasoning configures reasoning for reasoning-capable models.
+type ResponsesReasoning struct {
+	Effort  string `json:"effort,omitempty"`
+	Summary string `json:"summary,omitempty"`
+}
+
+// ResponsesTool represents a tool in the Responses API. Function tools are flat
+// (name/parameters at the top level) unlike chat completions.
+type ResponsesTool struct {
+	Type        string      `json:"type"`
+	Name        string      `json:"name,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Parameters  interface{} `json:"parameters,omitempty"`
+	Strict      bool        `json:"strict,omitempty"`
+}
+
+// ResponseInput is the list of input items. On the wire it may also be a plain string,
+// which is treated as a single user message.
+type ResponseInput []ResponseItem
+
+// UnmarshalJSON: accept a plain string as shorthand for a single user message.
+func (in *ResponseInput) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*in = ResponseInput{{Type: "message", Role: "user", Content: ResponseContent{{Type: "input_text", Text: s}}}}
+		return nil
+	}
+	var items []ResponseItem
+	if err := json.Unmarshal(b, &items); err != nil {
+		return err
+	}
+	*in = items
+	return nil
+}
+
+// ResponseItem is a single input or output item.
+//
+// Supported types: message, function_call, function_call_output, reasoning.
+// Items without a type but with a role are treated as messages.
+type ResponseItem struct {
+	Type   string `json:"type,omitempty"`
+	ID     string `json:"id,omitempty"`
+	Status string `json:"status,omitempty"`
+
+	// message
+	Role    string          `json:"role,omitempty"`
+	Content ResponseContent `json:"content,omitempty"`
+
+	// function_call / function_call_output
+	CallID    string      `json:"call_id,omitempty"`
+	Name      string      `json:"name,omitempty"`
+	Arguments string      `json:"arguments,omitempty"`
+	Output    interface{} `json:"output,omitempty"` // string or list of content parts
+
+	// reasoning
+	Summary          []ResponseSummaryPart `json:"summary,omitempty"`
+	EncryptedContent string                `json:"encrypted_content,omitempty"`
+}
+
+// MarshalJSON: reasoning items always carry a summary list, even when empty.
+func (i ResponseItem) MarshalJSON() ([]byte, error) {
+	type alias ResponseItem
+	if i.Type != "reasoning" {
+		return json.Marshal(alias(i))
+	}
+	summary := i.Summary
+	if summary == nil {
+		summary = []ResponseSummaryPart{}
+	}
+	return json.Marshal(struct {
+		alias
+		Summary []ResponseSummaryPart `json:"summary"`
+	}{alias(i), summary})
+}
+
+// ItemType returns the item type, defaulting to "message" for role-only items.
+func (i ResponseItem) ItemType() string {
+	if i.Type == "" && i.Role != "" {
+		return "message"
+	}
+	return i.Type
+}
+
+// OutputText returns the text of a function_call_output item's output.
+func (i ResponseItem) OutputText() string {
+	switch v := i.Output.(type) {
+	case string:
+		return v
+	case []interface{}:
+		var b strings.Builder
+		for _, part := range v {
+			if pm, ok := part.(map[string]interface{}); ok {
+				if txt, ok := pm["text"].(string); ok && txt != "" {
+					if b.Len() > 0 {
+						b.WriteString("\n")
+					}
+					b.WriteString(txt)
+				}
+			}
+		}
+		return b.String()
+	}
+	return ""
+}
+
+// ResponseContent is the content of a message item. On the wire it may also be a plain string.
+type ResponseContent []ResponseContentPart
+
+// UnmarshalJSON: accept a plain string as shorthand for a single text part.
+func (c *ResponseContent) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*c = ResponseContent{{Type: "input_text", Text: s}}
+		return nil
+	}
+	var parts []ResponseContentPart
+	if err := json.Unmarshal(b, &parts); err != nil {
+		return err
+	}
+	*c = parts
+	return nil
+}
+
+// ResponseContentPart is a single part of a message item (input_text, output_text,
+// input_image, input_file, refusal, ...).
+type ResponseContentPart struct {
+	Type        string        `json:"type"`
+	Text        string        `json:"text"`
+	Annotations []interface{} `json:"annotations,omitempty"`
+
+	// input_image
+	ImageURL string `json:"image_url,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+
+	// input_file
+	FileData string `json:"file_data,omitempty"`
+	FileURL  string `json:"file_url,omitempty"`
+	Filename string `json:"filename,omitempty"`
+
+	// input_image, input_file (OpenAI file store; not supported)
+	FileID string `json:"file_id,omitempty"`
+}
+
+// ResponseSummaryPart is a single reasoning summary entry.
+type ResponseSummaryPart struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Response represents a Responses API response object.
+type Response struct {
+	ID                 string                 `json:"id"`
+	Object             string                 `json:"object"`
+	CreatedAt          int64                  `json:"created_at"`
+	Status             string                 `json:"status"`
+	Model              string                 `json:"model"`
+	Output             []ResponseItem         `json:"output"`
+	Usage              *ResponseUsage         `json:"usage,omitempty"`
+	PreviousResponseID string                 `json:"previous_response_id,omitempty"`
+	IncompleteDetails  *IncompleteDetails     `json:"incomplete_details,omitempty"`
+	Error              *ResponseError         `json:"error,omitempty"`
+	Metadata           map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// ResponseUsage represents the token usage for a response.
+type ResponseUsage struct {
+	InputTokens         int                 `json:"input_tokens"`
+	OutputTokens        int                 `json:"output_tokens"`
+	TotalTokens         int                 `json:"total_tokens"`
+	OutputTokensDetails OutputTokensDetails `json:"output_tokens_details"`
+}
+
+// OutputTokensDetails breaks down output token usage.
+type OutputTokensDetails struct {
+	ReasoningTokens int `json:"reasoning_tokens"`
+}
+
+// IncompleteDetails explains why a response has status "incomplete".
+type IncompleteDetails struct {
+	Reason string `json:"reason"`
+}
+
+// ResponseError describes a failed response.
+type ResponseError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+const (
+	ResponseObject = "response"
+
+	ResponseStatusInProgress = "in_progress"
+	ResponseStatusCompleted  = "completed"
+	ResponseStatusIncomplete = "incomplete"
+	ResponseStatusFailed     = "failed"
+)
+
+// ResponseStatusFromGemini maps a Gemini candidate finishReason onto a response status and,
+// for incomplete responses, the reason reported in incomplete_details.
+func ResponseStatusFromGemini(finishReason string) (string, *IncompleteDetails) {
+	switch finishReason {
+	case "MAX_TOKENS":
+		return ResponseStatusIncomplete, &IncompleteDetails{Reason: "max_output_tokens"}
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+		return ResponseStatusIncomplete, &IncompleteDetails{Reason: "content_filter"}
+	default:
+		return ResponseStatusCompleted, nil
+	}
+}
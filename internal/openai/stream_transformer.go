@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"time"
+	"unicode/utf8"
 
 	"github.com/dvcrn/gemini-code-assist-proxy/internal/logger"
 	"github.com/google/uuid"
@@ -13,6 +14,9 @@ import (
 type StreamChunk struct {
 	Type string      `json:"type"`
 	Data interface{} `json:"data"`
+
+	// Candidate is the index of the Gemini candidate the chunk belongs to (n > 1)
+	Candidate int `json:"candidate,omitempty"`
 }
 
 // ReasoningData contains reasoning information
@@ -40,16 +44,22 @@ type NativeToolResponse struct {
 }
 
 // OpenAIToolCall represents a tool call in OpenAI format
+// In streaming deltas only the first chunk of a call carries ID, Type and Name;
+// continuation chunks carry the Index and the next piece of Arguments.
 type OpenAIToolCall struct {
 	Index    int                `json:"index"`
-	ID       string             `json:"id"`
-	Type     string             `json:"type"`
+	ID       string             `json:"id,omitempty"`
+	Type     string             `json:"type,omitempty"`
 	Function OpenAIFunctionCall `json:"function"`
+
+	// ThoughtSignature is the Gemini thought signature of the functionCall part.
+	// It is not part of the OpenAI wire format; the server keeps it by ID.
+	ThoughtSignature string `json:"-"`
 }
 
 // OpenAIFunctionCall represents the function part of a tool call
 type OpenAIFunctionCall struct {
-	Name      string `json:"name"`
+	Name      string `json:"name,omitempty"`
 	Arguments string `json:"arguments"`
 }
 
@@ -59,6 +69,7 @@ type OpenAIDelta struct {
 	Content          *string              `json:"content,omitempty"`
 	Reasoning        *string              `json:"reasoning,omitempty"`
 	ReasoningContent *string              `json:"reasoning_content,omitempty"`
+	Refusal          *string              `json:"refusal,omitempty"`
 	ToolCalls        []OpenAIToolCall     `json:"tool_calls,omitempty"`
 	NativeToolCalls  []NativeToolResponse `json:"native_tool_calls,omitempty"`
 	Grounding        interface{}          `json:"grounding,omitempty"`
@@ -111,10 +122,42 @@ const (
 	OpenAIChatCompletionChunkObject = "chat.completion.chunk"
 )
 
+// StreamOption configures CreateOpenAIStreamTransformer.
+type StreamOption func(*streamOptions)
+
+type streamOptions struct {
+	toolArgumentsChunkSize int
+	recordSignature        func(toolCallID, signature string)
+}
+
+// WithToolArgumentsChunkSize streams each tool call's arguments in pieces of at most n bytes,
+// like OpenAI does, instead of in a single delta. n <= 0 disables chunking.
+func WithToolArgumentsChunkSize(n int) StreamOption {
+	return func(o *streamOptions) {
+		o.toolArgumentsChunkSize = n
+	}
+}
+
+// WithThoughtSignatureRecorder calls record with the id and thought signature of every
+// tool call that carries one, so the signature can be restored on the next request.
+func WithThoughtSignatureRecorder(record func(toolCallID, signature string)) StreamOption {
+	return func(o *streamOptions) {
+		o.recordSignature = record
+	}
+}
+
 // CreateOpenAIStreamTransformer creates a transformer that converts Gemini StreamChunks
 // into OpenAI-compatible SSE formatted strings.
 // It returns a function that accepts an input channel and returns an output channel.
-func CreateOpenAIStreamTransformer(model string) func(<-chan StreamChunk) <-chan string {
+//
+// Every function call in the response gets its own tool call index (0, 1, ...) and id,
+// so parallel calls are not merged by clients that accumulate deltas by index.
+func CreateOpenAIStreamTransformer(model string, opts ...StreamOption) func(<-chan StreamChunk) <-chan string {
+	options := streamOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	return func(input <-chan StreamChunk) <-chan string {
 		output := make(chan string, 10)
 
@@ -123,25 +166,83 @@ func CreateOpenAIStreamTransformer(model string) func(<-chan StreamChunk) <-chan
 
 			chatID := fmt.Sprintf("chatcmpl-%s", uuid.New().String())
 			creationTime := time.Now().Unix()
-			firstChunk := true
-			var toolCallID *string
+			blockReason := ""
 			var usageData *UsageData
 
+			// One state per Gemini candidate (choice), in order of first appearance
+			choices := map[int]*choiceState{}
+			var choiceOrder []int
+			choiceFor := func(index int) *choiceState {
+				st, ok := choices[index]
+				if !ok {
+					st = &choiceState{}
+					choices[index] = st
+					choiceOrder = append(choiceOrder, index)
+				}
+				return st
+			}
+
+			send := func(index int, delta OpenAIDelta, logprobs interface{}) {
+				choice := OpenAIChoice{
+					Index:        index,
+					Delta:        delta,
+					FinishReason: nil,
+					Logprobs:     nil,
+					MatchedStop:  nil,
+				}
+				if logprobs != nil {
+					choice.Logprobs = &logprobs
+				}
+				openAIChunk := OpenAIChunk{
+					ID:      chatID,
+					Object:  OpenAIChatCompletionChunkObject,
+					Created: creationTime,
+					Model:   model,
+					Choices: []OpenAIChoice{choice},
+					Usage:   nil,
+				}
+
+				if jsonBytes, err := json.Marshal(openAIChunk); err == nil {
+					sse := fmt.Sprintf("data: %s\n\n", string(jsonBytes))
+					logger.Get().Info().Str("sse", sse).Msg("Sending OpenAI SSE chunk")
+					output <- sse
+				}
+			}
+
 			// Process each chunk
 			for chunk := range input {
 				logger.Get().Info().Interface("chunk", chunk).Msg("Processing Gemini stream chunk")
 
+				if chunk.Type == "usage" {
+					if usage, ok := toUsageData(chunk.Data); ok {
+						usageData = &usage
+					}
+					// Don't send a chunk for usage data
+					continue
+				}
+
+				st := choiceFor(chunk.Candidate)
 				delta := OpenAIDelta{}
 				shouldSend := false
 
+				// A signature belongs to the part that immediately follows it
+				signature := st.pendingSignature
+				st.pendingSignature = ""
+
 				switch chunk.Type {
+				case "thought_signature":
+					if sig, ok := chunk.Data.(string); ok {
+						st.pendingSignature = sig
+					}
+					continue
+
 				case "text", "thinking_content":
 					if text, ok := chunk.Data.(string); ok {
 						delta.Content = &text
-						if firstChunk {
+						if !st.started {
 							role := "assistant"
 							delta.Role = &role
-							firstChunk = false
+							st.started = true
 						}
 						shouldSend = true
 					}
@@ -160,30 +261,41 @@ func CreateOpenAIStreamTransformer(model string) func(<-chan StreamChunk) <-chan
 
 				case "tool_code":
 					if funcCall, ok := toGeminiFunctionCall(chunk.Data); ok {
+						index := st.toolCallCount
+						st.toolCallCount++
 						callID := fmt.Sprintf("call_%s", uuid.New().String())
-						toolCallID = &callID
+						if signature != "" && options.recordSignature != nil {
+							options.recordSignature(callID, signature)
+						}
 
 						argsJSON, _ := json.Marshal(funcCall.Args)
+						pieces := splitArguments(string(argsJSON), options.toolArgumentsChunkSize)
 						delta.ToolCalls = []OpenAIToolCall{
 							{
-								Index: 0,
+								Index: index,
 								ID:    callID,
 								Type:  "function",
 								Function: OpenAIFunctionCall{
 									Name:      funcCall.Name,
-									Arguments: string(argsJSON),
+									Arguments: pieces[0],
 								},
 							},
 						}
 
-						if firstChunk {
+						if !st.started {
 							role := "assistant"
 							delta.Role = &role
 							nullContent := ""
 							delta.Content = &nullContent
-							firstChunk = false
+							st.started = true
+						}
+						send(chunk.Candidate, delta, nil)
+
+						for _, piece := range pieces[1:] {
+							send(chunk.Candidate, OpenAIDelta{ToolCalls: []OpenAIToolCall{
+								{Index: index, Function: OpenAIFunctionCall{Arguments: piece}},
+							}}, nil)
 						}
-						shouldSend = true
 					}
 
 				case "native_tool":
@@ -198,44 +310,51 @@ func CreateOpenAIStreamTransformer(model string) func(<-chan StreamChunk) <-chan
 						shouldSend = true
 					}
 
-				case "usage":
-					if usage, ok := toUsageData(chunk.Data); ok {
-						usageData = &usage
+				case "logprobs":
+					if lp, ok := chunk.Data.(*ChoiceLogprobs); ok && lp != nil {
+						send(chunk.Candidate, delta, lp)
 					}
-					// Don't send a chunk for usage data
-					continue
-				}
 
-				if shouldSend {
-					openAIChunk := OpenAIChunk{
-						ID:      chatID,
-						Object:  OpenAIChatCompletionChunkObject,
-						Created: creationTime,
-						Model:   model,
-						Choices: []OpenAIChoice{
-							{
-								Index:        0,
-								Delta:        delta,
-								FinishReason: nil,
-								Logprobs:     nil,
-								MatchedStop:  nil,
-							},
-						},
-						Usage: nil,
+				case "finish_reason":
+					if fr, ok := chunk.Data.(string); ok {
+						st.finishReason = fr
 					}
 
-					if jsonBytes, err := json.Marshal(openAIChunk); err == nil {
-						sse := fmt.Sprintf("data: %s\n\n", string(jsonBytes))
-						logger.Get().Info().Str("sse", sse).Msg("Sending OpenAI SSE chunk")
-						output <- sse
+				case "block_reason":
+					if br, ok := chunk.Data.(string); ok && br != "" {
+						blockReason = br
+						refusal := PromptBlockedRefusal(br)
+						delta.Refusal = &refusal
+						if !st.started {
+							role := "assistant"
+							delta.Role = &role
+							st.started = true
+						}
+						shouldSend = true
 					}
 				}
+
+				if shouldSend {
+					send(chunk.Candidate, delta, nil)
+				}
 			}
 
-			// Send final chunk
-			finishReason := "stop"
-			if toolCallID != nil {
-				finishReason = "tool_calls"
+			// Send final chunk with one finish reason per choice
+			if len(choiceOrder) == 0 {
+				choiceFor(0)
+			}
+			finalChoices := make([]OpenAIFinalChoice, 0, len(choiceOrder))
+			for _, index := range choiceOrder {
+				st := choices[index]
+				finishReason := FinishReasonFromGemini(st.finishReason, st.toolCallCount > 0)
+				if blockReason != "" {
+					finishReason = FinishReasonContentFilter
+				}
+				finalChoices = append(finalChoices, OpenAIFinalChoice{
+					Index:        index,
+					Delta:        map[string]interface{}{},
+					FinishReason: finishReason,
+				})
 			}
 
 			finalChunk := OpenAIFinalChunk{
@@ -243,13 +362,7 @@ func CreateOpenAIStreamTransformer(model string) func(<-chan StreamChunk) <-chan
 				Object:  OpenAIChatCompletionChunkObject,
 				Created: creationTime,
 				Model:   model,
-				Choices: []OpenAIFinalChoice{
-					{
-						Index:        0,
-						Delta:        map[string]interface{}{},
-						FinishReason: finishReason,
-					},
-				},
+				Choices: finalChoices,
 			}
 
 			if usageData != nil {
@@ -271,6 +384,39 @@ func CreateOpenAIStreamTransformer(model string) func(<-chan StreamChunk) <-chan
 	}
 }
 
+// choiceState tracks a single choice (Gemini candidate) of a streamed response.
+type choiceState struct {
+	started          bool // role has been sent
+	toolCallCount    int
+	finishReason     string // Gemini finishReason
+	pendingSignature string
+}
+
+// splitArguments splits s into pieces of at most size bytes without breaking UTF-8 sequences.
+// It always returns at least one piece.
+func splitArguments(s string, size int) []string {
+	if size <= 0 || len(s) <= size {
+		return []string{s}
+	}
+	var pieces []string
+	for len(s) > size {
+		cut := size
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		if cut == 0 {
+			// A single rune longer than size; emit it whole
+			_, cut = utf8.DecodeRuneInString(s)
+		}
+		pieces = append(pieces, s[:cut])
+		s = s[cut:]
+	}
+	if s != "" {
+		pieces = append(pieces, s)
+	}
+	return pieces
+}
+
 // Type conversion helpers
 
 func toReasoningData(data interface{}) (ReasoningData, bool) {
@@ -245,6 +245,149 @@ func TestCreateOpenAIStreamTransformer_ToolCall(t *testing.T) {
 	}
 }
 
+// accumulateToolCalls merges streamed tool call deltas by index, the way OpenAI clients do.
+func accumulateToolCalls(t *testing.T, output <-chan string) ([]OpenAIToolCall, int) {
+	t.Helper()
+	var calls []OpenAIToolCall
+	deltas := 0
+	for chunk := range output {
+		jsonStr := strings.TrimSpace(strings.TrimPrefix(chunk, "data: "))
+		if jsonStr == "[DONE]" {
+			continue
+		}
+		var parsed OpenAIChunk
+		if err := json.Unmarshal([]byte(jsonStr), &parsed); err != nil || len(parsed.Choices) == 0 {
+			continue
+		}
+		for _, tc := range parsed.Choices[0].Delta.ToolCalls {
+			deltas++
+			if tc.Index == len(calls) {
+				if tc.ID == "" || tc.Function.Name == "" {
+					t.Fatalf("first delta for tool call %d is missing id or name: %+v", tc.Index, tc)
+				}
+				calls = append(calls, tc)
+				continue
+			}
+			if tc.Index >= len(calls) {
+				t.Fatalf("tool call index %d skipped ahead of %d calls", tc.Index, len(calls))
+			}
+			if tc.ID != "" || tc.Function.Name != "" {
+				t.Errorf("continuation delta for tool call %d repeats id or name: %+v", tc.Index, tc)
+			}
+			calls[tc.Index].Function.Arguments += tc.Function.Arguments
+		}
+	}
+	return calls, deltas
+}
+
+func TestCreateOpenAIStreamTransformer_ParallelToolCallsInOneChunk(t *testing.T) {
+	transformer := CreateOpenAIStreamTransformer("gemini-2.5-pro")
+
+	// A single Gemini chunk with two functionCall parts becomes two consecutive tool_code chunks
+	input := make(chan StreamChunk, 2)
+	input <- StreamChunk{Type: "tool_code", Data: map[string]interface{}{"name": "get_weather", "args": map[string]interface{}{"location": "Tokyo"}}}
+	input <- StreamChunk{Type: "tool_code", Data: map[string]interface{}{"name": "get_time", "args": map[string]interface{}{"tz": "Asia/Tokyo"}}}
+	close(input)
+
+	calls, _ := accumulateToolCalls(t, transformer(input))
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 tool calls, got %d", len(calls))
+	}
+	if calls[0].Function.Name != "get_weather" || calls[1].Function.Name != "get_time" {
+		t.Errorf("unexpected tool call names: %s, %s", calls[0].Function.Name, calls[1].Function.Name)
+	}
+	if calls[0].ID == calls[1].ID {
+		t.Errorf("expected distinct tool call ids, both were %s", calls[0].ID)
+	}
+	if calls[1].Function.Arguments != `{"tz":"Asia/Tokyo"}` {
+		t.Errorf("unexpected arguments for second call: %s", calls[1].Function.Arguments)
+	}
+}
+
+func TestCreateOpenAIStreamTransformer_ParallelToolCallsAcrossChunks(t *testing.T) {
+	transformer := CreateOpenAIStreamTransformer("gemini-2.5-pro")
+
+	input := make(chan StreamChunk, 5)
+	input <- StreamChunk{Type: "text", Data: "Checking both."}
+	input <- StreamChunk{Type: "tool_code", Data: map[string]interface{}{"name": "read_file", "args": map[string]interface{}{"path": "a.go"}}}
+	input <- StreamChunk{Type: "usage", Data: map[string]interface{}{"inputTokens": 10, "outputTokens": 5}}
+	input <- StreamChunk{Type: "tool_code", Data: map[string]interface{}{"name": "read_file", "args": map[string]interface{}{"path": "b.go"}}}
+	input <- StreamChunk{Type: "tool_code", Data: map[string]interface{}{"name": "read_file", "args": map[string]interface{}{"path": "c.go"}}}
+	close(input)
+
+	calls, _ := accumulateToolCalls(t, transformer(input))
+	if len(calls) != 3 {
+		t.Fatalf("expected 3 tool calls, got %d", len(calls))
+	}
+	seen := map[string]bool{}
+	for i, call := range calls {
+		if call.Index != i {
+			t.Errorf("expected index %d, got %d", i, call.Index)
+		}
+		if seen[call.ID] {
+			t.Errorf("duplicate tool call id %s", call.ID)
+		}
+		seen[call.ID] = true
+	}
+	if calls[2].Function.Arguments != `{"path":"c.go"}` {
+		t.Errorf("unexpected arguments for third call: %s", calls[2].Function.Arguments)
+	}
+}
+
+func TestCreateOpenAIStreamTransformer_ToolArgumentsChunking(t *testing.T) {
+	transformer := CreateOpenAIStreamTransformer("gemini-2.5-pro", WithToolArgumentsChunkSize(8))
+
+	input := make(chan StreamChunk, 2)
+	input <- StreamChunk{Type: "tool_code", Data: map[string]interface{}{"name": "write_file", "args": map[string]interface{}{"path": "日本語.txt", "content": "héllo wörld"}}}
+	input <- StreamChunk{Type: "tool_code", Data: map[string]interface{}{"name": "noop", "args": map[string]interface{}{}}}
+	close(input)
+
+	calls, deltas := accumulateToolCalls(t, transformer(input))
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 tool calls, got %d", len(calls))
+	}
+	if deltas <= 2 {
+		t.Errorf("expected arguments to be split across several deltas, got %d", deltas)
+	}
+
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(calls[0].Function.Arguments), &args); err != nil {
+		t.Fatalf("reassembled arguments are not valid JSON: %v (%s)", err, calls[0].Function.Arguments)
+	}
+	if args["path"] != "日本語.txt" || args["content"] != "héllo wörld" {
+		t.Errorf("unexpected reassembled arguments: %v", args)
+	}
+	if calls[1].Function.Arguments != "{}" {
+		t.Errorf("expected empty arguments for second call, got %s", calls[1].Function.Arguments)
+	}
+}
+
+func TestCreateOpenAIStreamTransformer_ThoughtSignatureRecorder(t *testing.T) {
+	recorded := map[string]string{}
+	transformer := CreateOpenAIStreamTransformer("gemini-2.5-pro", WithThoughtSignatureRecorder(func(id, sig string) {
+		recorded[id] = sig
+	}))
+
+	input := make(chan StreamChunk, 6)
+	input <- StreamChunk{Type: "thought_signature", Data: "sig-text"}
+	input <- StreamChunk{Type: "text", Data: "Let me check."}
+	input <- StreamChunk{Type: "thought_signature", Data: "sig-call"}
+	input <- StreamChunk{Type: "tool_code", Data: map[string]interface{}{"name": "read", "args": map[string]interface{}{"path": "a"}}}
+	input <- StreamChunk{Type: "tool_code", Data: map[string]interface{}{"name": "read", "args": map[string]interface{}{"path": "b"}}}
+	close(input)
+
+	calls, _ := accumulateToolCalls(t, transformer(input))
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 tool calls, got %d", len(calls))
+	}
+	if len(recorded) != 1 {
+		t.Fatalf("expected 1 recorded signature, got %v", recorded)
+	}
+	if recorded[calls[0].ID] != "sig-call" {
+		t.Errorf("expected sig-call for %s, got %v", calls[0].ID, recorded)
+	}
+}
+
 func TestCreateOpenAIStreamTransformer_NativeTool(t *testing.T) {
 	model := "gemini-2.5-pro"
 	transformer := CreateOpenAIStreamTransformer(model)
@@ -482,6 +625,44 @@ func TestCreateOpenAIStreamTransformer_FinishReason(t *testing.T) {
 			},
 			expectedReason: "tool_calls",
 		},
+		{
+			name: "length when truncated",
+			chunks: []StreamChunk{
+				{Type: "text", Data: "Hello"},
+				{Type: "finish_reason", Data: "MAX_TOKENS"},
+			},
+			expectedReason: "length",
+		},
+		{
+			name: "length wins over tool calls",
+			chunks: []StreamChunk{
+				{Type: "tool_code", Data: map[string]interface{}{"name": "test_func", "args": map[string]interface{}{}}},
+				{Type: "finish_reason", Data: "MAX_TOKENS"},
+			},
+			expectedReason: "length",
+		},
+		{
+			name: "content_filter for safety",
+			chunks: []StreamChunk{
+				{Type: "text", Data: "Hello"},
+				{Type: "finish_reason", Data: "SAFETY"},
+			},
+			expectedReason: "content_filter",
+		},
+		{
+			name: "error for malformed function call",
+			chunks: []StreamChunk{
+				{Type: "finish_reason", Data: "MALFORMED_FUNCTION_CALL"},
+			},
+			expectedReason: "error",
+		},
+		{
+			name: "content_filter for blocked prompt",
+			chunks: []StreamChunk{
+				{Type: "block_reason", Data: "PROHIBITED_CONTENT"},
+			},
+			expectedReason: "content_filter",
+		},
 	}
 
 	for _, tt := range tests {
@@ -514,6 +695,81 @@ func TestCreateOpenAIStreamTransformer_FinishReason(t *testing.T) {
 	}
 }
 
+func TestCreateOpenAIStreamTransformer_BlockReasonRefusal(t *testing.T) {
+	transformer := CreateOpenAIStreamTransformer("gemini-2.5-pro")
+
+	input := make(chan StreamChunk, 1)
+	input <- StreamChunk{Type: "block_reason", Data: "SAFETY"}
+	close(input)
+
+	var refusal string
+	for chunk := range transformer(input) {
+		var parsed OpenAIChunk
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(chunk, "data: "))), &parsed); err != nil {
+			continue
+		}
+		if len(parsed.Choices) > 0 && parsed.Choices[0].Delta.Refusal != nil {
+			refusal = *parsed.Choices[0].Delta.Refusal
+		}
+	}
+
+	if !strings.Contains(refusal, "SAFETY") {
+		t.Errorf("expected refusal mentioning the block reason, got %q", refusal)
+	}
+}
+
+func TestCreateOpenAIStreamTransformer_MultipleChoices(t *testing.T) {
+	transformer := CreateOpenAIStreamTransformer("gemini-2.5-pro")
+
+	input := make(chan StreamChunk, 6)
+	input <- StreamChunk{Candidate: 0, Type: "text", Data: "A"}
+	input <- StreamChunk{Candidate: 1, Type: "text", Data: "B"}
+	input <- StreamChunk{Candidate: 1, Type: "tool_code", Data: map[string]interface{}{"name": "f", "args": map[string]interface{}{}}}
+	input <- StreamChunk{Candidate: 0, Type: "finish_reason", Data: "STOP"}
+	input <- StreamChunk{Candidate: 1, Type: "finish_reason", Data: "STOP"}
+	close(input)
+
+	content := map[int]string{}
+	roles := map[int]int{}
+	var final OpenAIFinalChunk
+	for chunk := range transformer(input) {
+		jsonStr := strings.TrimSpace(strings.TrimPrefix(chunk, "data: "))
+		if jsonStr == "[DONE]" {
+			continue
+		}
+		if strings.Contains(jsonStr, `"finish_reason":null`) {
+			var parsed OpenAIChunk
+			if err := json.Unmarshal([]byte(jsonStr), &parsed); err != nil {
+				t.Fatalf("failed to parse chunk: %v", err)
+			}
+			choice := parsed.Choices[0]
+			if choice.Delta.Content != nil {
+				content[choice.Index] += *choice.Delta.Content
+			}
+			if choice.Delta.Role != nil {
+				roles[choice.Index]++
+			}
+			continue
+		}
+		if err := json.Unmarshal([]byte(jsonStr), &final); err != nil {
+			t.Fatalf("failed to parse final chunk: %v", err)
+		}
+	}
+
+	if content[0] != "A" || content[1] != "B" {
+		t.Errorf("expected content per choice, got %v", content)
+	}
+	if roles[0] != 1 || roles[1] != 1 {
+		t.Errorf("expected role once per choice, got %v", roles)
+	}
+	if len(final.Choices) != 2 {
+		t.Fatalf("expected 2 final choices, got %d", len(final.Choices))
+	}
+	if final.Choices[0].FinishReason != "stop" || final.Choices[1].FinishReason != "tool_calls" {
+		t.Errorf("unexpected finish reasons: %+v", final.Choices)
+	}
+}
+
 func TestTypeConversionHelpers(t *testing.T) {
 	t.Run("toReasoningData", func(t *testing.T) {
 		testCases := []struct {
@@ -1,13 +1,97 @@
 package openai
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 // ChatCompletionRequest represents a request payload for OpenAI-compatible chat completion endpoints.
 type ChatCompletionRequest struct {
-	MaxTokens   int       `json:"max_tokens"`
-	Messages    []Message `json:"messages"`
-	Model       string    `json:"model"`
-	Stream      bool      `json:"stream"`
-	Temperature float64   `json:"temperature"`
-	Tools       []Tool    `json:"tools,omitempty"`
+	MaxTokens      int             `json:"max_tokens"`
+	Messages       []Message       `json:"messages"`
+	Model          string          `json:"model"`
+	Stream         bool            `json:"stream"`
+	Temperature    *float64        `json:"temperature,omitempty"`
+	Tools          []Tool          `json:"tools,omitempty"`
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+
+	// Sampling parameters. Pointers distinguish an explicit 0 from unset.
+	TopP             *float64      `json:"top_p,omitempty"`
+	Stop             StopSequences `json:"stop,omitempty"`
+	N                int           `json:"n,omitempty"`
+	Seed             *int          `json:"seed,omitempty"`
+	PresencePenalty  *float64      `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64      `json:"frequency_penalty,omitempty"`
+
+	// MaxCompletionTokens supersedes MaxTokens in newer OpenAI clients
+	MaxCompletionTokens int `json:"max_completion_tokens,omitempty"`
+
+	Logprobs    bool `json:"logprobs,omitempty"`
+	TopLogprobs *int `json:"top_logprobs,omitempty"`
+
+	// ReasoningEffort ("none", "minimal", "low", "medium", "high") controls Gemini thinking.
+	// Some clients send it as reasoning.effort instead.
+	ReasoningEffort string           `json:"reasoning_effort,omitempty"`
+	Reasoning       *ReasoningConfig `json:"reasoning,omitempty"`
+
+	// ToolChoice is "none", "auto", "required" or {"type": "function", "function": {"name": "..."}}
+	ToolChoice interface{} `json:"tool_choice,omitempty"`
+
+	// ParallelToolCalls is accepted for compatibility; Gemini has no equivalent switch
+	// and may always return several function calls in one turn.
+	ParallelToolCalls *bool `json:"parallel_tool_calls,omitempty"`
+}
+
+// ReasoningConfig is the nested reasoning object some OpenAI-compatible clients send.
+type ReasoningConfig struct {
+	Effort string `json:"effort,omitempty"`
+}
+
+// Effort returns the requested reasoning effort, preferring reasoning_effort over reasoning.effort.
+func (r *ChatCompletionRequest) Effort() string {
+	if r.ReasoningEffort != "" {
+		return r.ReasoningEffort
+	}
+	if r.Reasoning != nil {
+		return r.Reasoning.Effort
+	}
+	return ""
+}
+
+// StopSequences is the "stop" parameter. On the wire it may be a single string or a list.
+type StopSequences []string
+
+// UnmarshalJSON: accept a plain string as a single stop sequence.
+func (s *StopSequences) UnmarshalJSON(b []byte) error {
+	var str string
+	if err := json.Unmarshal(b, &str); err == nil {
+		if str == "" {
+			*s = nil
+		} else {
+			*s = StopSequences{str}
+		}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*s = list
+	return nil
+}
+
+// ResponseFormat selects the output format: "text", "json_object" or "json_schema".
+type ResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *JSONSchemaFormat `json:"json_schema,omitempty"`
+}
+
+// JSONSchemaFormat describes the schema of a "json_schema" response format.
+type JSONSchemaFormat struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Schema      map[string]interface{} `json:"schema,omitempty"`
+	Strict      *bool                  `json:"strict,omitempty"`
 }
 
 // Message represents a message in the chat history, including tool calls/results.
@@ -26,6 +110,12 @@ type Message struct {
 
 	// Optional function name on tool messages (some clients include this)
 	Name string `json:"name,omitempty"`
+
+	// Model reasoning (thought summaries) on non-streaming assistant responses
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+
+	// Set when Gemini refused to answer (e.g. the prompt was blocked)
+	Refusal string `json:"refusal,omitempty"`
 }
 
 // ContentPart represents a part of a multi-modal message.
@@ -60,9 +150,75 @@ type ChatCompletionResponse struct {
 
 // Choice represents a single choice in a chat completion response.
 type Choice struct {
-	Index        int     `json:"index"`
-	Message      Message `json:"message"`
-	FinishReason string  `json:"finish_reason"`
+	Index        int             `json:"index"`
+	Message      Message         `json:"message"`
+	FinishReason string          `json:"finish_reason"`
+	Logprobs     *ChoiceLogprobs `json:"logprobs,omitempty"`
+}
+
+// ChoiceLogprobs holds the log probabilities of the tokens of a choice (logprobs: true).
+type ChoiceLogprobs struct {
+	Content []TokenLogprob `json:"content"`
+}
+
+// TokenLogprob is the log probability of a sampled token and its most likely alternatives.
+type TokenLogprob struct {
+	Token       string       `json:"token"`
+	Logprob     float64      `json:"logprob"`
+	Bytes       []int        `json:"bytes"`
+	TopLogprobs []TopLogprob `json:"top_logprobs"`
+}
+
+// TopLogprob is an alternative token considered at a position.
+type TopLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+	Bytes   []int   `json:"bytes"`
+}
+
+// LogprobsFromGemini converts a Gemini candidate logprobsResult into OpenAI logprobs.
+// chosenCandidates[i] is the sampled token at position i and topCandidates[i] its alternatives.
+func LogprobsFromGemini(result map[string]interface{}) *ChoiceLogprobs {
+	chosen, _ := result["chosenCandidates"].([]interface{})
+	if len(chosen) == 0 {
+		return nil
+	}
+	top, _ := result["topCandidates"].([]interface{})
+
+	lp := &ChoiceLogprobs{Content: make([]TokenLogprob, 0, len(chosen))}
+	for i, c := range chosen {
+		token, logprob := geminiLogprobCandidate(c)
+		entry := TokenLogprob{Token: token, Logprob: logprob, Bytes: tokenBytes(token), TopLogprobs: []TopLogprob{}}
+		if i < len(top) {
+			if tm, ok := top[i].(map[string]interface{}); ok {
+				alternatives, _ := tm["candidates"].([]interface{})
+				for _, alt := range alternatives {
+					altToken, altLogprob := geminiLogprobCandidate(alt)
+					entry.TopLogprobs = append(entry.TopLogprobs, TopLogprob{Token: altToken, Logprob: altLogprob, Bytes: tokenBytes(altToken)})
+				}
+			}
+		}
+		lp.Content = append(lp.Content, entry)
+	}
+	return lp
+}
+
+func geminiLogprobCandidate(c interface{}) (string, float64) {
+	cm, ok := c.(map[string]interface{})
+	if !ok {
+		return "", 0
+	}
+	token, _ := cm["token"].(string)
+	logprob, _ := cm["logProbability"].(float64)
+	return token, logprob
+}
+
+func tokenBytes(token string) []int {
+	b := make([]int, len(token))
+	for i := 0; i < len(token); i++ {
+		b[i] = int(token[i])
+	}
+	return b
 }
 
 // Usage represents the token usage for a request.
@@ -71,3 +227,36 @@ type Usage struct {
 	CompletionTokens int `json:"completion_tokens"`
 	TotalTokens      int `json:"total_tokens"`
 }
+
+const (
+	FinishReasonStop          = "stop"
+	FinishReasonLength        = "length"
+	FinishReasonToolCalls     = "tool_calls"
+	FinishReasonContentFilter = "content_filter"
+	// FinishReasonError is not part of the OpenAI spec; it follows the convention of
+	// OpenAI-compatible gateways for turns that ended on a model-side failure.
+	FinishReasonError = "error"
+)
+
+// FinishReasonFromGemini maps a Gemini candidate finishReason to an OpenAI finish_reason.
+// Truncation and filtering win over tool calls so clients know the turn is incomplete.
+func FinishReasonFromGemini(finishReason string, hasToolCalls bool) string {
+	switch finishReason {
+	case "MAX_TOKENS":
+		return FinishReasonLength
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+		return FinishReasonContentFilter
+	case "MALFORMED_FUNCTION_CALL", "UNEXPECTED_TOOL_CALL":
+		return FinishReasonError
+	}
+	if hasToolCalls {
+		return FinishReasonToolCalls
+	}
+	return FinishReasonStop
+}
+
+// PromptBlockedRefusal is the refusal text returned when Gemini blocks the prompt
+// (promptFeedback.blockReason) and produces no candidates.
+func PromptBlockedRefusal(blockReason string) string {
+	return fmt.Sprintf("The prompt was blocked by Gemini (blockReason: %s)", blockReason)
+}
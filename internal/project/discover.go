@@ -1,11 +1,8 @@
 package project
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 	"time"
 
 	"github.com/dvcrn/gemini-code-assist-proxy/internal/credentials"
@@ -41,7 +38,8 @@ func Discover(provider credentials.CredentialsProvider, envProjectID string, loa
 func runOnboardingFlow(provider credentials.CredentialsProvider, loadResponse *gemini.LoadCodeAssistResponse) (string, error) {
 	discoveryStartTime := time.Now()
 
-	// No need to get creds here anymore, callEndpoint will do it
+	// The client gets the credentials and retries transient failures of onboardUser
+	client := gemini.NewClient(provider)
 
 	if companionProject := loadResponse.CloudAICompanionProject; companionProject != "" {
 		logger.Get().Info().
@@ -85,7 +83,7 @@ func runOnboardingFlow(provider credentials.CredentialsProvider, loadResponse *g
 
 	// Initial onboarding call
 	onboardCallStart := time.Now()
-	lroResponse, err := callEndpoint(provider, "onboardUser", onboardRequest)
+	lroResponse, err := client.OnboardUser(context.Background(), onboardRequest)
 	if err != nil {
 		return "", fmt.Errorf("failed to call onboardUser: %w", err)
 	}
@@ -125,7 +123,7 @@ func runOnboardingFlow(provider credentials.CredentialsProvider, loadResponse *g
 		time.Sleep(2 * time.Second)
 
 		pollCallStart := time.Now()
-		lroResponse, err = callEndpoint(provider, "onboardUser", onboardRequest)
+		lroResponse, err = client.OnboardUser(context.Background(), onboardRequest)
 		if err != nil {
 			return "", fmt.Errorf("failed to poll onboardUser: %w", err)
 		}
@@ -135,87 +133,3 @@ func runOnboardingFlow(provider credentials.CredentialsProvider, loadResponse *g
 			Msg("Polling call complete")
 	}
 }
-
-func callEndpoint(provider credentials.CredentialsProvider, method string, body interface{}) (map[string]interface{}, error) {
-	callStart := time.Now()
-	defer func() {
-		callDuration := time.Since(callStart)
-		logger.Get().Debug().
-			Str("method", method).
-			Dur("endpoint_call_duration", callDuration).
-			Msg("Code Assist API call complete")
-	}()
-
-	creds, err := provider.GetCredentials()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get credentials: %w", err)
-	}
-	accessToken := creds.AccessToken
-
-	reqBody, err := json.Marshal(body)
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/%s:%s", credentials.CodeAssistEndpoint, credentials.CodeAssistAPIVersion, method), bytes.NewReader(reqBody))
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("Content-Type", "application/json")
-
-	httpClient := &http.Client{}
-	httpStart := time.Now()
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	httpDuration := time.Since(httpStart)
-
-	if resp.StatusCode == http.StatusUnauthorized {
-		resp.Body.Close()
-		logger.Get().Info().Msg("Received 401 Unauthorized, attempting to refresh token...")
-		if err := provider.RefreshToken(); err != nil {
-			return nil, fmt.Errorf("failed to refresh token: %w", err)
-		}
-		refreshedCreds, err := provider.GetCredentials()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get credentials after refresh: %w", err)
-		}
-		accessToken = refreshedCreds.AccessToken
-		req.Header.Set("Authorization", "Bearer "+accessToken)
-
-		// We need to re-create the body reader as it has been consumed
-		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
-
-		resp, err = httpClient.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("failed to make request after token refresh: %w", err)
-		}
-	}
-	defer resp.Body.Close()
-
-	respBody, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	logger.Get().Debug().
-		Str("method", method).
-		Dur("http_duration", httpDuration).
-		Int("status_code", resp.StatusCode).
-		Int("response_size", len(respBody)).
-		Msg("HTTP request complete")
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API call failed with status %d: %s", resp.StatusCode, string(respBody))
-	}
-
-	var result map[string]interface{}
-	if err := json.Unmarshal(respBody, &result); err != nil {
-		return nil, err
-	}
-
-	return result, nil
-}
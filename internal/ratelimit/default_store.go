@@ -0,0 +1,8 @@
+//go:build !js || !wasm
+
+package ratelimit
+
+// NewDefaultStore returns the store for this platform: counters in memory.
+func NewDefaultStore() (Store, error) {
+	return NewMemoryStore(), nil
+}
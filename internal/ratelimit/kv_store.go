@@ -0,0 +1,92 @@
+//go:build js && wasm
+
+package ratelimit
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/syumai/workers/cloudflare/kv"
+)
+
+// kvMinTTL is the shortest expiration Workers KV accepts.
+const kvMinTTL = 60 * time.Second
+
+// kvCounter is a counter as stored in KV. The expiry is kept in the value so that
+// updates do not extend the window.
+type kvCounter struct {
+	Value     int64 `json:"value"`
+	ExpiresAt int64 `json:"expires_at"`
+}
+
+// KVStore keeps counters in Cloudflare KV so that all isolates share them. KV has no atomic
+// increment and is eventually consistent, so concurrent requests can exceed a limit slightly.
+type KVStore struct {
+	kvStore *kv.Namespace
+	now     func() time.Time
+}
+
+// NewDefaultStore returns the store for this platform: the proxy's KV namespace.
+func NewDefaultStore() (Store, error) {
+	kvStore, err := kv.NewNamespace("gemini_code_assist_proxy_kv")
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize KV namespace: %w", err)
+	}
+	return &KVStore{kvStore: kvStore, now: time.Now}, nil
+}
+
+func (s *KVStore) load(key string) (*kvCounter, error) {
+	data, err := s.kvStore.GetString("ratelimit:"+key, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rate limit counter from KV: %w", err)
+	}
+	if data == "" {
+		return nil, nil
+	}
+	var c kvCounter
+	if err := json.Unmarshal([]byte(data), &c); err != nil {
+		return nil, fmt.Errorf("failed to parse rate limit counter from KV: %w", err)
+	}
+	if s.now().Unix() >= c.ExpiresAt {
+		return nil, nil
+	}
+	return &c, nil
+}
+
+func (s *KVStore) Add(key string, delta int64, ttl time.Duration) (int64, error) {
+	c, err := s.load(key)
+	if err != nil {
+		return 0, err
+	}
+	now := s.now()
+	if c == nil {
+		c = &kvCounter{ExpiresAt: now.Add(ttl).Unix()}
+	}
+	c.Value += delta
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal rate limit counter: %w", err)
+	}
+	expiration := c.ExpiresAt
+	if earliest := now.Add(kvMinTTL).Unix(); expiration < earliest {
+		expiration = earliest
+	}
+	if err := s.kvStore.PutString("ratelimit:"+key, string(data), &kv.PutOptions{Expiration: int(expiration)}); err != nil {
+		return 0, fmt.Errorf("failed to store rate limit counter in KV: %w", err)
+	}
+	return c.Value, nil
+}
+
+func (s *KVStore) Get(key string) (int64, error) {
+	c, err := s.load(key)
+	if err != nil || c == nil {
+		return 0, err
+	}
+	return c.Value, nil
+}
+
+func (s *KVStore) Name() string {
+	return "KVStore"
+}
@@ -0,0 +1,155 @@
+// Package ratelimit throttles clients of the proxy: requests per minute, tokens per day and
+// concurrent streams, counted per client API key.
+//
+// Counters live in a Store: in memory for a single process, or in Workers KV where isolates
+// share them on a best effort basis (KV has no atomic increment).
+package ratelimit
+
+import (
+	"fmt"
+	"time"
+)
+
+// Limits are the limits of one client. Zero means unlimited in the global defaults; in the
+// limits of a key, zero falls back to the default and a negative value means unlimited.
+type Limits struct {
+	RequestsPerMinute    int   `json:"requests_per_minute,omitempty"`
+	TokensPerDay         int64 `json:"tokens_per_day,omitempty"`
+	MaxConcurrentStreams int   `json:"max_concurrent_streams,omitempty"`
+}
+
+// WithDefaults returns l with its zero fields taken from defaults.
+func (l *Limits) WithDefaults(defaults Limits) Limits {
+	if l == nil {
+		return defaults
+	}
+	merged := *l
+	if merged.RequestsPerMinute == 0 {
+		merged.RequestsPerMinute = defaults.RequestsPerMinute
+	}
+	if merged.TokensPerDay == 0 {
+		merged.TokensPerDay = defaults.TokensPerDay
+	}
+	if merged.MaxConcurrentStreams == 0 {
+		merged.MaxConcurrentStreams = defaults.MaxConcurrentStreams
+	}
+	return merged
+}
+
+// LimitError is returned when a limit is reached.
+type LimitError struct {
+	Limit      string // "requests_per_minute", "tokens_per_day" or "max_concurrent_streams"
+	Max        int64
+	RetryAfter time.Duration
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("rate limit exceeded: %s (%d), retry after %s", e.Limit, e.Max, e.RetryAfter.Round(time.Second))
+}
+
+// streamTTL bounds how long a stream slot is held if it is never released (e.g. a crashed isolate).
+const streamTTL = time.Hour
+
+// Store holds the counters of the limiter.
+type Store interface {
+	// Add adds delta to the counter and returns the new value. A new counter expires after ttl.
+	Add(key string, delta int64, ttl time.Duration) (int64, error)
+
+	// Get returns the value of the counter, 0 if it does not exist or has expired
+	Get(key string) (int64, error)
+
+	// Name returns the name of the store for logging
+	Name() string
+}
+
+// Limiter enforces Limits on clients identified by a key, e.g. the API key ID.
+type Limiter struct {
+	store    Store
+	defaults Limits
+	now      func() time.Time
+}
+
+// NewLimiter creates a limiter with the given global defaults.
+func NewLimiter(store Store, defaults Limits) *Limiter {
+	return &Limiter{store: store, defaults: defaults, now: time.Now}
+}
+
+// Defaults returns the global default limits.
+func (l *Limiter) Defaults() Limits {
+	return l.defaults
+}
+
+// Allow counts a request of client and checks the requests per minute and the daily token
+// budget. It returns a *LimitError when a limit is reached.
+func (l *Limiter) Allow(client string, limits *Limits) error {
+	lim := limits.WithDefaults(l.defaults)
+	now := l.now().UTC()
+
+	if lim.TokensPerDay > 0 {
+		used, err := l.store.Get(tokensKey(client, now))
+		if err != nil {
+			return err
+		}
+		if used >= lim.TokensPerDay {
+			return &LimitError{Limit: "tokens_per_day", Max: lim.TokensPerDay, RetryAfter: nextDay(now).Sub(now)}
+		}
+	}
+
+	if lim.RequestsPerMinute > 0 {
+		window := now.Truncate(time.Minute)
+		count, err := l.store.Add(fmt.Sprintf("rpm:%s:%d", client, window.Unix()), 1, 2*time.Minute)
+		if err != nil {
+			return err
+		}
+		if count > int64(lim.RequestsPerMinute) {
+			return &LimitError{Limit: "requests_per_minute", Max: int64(lim.RequestsPerMinute), RetryAfter: window.Add(time.Minute).Sub(now)}
+		}
+	}
+
+	return nil
+}
+
+// AcquireStream takes a stream slot of client. The returned release func frees it and must be
+// called when the stream ends.
+func (l *Limiter) AcquireStream(client string, limits *Limits) (release func(), err error) {
+	lim := limits.WithDefaults(l.defaults)
+	if lim.MaxConcurrentStreams <= 0 {
+		return func() {}, nil
+	}
+
+	key := "streams:" + client
+	count, err := l.store.Add(key, 1, streamTTL)
+	if err != nil {
+		return nil, err
+	}
+	if count > int64(lim.MaxConcurrentStreams) {
+		l.store.Add(key, -1, streamTTL)
+		return nil, &LimitError{Limit: "max_concurrent_streams", Max: int64(lim.MaxConcurrentStreams), RetryAfter: time.Second}
+	}
+	return func() { l.store.Add(key, -1, streamTTL) }, nil
+}
+
+// AddTokens adds tokens used by client to its daily budget.
+func (l *Limiter) AddTokens(client string, tokens int64) error {
+	if tokens <= 0 {
+		return nil
+	}
+	now := l.now().UTC()
+	_, err := l.store.Add(tokensKey(client, now), tokens, nextDay(now).Sub(now)+time.Hour)
+	return err
+}
+
+// TokensUsed returns the tokens client used today (UTC).
+func (l *Limiter) TokensUsed(client string) (int64, error) {
+	return l.store.Get(tokensKey(client, l.now().UTC()))
+}
+
+func tokensKey(client string, now time.Time) string {
+	return fmt.Sprintf("tpd:%s:%s", client, now.Format("2006-01-02"))
+}
+
+// nextDay returns the next UTC midnight, when the daily token budget resets.
+func nextDay(now time.Time) time.Time {
+	y, m, d := now.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,105 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testLimiter(defaults Limits) (*Limiter, *time.Time) {
+	store := NewMemoryStore()
+	now := time.Date(2025, 1, 1, 12, 0, 30, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	l := NewLimiter(store, defaults)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestRequestsPerMinute(t *testing.T) {
+	l, now := testLimiter(Limits{RequestsPerMinute: 2})
+
+	require.NoError(t, l.Allow("a", nil))
+	require.NoError(t, l.Allow("a", nil))
+
+	err := l.Allow("a", nil)
+	var limitErr *LimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "requests_per_minute", limitErr.Limit)
+	assert.Equal(t, 30*time.Second, limitErr.RetryAfter)
+
+	// Other clients have their own budget
+	assert.NoError(t, l.Allow("b", nil))
+
+	// The next minute starts a new window
+	*now = now.Add(30 * time.Second)
+	assert.NoError(t, l.Allow("a", nil))
+}
+
+func TestTokensPerDay(t *testing.T) {
+	l, now := testLimiter(Limits{TokensPerDay: 1000})
+
+	require.NoError(t, l.Allow("a", nil))
+	require.NoError(t, l.AddTokens("a", 999))
+	require.NoError(t, l.Allow("a", nil))
+	require.NoError(t, l.AddTokens("a", 10))
+
+	used, err := l.TokensUsed("a")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1009), used)
+
+	err = l.Allow("a", nil)
+	var limitErr *LimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "tokens_per_day", limitErr.Limit)
+	assert.Equal(t, 11*time.Hour+59*time.Minute+30*time.Second, limitErr.RetryAfter)
+
+	// The budget resets at midnight UTC
+	*now = time.Date(2025, 1, 2, 0, 0, 1, 0, time.UTC)
+	assert.NoError(t, l.Allow("a", nil))
+}
+
+func TestConcurrentStreams(t *testing.T) {
+	l, _ := testLimiter(Limits{MaxConcurrentStreams: 1})
+
+	release, err := l.AcquireStream("a", nil)
+	require.NoError(t, err)
+
+	_, err = l.AcquireStream("a", nil)
+	var limitErr *LimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "max_concurrent_streams", limitErr.Limit)
+
+	release()
+	release2, err := l.AcquireStream("a", nil)
+	require.NoError(t, err)
+	release2()
+}
+
+func TestKeyLimitsOverrideDefaults(t *testing.T) {
+	l, _ := testLimiter(Limits{RequestsPerMinute: 1, MaxConcurrentStreams: 1})
+
+	// A negative limit lifts the default; zero keeps it
+	unlimited := &Limits{RequestsPerMinute: -1}
+	for i := 0; i < 5; i++ {
+		require.NoError(t, l.Allow("a", unlimited))
+	}
+	_, err := l.AcquireStream("a", unlimited)
+	require.NoError(t, err)
+	_, err = l.AcquireStream("a", unlimited)
+	assert.Error(t, err)
+
+	stricter := &Limits{TokensPerDay: 10}
+	require.NoError(t, l.AddTokens("b", 10))
+	assert.Error(t, l.Allow("b", stricter))
+}
+
+func TestNoLimits(t *testing.T) {
+	l, _ := testLimiter(Limits{})
+	for i := 0; i < 100; i++ {
+		require.NoError(t, l.Allow("a", nil))
+	}
+	_, err := l.AcquireStream("a", nil)
+	assert.NoError(t, err)
+}
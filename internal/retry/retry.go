@@ -0,0 +1,141 @@
+// Package retry runs upstream calls again after transient failures, with exponential backoff
+// and jitter, honoring the delay the server asked for and the deadline of the request.
+package retry
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/env"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/logger"
+)
+
+// Policy is how often and how patiently a call is retried.
+type Policy struct {
+	// MaxAttempts is the number of attempts including the first; 1 disables retries
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry; it doubles with every retry
+	BaseDelay time.Duration
+	// MaxDelay caps a single wait. A server asking for a longer delay is not retried.
+	MaxDelay time.Duration
+}
+
+// Backoff returns the wait before the given retry (1 for the first): BaseDelay doubled for
+// every previous retry and capped at MaxDelay, of which a random half is jitter.
+func (p Policy) Backoff(retry int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < retry && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+// retryableError marks the error of an attempt as transient.
+type retryableError struct {
+	err   error
+	after time.Duration
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+
+func (e *retryableError) Unwrap() error { return e.err }
+
+// Retryable marks err as transient, so that Do tries again. after is the delay the server
+// asked for (e.g. from google.rpc.RetryInfo), or 0 to use the backoff of the policy.
+func Retryable(err error, after time.Duration) error {
+	return &retryableError{err: err, after: after}
+}
+
+// Do calls fn, numbering attempts from 1, until it succeeds or returns an error that is not
+// marked Retryable, the attempts of p are used up, the server asks for a longer delay than
+// MaxDelay, or waiting would pass the deadline of ctx. The error of the last attempt is
+// returned without the Retryable mark.
+func Do(ctx context.Context, p Policy, fn func(attempt int) error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn(attempt)
+		re, ok := err.(*retryableError)
+		if !ok {
+			return err
+		}
+		if attempt >= p.MaxAttempts {
+			return re.err
+		}
+
+		wait := p.Backoff(attempt)
+		if re.after > 0 {
+			if p.MaxDelay > 0 && re.after > p.MaxDelay {
+				return re.err
+			}
+			wait = re.after
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return re.err
+		}
+
+		logger.Get().Warn().
+			Err(re.err).
+			Int("attempt", attempt).
+			Dur("retry_in", wait).
+			Msg("Upstream call failed, retrying")
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return re.err
+		case <-timer.C:
+		}
+	}
+}
+
+// FromEnv returns the policy of a call type: def, overridden by RETRY_MAX_ATTEMPTS,
+// RETRY_BASE_DELAY and RETRY_MAX_DELAY, then by the same variables naming the call type,
+// e.g. RETRY_GENERATE_CONTENT_MAX_ATTEMPTS for "generateContent".
+func FromEnv(call string, def Policy) Policy {
+	p := def
+	for _, prefix := range []string{"RETRY_", "RETRY_" + envName(call) + "_"} {
+		if str, ok := env.Get(prefix + "MAX_ATTEMPTS"); ok {
+			if n, err := strconv.Atoi(str); err == nil && n >= 1 {
+				p.MaxAttempts = n
+			} else {
+				logger.Get().Warn().Str("value", str).Msgf("Invalid %sMAX_ATTEMPTS, ignoring", prefix)
+			}
+		}
+		for _, field := range []struct {
+			name string
+			dst  *time.Duration
+		}{{"BASE_DELAY", &p.BaseDelay}, {"MAX_DELAY", &p.MaxDelay}} {
+			if str, ok := env.Get(prefix + field.name); ok {
+				if d, err := time.ParseDuration(str); err == nil && d >= 0 {
+					*field.dst = d
+				} else {
+					logger.Get().Warn().Str("value", str).Msgf("Invalid %s%s, ignoring", prefix, field.name)
+				}
+			}
+		}
+	}
+	return p
+}
+
+// envName converts a camelCase call type to UPPER_SNAKE_CASE.
+func envName(call string) string {
+	var sb strings.Builder
+	for i, r := range call {
+		if unicode.IsUpper(r) && i > 0 {
+			sb.WriteByte('_')
+		}
+		sb.WriteRune(unicode.ToUpper(r))
+	}
+	return sb.String()
+}
@@ -0,0 +1,115 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	p := Policy{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+	for i := 0; i < 50; i++ {
+		d := p.Backoff(1)
+		assert.True(t, d >= 50*time.Millisecond && d <= 100*time.Millisecond, "first retry: %s", d)
+		d = p.Backoff(2)
+		assert.True(t, d >= 100*time.Millisecond && d <= 200*time.Millisecond, "second retry: %s", d)
+		d = p.Backoff(10)
+		assert.True(t, d >= 150*time.Millisecond && d <= 300*time.Millisecond, "capped: %s", d)
+	}
+	assert.Equal(t, time.Duration(0), Policy{}.Backoff(1))
+}
+
+func TestDo(t *testing.T) {
+	transient := errors.New("unavailable")
+	fatal := errors.New("bad request")
+	fast := Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+	t.Run("succeeds after transient failures", func(t *testing.T) {
+		var attempts []int
+		err := Do(context.Background(), fast, func(attempt int) error {
+			attempts = append(attempts, attempt)
+			if attempt < 3 {
+				return Retryable(transient, 0)
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []int{1, 2, 3}, attempts)
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		calls := 0
+		err := Do(context.Background(), fast, func(int) error {
+			calls++
+			return Retryable(transient, 0)
+		})
+		assert.Equal(t, transient, err, "the Retryable mark is removed")
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("does not retry other errors", func(t *testing.T) {
+		calls := 0
+		err := Do(context.Background(), fast, func(int) error {
+			calls++
+			return fatal
+		})
+		assert.Equal(t, fatal, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("honors the server delay", func(t *testing.T) {
+		start := time.Now()
+		calls := 0
+		err := Do(context.Background(), Policy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Second}, func(int) error {
+			calls++
+			if calls == 1 {
+				return Retryable(transient, 50*time.Millisecond)
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	})
+
+	t.Run("does not wait longer than MaxDelay", func(t *testing.T) {
+		calls := 0
+		err := Do(context.Background(), fast, func(int) error {
+			calls++
+			return Retryable(transient, time.Hour)
+		})
+		assert.Equal(t, transient, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("stops at the deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		calls := 0
+		err := Do(ctx, Policy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: time.Second}, func(int) error {
+			calls++
+			return Retryable(transient, 0)
+		})
+		assert.Equal(t, transient, err)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestFromEnv(t *testing.T) {
+	def := Policy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	t.Setenv("RETRY_MAX_ATTEMPTS", "4")
+	t.Setenv("RETRY_GENERATE_CONTENT_MAX_ATTEMPTS", "1")
+	t.Setenv("RETRY_GENERATE_CONTENT_MAX_DELAY", "2s")
+	t.Setenv("RETRY_LOAD_CODE_ASSIST_BASE_DELAY", "invalid")
+
+	assert.Equal(t, Policy{MaxAttempts: 1, BaseDelay: time.Second, MaxDelay: 2 * time.Second}, FromEnv("generateContent", def))
+	assert.Equal(t, Policy{MaxAttempts: 4, BaseDelay: time.Second, MaxDelay: 10 * time.Second}, FromEnv("loadCodeAssist", def))
+}
+
+func TestEnvName(t *testing.T) {
+	assert.Equal(t, "STREAM_GENERATE_CONTENT", envName("streamGenerateContent"))
+	assert.Equal(t, "ONBOARD_USER", envName("onboardUser"))
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/accounts"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/tracing"
+)
+
+// generateContent sends req through the account pool, setting the project of the chosen account.
+// The tokens used are charged to the client of ctx.
+func (s *Server) generateContent(ctx context.Context, req *gemini.GenerateContentRequest) (*gemini.GenerateContentResponse, error) {
+	var resp *gemini.GenerateContentResponse
+	err := s.accounts.Do(ctx, func(callCtx context.Context, account *accounts.Account) error {
+		req.Project = account.ProjectID
+		var err error
+		resp, err = account.Client.GenerateContent(callCtx, req)
+		return err
+	})
+	if err == nil {
+		if usage, ok := parseUsageMetadata(unwrapCloudCodeResponse(resp.Response)); ok {
+			s.recordUsage(ctx, req.Model, usage)
+		}
+	}
+	return resp, err
+}
+
+// maxUpstreamStreamDuration bounds an upstream stream, including one that is still being read
+// after its client went away.
+const maxUpstreamStreamDuration = 30 * time.Minute
+
+// streamGenerateContent opens an upstream stream through the account pool. Failover happens
+// before the stream is open, so nothing has been forwarded to out when another account is tried.
+// The tokens used are charged to the client of ctx when the stream ends. Once open, the upstream
+// stream no longer depends on ctx: if the client goes away, the rest of the stream is read
+// without forwarding (for at most maxUpstreamStreamDuration) and the full usage is charged.
+//
+// The relay is traced as an sse.stream span, recording the first upstream event, the longest
+// gap between upstream lines and how long the client side blocked the relay.
+func (s *Server) streamGenerateContent(ctx context.Context, req *gemini.GenerateContentRequest, out chan<- string) error {
+	upstreamCtx, cancelUpstream := context.WithTimeout(context.WithoutCancel(ctx), maxUpstreamStreamDuration)
+	// A client leaving before the stream is open still stops retries and failover
+	stopCancel := context.AfterFunc(ctx, cancelUpstream)
+
+	upstream := make(chan string, cap(out))
+	err := s.accounts.Do(upstreamCtx, func(callCtx context.Context, account *accounts.Account) error {
+		req.Project = account.ProjectID
+		return account.Client.StreamGenerateContent(callCtx, req, upstream)
+	})
+	stopCancel()
+	if err != nil {
+		cancelUpstream()
+		return err
+	}
+
+	_, span := tracing.Start(ctx, "sse.stream", tracing.KindInternal, tracing.String("gen_ai.request.model", req.Model))
+	done := trackRelay(ctx)
+	go func() {
+		defer done()
+		defer cancelUpstream()
+		defer close(out)
+		var usage tokenUsage
+		first, seen := true, false
+		var lines int
+		var maxGap, blocked time.Duration
+		last := time.Now()
+		for line := range upstream {
+			now := time.Now()
+			maxGap = max(maxGap, now.Sub(last))
+			last = now
+			lines++
+			if first && strings.HasPrefix(line, "data: ") {
+				observeFirstToken(ctx)
+				span.AddEvent("first_upstream_event")
+				first = false
+			}
+			if u, ok := usageFromStreamLine(line); ok {
+				usage, seen = u, true
+			}
+			select {
+			case out <- line:
+			case <-ctx.Done():
+				// Nobody reads out anymore; keep reading so the whole stream is charged
+			}
+			blocked += time.Since(now)
+		}
+		if seen {
+			s.recordUsage(ctx, req.Model, usage)
+		}
+		span.SetAttributes(
+			tracing.Int("gcap.stream.lines", lines),
+			tracing.Int64("gcap.stream.max_gap_ms", maxGap.Milliseconds()),
+			tracing.Int64("gcap.stream.client_blocked_ms", blocked.Milliseconds()),
+			tracing.Int64("gen_ai.usage.input_tokens", usage.Prompt),
+			tracing.Int64("gen_ai.usage.output_tokens", usage.Output),
+		)
+		span.End()
+	}()
+	return nil
+}
+
+// countTokens counts tokens through the account pool.
+func (s *Server) countTokens(ctx context.Context, req *gemini.CountTokensRequest) (*gemini.CountTokensResponse, error) {
+	var resp *gemini.CountTokensResponse
+	err := s.accounts.Do(ctx, func(callCtx context.Context, account *accounts.Account) error {
+		var err error
+		resp, err = account.Client.CountTokens(callCtx, req)
+		return err
+	})
+	return resp, err
+}
+
+// accountsHandler handles GET /admin/accounts and lists the account pool with its health
+func (s *Server) accountsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	statuses := s.accounts.Status()
+	healthy := 0
+	for _, st := range statuses {
+		if st.Healthy {
+			healthy++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"accounts": statuses,
+		"total":    len(statuses),
+		"healthy":  healthy,
+	})
+}
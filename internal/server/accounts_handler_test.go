@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/accounts"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/credentials"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
+	serverhttp "github.com/dvcrn/gemini-code-assist-proxy/internal/http"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/ratelimit"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/usage"
+)
+
+type staticProvider struct{}
+
+func (staticProvider) GetCredentials() (*credentials.OAuthCredentials, error) {
+	return &credentials.OAuthCredentials{AccessToken: "token"}, nil
+}
+
+func (staticProvider) SaveCredentials(*credentials.OAuthCredentials) error { return nil }
+
+func (staticProvider) RefreshToken() error { return nil }
+
+func (staticProvider) Name() string { return "static" }
+
+// newTestAccounts returns a pool with one account whose upstream calls go to client.
+func newTestAccounts(client serverhttp.HTTPClient) *accounts.Pool {
+	account := accounts.NewAccount("test", staticProvider{}, "project")
+	account.Client = gemini.NewClientWithHTTPClient(staticProvider{}, client)
+	return accounts.NewPool([]*accounts.Account{account}, accounts.StrategyRoundRobin, time.Minute)
+}
+
+// hostRewriter sends the requests of the Code Assist client to a test server.
+type hostRewriter struct {
+	target *url.URL
+}
+
+func (c hostRewriter) Do(req *http.Request) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.URL.Scheme, out.URL.Host = c.target.Scheme, c.target.Host
+	return http.DefaultClient.Do(out)
+}
+
+func TestStreamGenerateContent_ChargesAbortedStreams(t *testing.T) {
+	t.Setenv("ADMIN_API_KEY", "admin-secret")
+
+	// The upstream sends one event, then the rest of the answer once the client is gone.
+	// It is a real server, so a canceled upstream request ends the stream early.
+	clientGone := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `data: {"response":{"candidates":[{"content":{"parts":[{"text":"Hel"}]}}],"usageMetadata":{"promptTokenCount":20,"candidatesTokenCount":1,"totalTokenCount":21}}}`+"\n\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-clientGone:
+		case <-r.Context().Done():
+			return
+		}
+		io.WriteString(w, `data: {"response":{"candidates":[{"content":{"parts":[{"text":"lo"}]}}],"usageMetadata":{"promptTokenCount":20,"candidatesTokenCount":10,"totalTokenCount":30}}}`+"\n\n")
+	}))
+	defer server.Close()
+	target, _ := url.Parse(server.URL)
+
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), ratelimit.Limits{})
+	ledger := usage.NewMemoryLedger(0)
+	s := &Server{accounts: newTestAccounts(hostRewriter{target: target}), limiter: limiter, ledger: ledger}
+
+	clientCtx, disconnect := context.WithCancel(context.Background())
+	handler := s.inferenceMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		out := make(chan string, 1)
+		if err := s.streamGenerateContent(r.Context(), &gemini.GenerateContentRequest{Model: "gemini-2.5-pro"}, out); err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+		<-out
+		// The client disconnects and the handler stops reading the stream
+		disconnect()
+		close(clientGone)
+	})
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil).WithContext(clientCtx)
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	handler(httptest.NewRecorder(), req)
+
+	used, err := limiter.TokensUsed("admin")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if used != 30 {
+		t.Errorf("expected the 30 tokens of the aborted stream to be charged, got %d", used)
+	}
+	records, _ := ledger.Query(usage.Filter{})
+	if len(records) != 1 || records[0].TotalTokens != 30 || records[0].OutputTokens != 10 {
+		t.Errorf("unexpected ledger records: %+v", records)
+	}
+}
@@ -1,19 +1,67 @@
 package server
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/apikeys"
 	"github.com/dvcrn/gemini-code-assist-proxy/internal/env"
 	"github.com/dvcrn/gemini-code-assist-proxy/internal/logger"
 )
 
-// adminMiddleware checks for valid admin API key from either
-// 'Authorization: Bearer <key>', 'X-Goog-Api-Key: <key>' headers, or 'key' query parameter.
+// adminClientKey is the identity of requests authenticated with ADMIN_API_KEY; it has every scope.
+var adminClientKey = &apikeys.Key{
+	ID:     "admin",
+	Name:   "ADMIN_API_KEY",
+	Scopes: []apikeys.Scope{apikeys.ScopeAdmin, apikeys.ScopeInference},
+}
+
+type clientKeyContextKey struct{}
+
+// clientKey returns the API key that authenticated the request, or nil for unauthenticated routes.
+func clientKey(ctx context.Context) *apikeys.Key {
+	key, _ := ctx.Value(clientKeyContextKey{}).(*apikeys.Key)
+	return key
+}
+
+// modelAllowed reports whether the requesting API key may use one of the given model names
+// (typically the requested and the normalized name).
+func modelAllowed(r *http.Request, models ...string) bool {
+	key := clientKey(r.Context())
+	if key == nil {
+		return true
+	}
+	for _, m := range models {
+		if key.AllowsModel(m) {
+			return true
+		}
+	}
+	return false
+}
+
+// adminMiddleware only lets requests with a key that has the admin scope through.
 func (s *Server) adminMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return s.authMiddleware(apikeys.ScopeAdmin, next)
+}
+
+// inferenceMiddleware only lets requests with a key that has the inference scope through,
+// within the rate limits of the key, and records them in the usage ledger and a trace.
+func (s *Server) inferenceMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return s.tracingMiddleware(s.authMiddleware(apikeys.ScopeInference, s.usageMiddleware(s.rateLimitMiddleware(next))))
+}
+
+// authMiddleware checks for a valid API key from either
+// 'Authorization: Bearer <key>', 'X-Goog-Api-Key: <key>', 'X-Api-Key: <key>' headers, or 'key' query parameter.
+// ADMIN_API_KEY grants every scope; keys from the API key store grant their own scopes.
+func (s *Server) authMiddleware(scope apikeys.Scope, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		adminKey, ok := env.Get("ADMIN_API_KEY")
-		if !ok || adminKey == "" {
+		adminKey, hasAdminKey := env.Get("ADMIN_API_KEY")
+		if !hasAdminKey && !s.hasAPIKeys() {
 			logger.Get().Error().Msg("ADMIN_API_KEY environment variable not set")
 			http.Error(w, "Admin API not configured", http.StatusInternalServerError)
 			return
@@ -22,13 +70,14 @@ func (s *Server) adminMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		var providedToken string
 		authHeader := r.Header.Get("Authorization")
 		googApiKey := r.Header.Get("X-Goog-Api-Key")
+		anthropicApiKey := r.Header.Get("X-Api-Key")
 		keyParam := r.URL.Query().Get("key")
 
 		if authHeader != "" {
 			// Expect "Bearer <token>" format, case-insensitive
 			parts := strings.Split(authHeader, " ")
 			if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
-				logger.Get().Warn().Msgf("Invalid Authorization header format for admin endpoint: %s %s from %s",
+				logger.Get().Warn().Msgf("Invalid Authorization header format: %s %s from %s",
 					r.Method, r.RequestURI, r.RemoteAddr)
 				http.Error(w, "Invalid Authorization header format", http.StatusUnauthorized)
 				return
@@ -37,28 +86,68 @@ func (s *Server) adminMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		} else if googApiKey != "" {
 			// Use X-Goog-Api-Key header directly
 			providedToken = googApiKey
+		} else if anthropicApiKey != "" {
+			// Anthropic SDKs send the key via X-Api-Key
+			providedToken = anthropicApiKey
 		} else if keyParam != "" {
 			// Use the key from query parameter directly
 			providedToken = keyParam
 		} else {
-			logger.Get().Warn().Msgf("Missing required Authorization header, X-Goog-Api-Key header, or key query parameter for admin endpoint: %s %s from %s",
+			logger.Get().Warn().Msgf("Missing required Authorization header, X-Goog-Api-Key header, X-Api-Key header, or key query parameter: %s %s from %s",
 				r.Method, r.RequestURI, r.RemoteAddr)
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		// Verify admin key
-		if providedToken != adminKey {
-			logger.Get().Warn().Msgf("Invalid admin API key provided: %s %s from %s",
+		var key *apikeys.Key
+		if hasAdminKey && secureCompare(providedToken, adminKey) {
+			key = adminClientKey
+		} else if s.apiKeys != nil {
+			var err error
+			key, err = apikeys.Authenticate(s.apiKeys, providedToken, time.Now())
+			if err != nil && !errors.Is(err, apikeys.ErrInvalidKey) && !errors.Is(err, apikeys.ErrExpired) {
+				logger.Get().Error().Err(err).Msg("Failed to look up API key")
+				http.Error(w, "Failed to verify API key", http.StatusInternalServerError)
+				return
+			}
+		}
+		if key == nil {
+			logger.Get().Warn().Msgf("Invalid API key provided: %s %s from %s",
 				r.Method, r.RequestURI, r.RemoteAddr)
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		// Admin authorized
-		logger.Get().Info().Msgf("Admin request authorized: %s %s from %s",
-			r.Method, r.RequestURI, r.RemoteAddr)
+		if !key.HasScope(scope) {
+			logger.Get().Warn().
+				Str("key_id", key.ID).
+				Str("scope", string(scope)).
+				Msgf("API key lacks scope: %s %s from %s", r.Method, r.RequestURI, r.RemoteAddr)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		logger.Get().Info().
+			Str("key_id", key.ID).
+			Str("key_name", key.Name).
+			Msgf("Request authorized: %s %s from %s", r.Method, r.RequestURI, r.RemoteAddr)
+
+		next(w, r.WithContext(context.WithValue(r.Context(), clientKeyContextKey{}, key)))
+	}
+}
 
-		next(w, r)
+// hasAPIKeys reports whether any API key has been created.
+func (s *Server) hasAPIKeys() bool {
+	if s.apiKeys == nil {
+		return false
 	}
+	keys, err := s.apiKeys.List()
+	return err != nil || len(keys) > 0
+}
+
+// secureCompare compares two secrets in constant time. Hashing first also hides their lengths.
+func secureCompare(a, b string) bool {
+	ha := sha256.Sum256([]byte(a))
+	hb := sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
 }
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/apikeys"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/logger"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/ratelimit"
+)
+
+// apiKeyView is an API key as returned by the admin endpoints; the hash is never exposed.
+type apiKeyView struct {
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	Prefix     string            `json:"prefix"`
+	Scopes     []apikeys.Scope   `json:"scopes"`
+	Models     []string          `json:"models,omitempty"`
+	RateLimits *ratelimit.Limits `json:"rate_limits,omitempty"`
+	ExpiresAt  *time.Time        `json:"expires_at,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	Expired    bool              `json:"expired"`
+	Key        string            `json:"key,omitempty"` // only set in the response that creates the key
+}
+
+func newAPIKeyView(k *apikeys.Key) apiKeyView {
+	return apiKeyView{
+		ID:         k.ID,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     k.Scopes,
+		Models:     k.Models,
+		RateLimits: k.RateLimits,
+		ExpiresAt:  k.ExpiresAt,
+		CreatedAt:  k.CreatedAt,
+		Expired:    k.Expired(time.Now()),
+	}
+}
+
+// apiKeyRequest is the body of POST /admin/keys and PATCH /admin/keys/{id}.
+// Fields left out keep their current value (or the default on creation).
+type apiKeyRequest struct {
+	Name       *string           `json:"name"`
+	Scopes     []apikeys.Scope   `json:"scopes"`
+	Models     *[]string         `json:"models"`
+	RateLimits *ratelimit.Limits `json:"rate_limits"`
+	ExpiresAt  *time.Time        `json:"expires_at"`
+	ExpiresIn  string            `json:"expires_in"` // Go duration, e.g. "720h"; alternative to expires_at
+}
+
+// expiry returns the expiry requested by expires_at or expires_in, or nil if neither is set.
+func (req *apiKeyRequest) expiry() (*time.Time, error) {
+	if req.ExpiresIn == "" {
+		return req.ExpiresAt, nil
+	}
+	d, err := time.ParseDuration(req.ExpiresIn)
+	if err != nil || d <= 0 {
+		return nil, errors.New("expires_in must be a positive duration, e.g. 720h")
+	}
+	t := time.Now().Add(d).UTC()
+	return &t, nil
+}
+
+// apiKeysHandler handles GET /admin/keys (list) and POST /admin/keys (create)
+func (s *Server) apiKeysHandler(w http.ResponseWriter, r *http.Request) {
+	if s.apiKeys == nil {
+		http.Error(w, "API key store not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		keys, err := s.apiKeys.List()
+		if err != nil {
+			logger.Get().Error().Err(err).Msg("Failed to list API keys")
+			http.Error(w, "Failed to list API keys", http.StatusInternalServerError)
+			return
+		}
+		views := make([]apiKeyView, 0, len(keys))
+		for _, k := range keys {
+			views = append(views, newAPIKeyView(k))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": views})
+
+	case http.MethodPost:
+		var req apiKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.Name == nil || *req.Name == "" {
+			http.Error(w, "name is required", http.StatusBadRequest)
+			return
+		}
+		scopes := req.Scopes
+		if len(scopes) == 0 {
+			scopes = []apikeys.Scope{apikeys.ScopeInference}
+		}
+		var models []string
+		if req.Models != nil {
+			models = *req.Models
+		}
+		expiresAt, err := req.expiry()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		key, token, err := apikeys.Generate(*req.Name, scopes, models, expiresAt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		key.RateLimits = req.RateLimits
+		if err := s.apiKeys.Put(key); err != nil {
+			logger.Get().Error().Err(err).Msg("Failed to save API key")
+			http.Error(w, "Failed to save API key", http.StatusInternalServerError)
+			return
+		}
+
+		logger.Get().Info().
+			Str("key_id", key.ID).
+			Str("key_name", key.Name).
+			Interface("scopes", key.Scopes).
+			Msg("API key created")
+
+		view := newAPIKeyView(key)
+		view.Key = token
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(view)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// apiKeyHandler handles GET, PATCH and DELETE /admin/keys/{id}
+func (s *Server) apiKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if s.apiKeys == nil {
+		http.Error(w, "API key store not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	// e.g. /admin/keys/key_0123456789abcdef
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) != 3 {
+		http.NotFound(w, r)
+		return
+	}
+	id := pathParts[2]
+
+	key, err := s.apiKeys.Get(id)
+	if errors.Is(err, apikeys.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		logger.Get().Error().Err(err).Str("key_id", id).Msg("Failed to get API key")
+		http.Error(w, "Failed to get API key", http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(newAPIKeyView(key))
+
+	case http.MethodPatch:
+		var req apiKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.Name != nil {
+			key.Name = *req.Name
+		}
+		if req.Scopes != nil {
+			for _, sc := range req.Scopes {
+				if !apikeys.ValidScope(sc) {
+					http.Error(w, "unknown scope "+string(sc), http.StatusBadRequest)
+					return
+				}
+			}
+			key.Scopes = req.Scopes
+		}
+		if req.Models != nil {
+			key.Models = *req.Models
+		}
+		if req.ExpiresAt != nil || req.ExpiresIn != "" {
+			expiresAt, err := req.expiry()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			key.ExpiresAt = expiresAt
+		}
+		if req.RateLimits != nil {
+			key.RateLimits = req.RateLimits
+		}
+
+		if err := s.apiKeys.Put(key); err != nil {
+			logger.Get().Error().Err(err).Str("key_id", id).Msg("Failed to update API key")
+			http.Error(w, "Failed to update API key", http.StatusInternalServerError)
+			return
+		}
+		logger.Get().Info().Str("key_id", id).Msg("API key updated")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(newAPIKeyView(key))
+
+	case http.MethodDelete:
+		if err := s.apiKeys.Delete(id); err != nil {
+			logger.Get().Error().Err(err).Str("key_id", id).Msg("Failed to delete API key")
+			http.Error(w, "Failed to delete API key", http.StatusInternalServerError)
+			return
+		}
+		logger.Get().Info().Str("key_id", id).Msg("API key revoked")
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,120 @@
+//go:build !js || !wasm
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/apikeys"
+)
+
+func newAPIKeyTestServer(t *testing.T) *Server {
+	t.Helper()
+	t.Setenv("ADMIN_API_KEY", "admin-secret")
+	s := &Server{
+		mux:     http.NewServeMux(),
+		apiKeys: apikeys.NewFileStore(filepath.Join(t.TempDir(), "keys.json")),
+	}
+	s.setupRoutes()
+	return s
+}
+
+func doAPIKeyRequest(s *Server, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPIKeysCRUD(t *testing.T) {
+	s := newAPIKeyTestServer(t)
+
+	rec := doAPIKeyRequest(s, http.MethodPost, "/admin/keys", "admin-secret", `{"name": "ci", "models": ["gemini-2.5-flash"], "expires_in": "24h"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created apiKeyView
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if created.Key == "" || created.ID == "" || created.ExpiresAt == nil {
+		t.Fatalf("unexpected created key: %+v", created)
+	}
+	if len(created.Scopes) != 1 || created.Scopes[0] != apikeys.ScopeInference {
+		t.Errorf("expected default inference scope, got %v", created.Scopes)
+	}
+
+	rec = doAPIKeyRequest(s, http.MethodGet, "/admin/keys", "admin-secret", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list: expected 200, got %d", rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), created.Key) || strings.Contains(rec.Body.String(), `"hash"`) {
+		t.Errorf("list must not expose the token or its hash: %s", rec.Body.String())
+	}
+
+	rec = doAPIKeyRequest(s, http.MethodPatch, "/admin/keys/"+created.ID, "admin-secret", `{"name": "renamed"}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"renamed"`) {
+		t.Fatalf("patch: expected 200 with new name, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doAPIKeyRequest(s, http.MethodDelete, "/admin/keys/"+created.ID, "admin-secret", "")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d", rec.Code)
+	}
+	rec = doAPIKeyRequest(s, http.MethodGet, "/admin/keys/"+created.ID, "admin-secret", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("get after delete: expected 404, got %d", rec.Code)
+	}
+}
+
+func TestAPIKeyScopesAndModels(t *testing.T) {
+	s := newAPIKeyTestServer(t)
+
+	rec := doAPIKeyRequest(s, http.MethodPost, "/admin/keys", "admin-secret", `{"name": "ci", "models": ["gemini-2.5-flash"]}`)
+	var created apiKeyView
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		want   int
+	}{
+		{"no key", http.MethodGet, "/admin/keys", "", "", http.StatusUnauthorized},
+		{"unknown key", http.MethodGet, "/admin/keys", "gcap_unknown", "", http.StatusUnauthorized},
+		{"inference key on admin route", http.MethodGet, "/admin/keys", created.Key, "", http.StatusForbidden},
+		{"model not allowed on chat", http.MethodPost, "/v1/chat/completions", created.Key, `{"model": "gemini-2.5-pro", "messages": []}`, http.StatusForbidden},
+		{"model not allowed on messages", http.MethodPost, "/v1/messages", created.Key, `{"model": "gemini-2.5-pro", "max_tokens": 1, "messages": []}`, http.StatusForbidden},
+		{"model not allowed on responses", http.MethodPost, "/v1/responses", created.Key, `{"model": "gemini-2.5-pro", "input": "hi"}`, http.StatusForbidden},
+		{"model not allowed on native", http.MethodPost, "/v1beta/models/gemini-2.5-pro:generateContent", created.Key, `{}`, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doAPIKeyRequest(s, tt.method, tt.path, tt.token, tt.body)
+			if rec.Code != tt.want {
+				t.Errorf("expected %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestSecureCompare(t *testing.T) {
+	if !secureCompare("secret", "secret") {
+		t.Error("expected equal secrets to match")
+	}
+	if secureCompare("secret", "secret2") || secureCompare("", "secret") {
+		t.Error("expected different secrets not to match")
+	}
+}
@@ -5,11 +5,14 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/env"
 	"github.com/dvcrn/gemini-code-assist-proxy/internal/logger"
 	"github.com/dvcrn/gemini-code-assist-proxy/internal/openai"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/tracing"
 	"github.com/dvcrn/gemini-code-assist-proxy/internal/transform"
 )
 
@@ -28,7 +31,7 @@ func (s *Server) openAIChatCompletionsHandler(w http.ResponseWriter, r *http.Req
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		logger.Get().Error().Err(err).Msg("Error reading request body")
-		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "Error reading request body")
 		return
 	}
 	defer r.Body.Close()
@@ -37,7 +40,7 @@ func (s *Server) openAIChatCompletionsHandler(w http.ResponseWriter, r *http.Req
 	var req openai.ChatCompletionRequest
 	if err := json.Unmarshal(body, &req); err != nil {
 		logger.Get().Error().Err(err).Msg("Error parsing request body")
-		http.Error(w, "Error parsing request body", http.StatusBadRequest)
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "Error parsing request body")
 		return
 	}
 
@@ -49,6 +52,13 @@ func (s *Server) openAIChatCompletionsHandler(w http.ResponseWriter, r *http.Req
 		Int("tools", len(req.Tools)).
 		Msg("Parsed OpenAI request")
 
+	noteRequest(r, req.Model, normalizeModelName(req.Model), req.Stream)
+	if !modelAllowed(r, req.Model, normalizeModelName(req.Model)) {
+		logger.Get().Warn().Str("model", req.Model).Msg("Model not allowed for API key")
+		writeOpenAIError(w, http.StatusForbidden, "invalid_request_error", "Model not allowed for this API key: "+req.Model)
+		return
+	}
+
 	// Log tool result messages present in the request (tool outputs from client)
 	toolMsgCount := 0
 	for i, m := range req.Messages {
@@ -98,6 +108,9 @@ func (s *Server) openAIChatCompletionsHandler(w http.ResponseWriter, r *http.Req
 		Int("tool_messages", toolMsgCount).
 		Msg("Tool result message count")
 
+	// Gemini needs the thought signatures of earlier function calls back
+	s.restoreThoughtSignatures(req.Messages)
+
 	// Delegate to stream or non-stream handler
 	if req.Stream {
 		s.chatCompletionRequestStream(w, r, req, startTime)
@@ -108,11 +121,13 @@ func (s *Server) openAIChatCompletionsHandler(w http.ResponseWriter, r *http.Req
 
 // chatCompletionRequestStream handles the streaming variant (existing behavior).
 func (s *Server) chatCompletionRequestStream(w http.ResponseWriter, r *http.Request, req openai.ChatCompletionRequest, startTime time.Time) {
-	// Transform OpenAI -> Gemini
-	gemReq, err := transform.ToGeminiRequest(&req, s.projectID)
+	// Transform OpenAI -> Gemini; the project is set by the account the request is dispatched to
+	endTransform := traceStep(r.Context(), "transform.request", tracing.String("gcap.api", "openai.chat_completions"))
+	gemReq, err := transform.ToGeminiRequest(&req, "")
 	if err != nil {
+		endTransform(err)
 		logger.Get().Error().Err(err).Msg("Failed to transform OpenAI request to Gemini request")
-		http.Error(w, "Failed to transform request", http.StatusInternalServerError)
+		writeOpenAIError(w, http.StatusInternalServerError, "server_error", "Failed to transform request")
 		return
 	}
 
@@ -126,8 +141,37 @@ func (s *Server) chatCompletionRequestStream(w http.ResponseWriter, r *http.Requ
 			Str("normalized_model", normalizedModelName).
 			Msg("Normalized model for CloudCode")
 	}
+	applyReasoningEffort(&gemReq.Request, gemReq.Model, req.Effort())
+	sanitizeGeminiRequest(&gemReq.Request, gemReq.Model)
+	toolNames := transform.SanitizeToolNames(&gemReq.Request)
+	endTransform(nil)
+
+	if err := s.resolveRemoteMedia(r.Context(), &gemReq.Request); err != nil {
+		logger.Get().Error().Err(err).Msg("Failed to resolve remote media")
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	release, ok := s.acquireStream(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+
+	// Start upstream streaming from Gemini
+	upstream := make(chan string, 32)
+	logger.Get().Info().
+		Str("model", gemReq.Model).
+		Msg("Starting upstream StreamGenerateContent")
+
+	if err := s.streamGenerateContent(r.Context(), gemReq, upstream); err != nil {
+		logger.Get().Error().Err(err).Msg("StreamGenerateContent call failed")
+		writeUpstreamError(w, r, err)
+		return
+	}
+	logger.Get().Info().Msg("Upstream StreamGenerateContent started")
 
-	// Prepare SSE response
+	// Prepare SSE response; headers are only committed once the upstream stream is open
 	w.Header().Del("Content-Length")
 	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
 	w.Header().Set("Cache-Control", "no-cache")
@@ -144,12 +188,6 @@ func (s *Server) chatCompletionRequestStream(w http.ResponseWriter, r *http.Requ
 		logger.Get().Info().Msg("SSE flusher not available; relying on implicit streaming")
 	}
 
-	// Start upstream streaming from Gemini
-	upstream := make(chan string, 32)
-	logger.Get().Info().
-		Str("model", gemReq.Model).
-		Msg("Starting upstream StreamGenerateContent")
-
 	// Pinger to keep connection alive
 	pingerCtx, cancelPinger := context.WithCancel(r.Context())
 	defer cancelPinger()
@@ -174,204 +212,15 @@ func (s *Server) chatCompletionRequestStream(w http.ResponseWriter, r *http.Requ
 		}
 	}()
 
-	if err := s.geminiClient.StreamGenerateContent(r.Context(), gemReq, upstream); err != nil {
-		logger.Get().Error().Err(err).Msg("StreamGenerateContent call failed")
-		http.Error(w, "Upstream streaming error", http.StatusInternalServerError)
-		return
-	}
-	logger.Get().Info().Msg("Upstream StreamGenerateContent started")
-
 	// Adapter: CloudCode SSE -> StreamChunk (model text, tool calls, usage, etc.)
-	chunkIn := make(chan openai.StreamChunk, 32)
-	go func() {
-		defer close(chunkIn)
-		firstUpstream := true
-		firstThoughtSeen := false
-		for line := range upstream {
-			if firstUpstream {
-				cancelPinger() // Stop pinger on first data
-			}
-			// Process only data lines
-			if !strings.HasPrefix(line, "data: ") {
-				continue
-			}
-
-			if firstUpstream {
-				logger.Get().Info().
-					Dur("time_to_first_upstream_line", time.Since(startTime)).
-					Msg("First upstream SSE line received")
-				firstUpstream = false
-			}
-
-			// Transform CloudCode wrapper to standard Gemini-format event
-			transformed := TransformSSELine(line)
-			data := strings.TrimSpace(strings.TrimPrefix(transformed, "data: "))
-
-			// Handle upstream DONE
-			if data == "" || data == "[DONE]" || data == "\"[DONE]\"" {
-				logger.Get().Info().Msg("Received upstream DONE")
-				break
-			}
-
-			// Parse JSON payload
-			var obj map[string]interface{}
-			if err := json.Unmarshal([]byte(data), &obj); err != nil {
-				// Fallback: forward as plain text chunk
-				logger.Get().Debug().Err(err).Msg("Failed to parse SSE JSON; forwarding as text")
-				chunkIn <- openai.StreamChunk{Type: "text", Data: data}
-				continue
-			}
-
-			// Usage metadata (optional)
-			if um, ok := obj["usageMetadata"].(map[string]interface{}); ok {
-				payload := map[string]interface{}{}
-				if v, ok := um["promptTokenCount"]; ok {
-					payload["inputTokens"] = v
-				}
-				if v, ok := um["candidatesTokenCount"]; ok {
-					payload["outputTokens"] = v
-				}
-				chunkIn <- openai.StreamChunk{Type: "usage", Data: payload}
-			}
-
-			// Extract candidate content parts
-			if cands, ok := obj["candidates"].([]interface{}); ok {
-				for _, c := range cands {
-					cand, ok := c.(map[string]interface{})
-					if !ok {
-						logger.Get().Warn().Interface("candidate", c).Msg("Skipping invalid candidate in Gemini stream")
-						continue
-					}
-
-					// Optional grounding metadata passthrough
-					if gm, ok := cand["groundingMetadata"]; ok && gm != nil {
-						chunkIn <- openai.StreamChunk{Type: "grounding_metadata", Data: gm}
-					}
-
-					// Retrieve parts
-					var parts []interface{}
-					if content, ok := cand["content"].(map[string]interface{}); ok {
-						if ps, ok := content["parts"].([]interface{}); ok {
-							parts = ps
-						}
-					}
-					if len(parts) == 0 {
-						if ps, ok := cand["parts"].([]interface{}); ok {
-							parts = ps
-						}
-					}
-
-					// Process parts
-					for _, p := range parts {
-						part, ok := p.(map[string]interface{})
-						if !ok {
-							logger.Get().Warn().Interface("part", p).Msg("Skipping invalid part in Gemini stream")
-							continue
-						}
-
-						// Thought tokens (reasoning) — map to OpenAI reasoning stream
-						if isThought, ok := part["thought"].(bool); ok && isThought {
-							if txt, ok := part["text"].(string); ok && txt != "" {
-								if !firstThoughtSeen {
-									preview := txt
-									if len(preview) > 300 {
-										preview = preview[:300] + "..."
-									}
-									logger.Get().Info().
-										Int("len", len(txt)).
-										Str("preview", preview).
-										Msg("Streaming thinking tokens detected")
-									firstThoughtSeen = true
-								}
-								logger.Get().Debug().
-									Str("token", txt).
-									Msg("SSE thought token received")
-								chunkIn <- openai.StreamChunk{Type: "real_thinking", Data: txt}
-							}
-							// Skip normal text handling to avoid duplicating this token
-							continue
-						}
-
-						// Text tokens — log per token at DEBUG
-						if txt, ok := part["text"].(string); ok && txt != "" {
-							logger.Get().Debug().
-								Str("token", txt).
-								Msg("SSE text token received")
-							chunkIn <- openai.StreamChunk{Type: "text", Data: txt}
-						}
-
-						// Function call parts
-						if fc, ok := part["functionCall"].(map[string]interface{}); ok {
-							rawName, _ := fc["name"].(string)
-							name := strings.TrimSpace(rawName)
-
-							// Robust args extraction without client-specific normalization
-							var args map[string]interface{}
-							var source string
-							tryParse := func(val interface{}, key string) bool {
-								switch v := val.(type) {
-								case map[string]interface{}:
-									args = v
-									source = key
-									return true
-								case string:
-									var m map[string]interface{}
-									if err := json.Unmarshal([]byte(v), &m); err == nil {
-										args = m
-										source = key + " (json)"
-										return true
-									}
-								}
-								return false
-							}
-							if !tryParse(fc["args"], "args") &&
-								!tryParse(fc["argsJson"], "argsJson") &&
-								!tryParse(fc["arguments"], "arguments") &&
-								!tryParse(fc["parameters"], "parameters") {
-								args = map[string]interface{}{}
-								source = "default_empty"
-							}
-
-							// Log tool call inputs (preview at INFO, full JSON at DEBUG)
-							argsJSON, _ := json.Marshal(args)
-							argsPreview := string(argsJSON)
-							if len(argsPreview) > 300 {
-								argsPreview = argsPreview[:300] + "..."
-							}
-							logger.Get().Info().
-								Str("function", name).
-								Int("arg_keys", len(args)).
-								Str("args_preview", argsPreview).
-								Msg("Tool call inputs")
-							logger.Get().Debug().
-								Str("function", name).
-								RawJSON("args", argsJSON).
-								Str("args_source", source).
-								Msg("Tool call full args")
-
-							logger.Get().Info().
-								Str("function", name).
-								Str("args_source", source).
-								Int("arg_keys", len(args)).
-								Msg("Emitting tool call from model")
-
-							// Emit tool call to OpenAI transformer
-							chunkIn <- openai.StreamChunk{
-								Type: "tool_code",
-								Data: map[string]interface{}{
-									"name": name,
-									"args": args,
-								},
-							}
-						}
-					}
-				}
-			}
-		}
-	}()
+	chunkIn := geminiStreamToChunks(upstream, toolNames, startTime, cancelPinger)
 
 	// Transform chunks into OpenAI-compatible SSE and stream to client
-	transformer := openai.CreateOpenAIStreamTransformer(req.Model)
+	streamOpts := []openai.StreamOption{openai.WithThoughtSignatureRecorder(s.rememberThoughtSignature)}
+	if n, err := strconv.Atoi(env.GetOrDefault("TOOL_CALL_ARGS_CHUNK_SIZE", "0")); err == nil && n > 0 {
+		streamOpts = append(streamOpts, openai.WithToolArgumentsChunkSize(n))
+	}
+	transformer := openai.CreateOpenAIStreamTransformer(req.Model, streamOpts...)
 	out := transformer(chunkIn)
 
 	firstWrite := true
@@ -399,11 +248,13 @@ func (s *Server) chatCompletionRequestStream(w http.ResponseWriter, r *http.Requ
 
 // chatCompletionRequest handles the non-streaming variant via GenerateContent and returns OpenAI-style JSON.
 func (s *Server) chatCompletionRequest(w http.ResponseWriter, r *http.Request, req openai.ChatCompletionRequest, startTime time.Time) {
-	// Transform OpenAI -> Gemini
-	gemReq, err := transform.ToGeminiRequest(&req, s.projectID)
+	// Transform OpenAI -> Gemini; the project is set by the account the request is dispatched to
+	endTransform := traceStep(r.Context(), "transform.request", tracing.String("gcap.api", "openai.chat_completions"))
+	gemReq, err := transform.ToGeminiRequest(&req, "")
 	if err != nil {
+		endTransform(err)
 		logger.Get().Error().Err(err).Msg("Failed to transform OpenAI request to Gemini request")
-		http.Error(w, "Failed to transform request", http.StatusInternalServerError)
+		writeOpenAIError(w, http.StatusInternalServerError, "server_error", "Failed to transform request")
 		return
 	}
 
@@ -417,84 +268,38 @@ func (s *Server) chatCompletionRequest(w http.ResponseWriter, r *http.Request, r
 			Str("normalized_model", normalizedModelName).
 			Msg("Normalized model for CloudCode")
 	}
+	applyReasoningEffort(&gemReq.Request, gemReq.Model, req.Effort())
+	sanitizeGeminiRequest(&gemReq.Request, gemReq.Model)
+	toolNames := transform.SanitizeToolNames(&gemReq.Request)
+	endTransform(nil)
+
+	if err := s.resolveRemoteMedia(r.Context(), &gemReq.Request); err != nil {
+		logger.Get().Error().Err(err).Msg("Failed to resolve remote media")
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
 
 	// Call non-streaming GenerateContent
 	apiStart := time.Now()
-	resp, err := s.geminiClient.GenerateContent(gemReq)
+	resp, err := s.generateContent(r.Context(), gemReq)
 	if err != nil {
 		logger.Get().Error().Err(err).Dur("api_call_duration", time.Since(apiStart)).Msg("GenerateContent failed")
-		http.Error(w, "Error calling GenerateContent", http.StatusInternalServerError)
+		writeUpstreamError(w, r, err)
 		return
 	}
 
-	// Extract assistant text content from first candidate
-	var contentText string
-	if resp != nil && resp.Response != nil {
-		if cands, ok := resp.Response["candidates"].([]interface{}); ok && len(cands) > 0 {
-			if first, ok := cands[0].(map[string]interface{}); ok {
-				// parts may be under content.parts or parts
-				var parts []interface{}
-				if c, ok := first["content"].(map[string]interface{}); ok {
-					if ps, ok := c["parts"].([]interface{}); ok {
-						parts = ps
-					}
-				}
-				if len(parts) == 0 {
-					if ps, ok := first["parts"].([]interface{}); ok {
-						parts = ps
-					}
-				}
-				var b strings.Builder
-				for _, p := range parts {
-					if pm, ok := p.(map[string]interface{}); ok {
-						if txt, ok := pm["text"].(string); ok && txt != "" {
-							if b.Len() > 0 {
-								b.WriteString("\n")
-							}
-							b.WriteString(txt)
-						}
-					}
-				}
-				contentText = b.String()
-			}
-		}
-	}
-
-	// Build OpenAI-style response
-	created := time.Now().Unix()
-	openAIResp := map[string]interface{}{
-		"id":      "chatcmpl",
-		"object":  "chat.completion",
-		"created": created,
-		"model":   req.Model,
-		"choices": []map[string]interface{}{
-			{
-				"index": 0,
-				"message": map[string]interface{}{
-					"role":    "assistant",
-					"content": contentText,
-				},
-				"finish_reason": "stop",
-			},
-		},
+	toolNames.RestoreResponse(resp.Response)
+	endTransform = traceStep(r.Context(), "transform.response", tracing.String("gcap.api", "openai.chat_completions"))
+	openAIResp, err := transform.ToOpenAIChatCompletionResponse(resp, req.Model)
+	endTransform(err)
+	if err != nil {
+		logger.Get().Error().Err(err).Msg("Failed to transform Gemini response to OpenAI response")
+		writeOpenAIError(w, http.StatusInternalServerError, "server_error", "Failed to transform response")
+		return
 	}
-
-	// Include usage if available
-	if resp != nil && resp.Response != nil {
-		if um, ok := resp.Response["usageMetadata"].(map[string]interface{}); ok {
-			prompt := 0
-			comp := 0
-			if v, ok := um["promptTokenCount"].(float64); ok {
-				prompt = int(v)
-			}
-			if v, ok := um["candidatesTokenCount"].(float64); ok {
-				comp = int(v)
-			}
-			openAIResp["usage"] = map[string]interface{}{
-				"prompt_tokens":     prompt,
-				"completion_tokens": comp,
-				"total_tokens":      prompt + comp,
-			}
+	for _, choice := range openAIResp.Choices {
+		for _, tc := range choice.Message.ToolCalls {
+			s.rememberThoughtSignature(tc.ID, tc.ThoughtSignature)
 		}
 	}
 
@@ -141,3 +141,111 @@ func sanitizeGeminiRequest(r *gemini.GeminiInternalRequest, model string) {
 		}
 	}
 }
+
+// thinkingBudgets maps reasoning efforts to thinkingBudget for Gemini 2.5 models.
+var thinkingBudgets = map[string]int{
+	"none":    128,
+	"minimal": 128,
+	"low":     1024,
+	"medium":  8192,
+	"high":    24576,
+}
+
+// applyReasoningEffort maps an OpenAI reasoning effort ("none", "minimal", "low", "medium", "high")
+// onto thinkingConfig for the normalized model: thinkingLevel for Gemini 3, thinkingBudget for 2.5.
+// Without an effort the per-model default from DEFAULT_THINKING_LEVEL applies, unless the request
+// already configures thinking. "none" keeps the smallest setting but hides thoughts, since
+// sanitizeGeminiRequest never lets a request disable thinking outright; only Flash-Lite, which
+// does not think by default, gets a budget of 0.
+func applyReasoningEffort(r *gemini.GeminiInternalRequest, model, effort string) {
+	if r == nil {
+		return
+	}
+	effort = strings.ToLower(strings.TrimSpace(effort))
+	if effort == "" {
+		if r.GenerationConfig != nil && r.GenerationConfig.ThinkingConfig != nil {
+			return
+		}
+		effort = defaultThinkingLevel(model)
+		if effort == "" {
+			return
+		}
+	}
+	if _, ok := thinkingBudgets[effort]; !ok {
+		logger.Get().Warn().Str("effort", effort).Msg("Ignoring unknown reasoning effort")
+		return
+	}
+
+	cfg := &gemini.ThinkingConfig{IncludeThoughts: effort != "none"}
+	if strings.HasPrefix(model, "gemini-3") {
+		cfg.ThinkingLevel = thinkingLevelForModel(model, effort)
+	} else {
+		budget := thinkingBudgetForModel(model, effort)
+		cfg.ThinkingBudget = &budget
+	}
+
+	if r.GenerationConfig == nil {
+		r.GenerationConfig = &gemini.GeminiGenerationConfig{}
+	}
+	if existing := r.GenerationConfig.ThinkingConfig; existing != nil {
+		cfg.Extra = existing.Extra
+	}
+	r.GenerationConfig.ThinkingConfig = cfg
+	logger.Get().Debug().
+		Str("model", model).
+		Str("effort", effort).
+		Str("thinking_level", cfg.ThinkingLevel).
+		Msg("Applied reasoning effort")
+}
+
+// thinkingLevelForModel picks the Gemini 3 thinkingLevel for an effort. Flash models accept
+// minimal/low/medium/high; Pro models only low and high.
+func thinkingLevelForModel(model, effort string) string {
+	if strings.Contains(model, "flash") {
+		if effort == "none" {
+			return "minimal"
+		}
+		return effort
+	}
+	switch effort {
+	case "medium", "high":
+		return "high"
+	default:
+		return "low"
+	}
+}
+
+// thinkingBudgetForModel picks the Gemini 2.5 thinkingBudget for an effort. Flash-Lite only
+// accepts 0 (no thinking) or 512 to 24576.
+func thinkingBudgetForModel(model, effort string) int {
+	budget := thinkingBudgets[effort]
+	if strings.HasPrefix(model, "gemini-2.5-flash-lite") {
+		if effort == "none" {
+			return 0
+		}
+		return min(max(budget, 512), 24576)
+	}
+	return budget
+}
+
+// defaultThinkingLevel returns the configured default effort for model. DEFAULT_THINKING_LEVEL is
+// either a single effort for all models ("high") or a comma-separated list of model=effort
+// pairs matched against the normalized model name ("gemini-3-pro=high,gemini-2.5-flash=low").
+func defaultThinkingLevel(model string) string {
+	fallback := ""
+	for _, entry := range strings.Split(env.GetOrDefault("DEFAULT_THINKING_LEVEL", ""), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, level, ok := strings.Cut(entry, "=")
+		if !ok {
+			fallback = strings.ToLower(entry)
+			continue
+		}
+		if strings.TrimSpace(name) == model {
+			return strings.ToLower(strings.TrimSpace(level))
+		}
+	}
+	return fallback
+}
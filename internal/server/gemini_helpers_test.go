@@ -1,6 +1,10 @@
 package server
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
+)
 
 func TestNormalizeModelName(t *testing.T) {
 	testCases := []struct {
@@ -93,3 +97,111 @@ func TestNormalizeModelName(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyReasoningEffort(t *testing.T) {
+	testCases := []struct {
+		name            string
+		model           string
+		effort          string
+		expectedLevel   string
+		expectedBudget  int
+		includeThoughts bool
+	}{
+		{name: "gemini 3 pro high", model: "gemini-3-pro", effort: "high", expectedLevel: "high", includeThoughts: true},
+		{name: "gemini 3 pro medium rounds up", model: "gemini-3.1-pro-preview", effort: "medium", expectedLevel: "high", includeThoughts: true},
+		{name: "gemini 3 pro minimal", model: "gemini-3-pro", effort: "minimal", expectedLevel: "low", includeThoughts: true},
+		{name: "gemini 3 flash medium", model: "gemini-3-flash-preview", effort: "Medium", expectedLevel: "medium", includeThoughts: true},
+		{name: "gemini 3 flash none", model: "gemini-3-flash", effort: "none", expectedLevel: "minimal", includeThoughts: false},
+		{name: "gemini 2.5 low", model: "gemini-2.5-pro", effort: "low", expectedBudget: 1024, includeThoughts: true},
+		{name: "gemini 2.5 high", model: "gemini-2.5-flash", effort: "high", expectedBudget: 24576, includeThoughts: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &gemini.GeminiInternalRequest{}
+			applyReasoningEffort(req, tc.model, tc.effort)
+
+			if req.GenerationConfig == nil || req.GenerationConfig.ThinkingConfig == nil {
+				t.Fatal("expected thinkingConfig to be set")
+			}
+			cfg := req.GenerationConfig.ThinkingConfig
+			if cfg.ThinkingLevel != tc.expectedLevel {
+				t.Errorf("expected thinkingLevel %q, got %q", tc.expectedLevel, cfg.ThinkingLevel)
+			}
+			if tc.expectedBudget == 0 && cfg.ThinkingBudget != nil {
+				t.Errorf("expected no thinkingBudget, got %d", *cfg.ThinkingBudget)
+			}
+			if tc.expectedBudget != 0 && (cfg.ThinkingBudget == nil || *cfg.ThinkingBudget != tc.expectedBudget) {
+				t.Errorf("expected thinkingBudget %d, got %v", tc.expectedBudget, cfg.ThinkingBudget)
+			}
+			if cfg.IncludeThoughts != tc.includeThoughts {
+				t.Errorf("expected includeThoughts %v, got %v", tc.includeThoughts, cfg.IncludeThoughts)
+			}
+		})
+	}
+}
+
+func TestApplyReasoningEffort_FlashLiteBudgets(t *testing.T) {
+	testCases := []struct {
+		effort         string
+		expectedBudget int
+	}{
+		{effort: "none", expectedBudget: 0},
+		{effort: "minimal", expectedBudget: 512},
+		{effort: "low", expectedBudget: 1024},
+		{effort: "high", expectedBudget: 24576},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.effort, func(t *testing.T) {
+			req := &gemini.GeminiInternalRequest{}
+			applyReasoningEffort(req, "gemini-2.5-flash-lite", tc.effort)
+
+			cfg := req.GenerationConfig.ThinkingConfig
+			if cfg.ThinkingBudget == nil || *cfg.ThinkingBudget != tc.expectedBudget {
+				t.Errorf("expected thinkingBudget %d, got %v", tc.expectedBudget, cfg.ThinkingBudget)
+			}
+		})
+	}
+
+	// Without thoughts the budget of 0 leaves Flash-Lite at its default of not thinking
+	req := &gemini.GeminiInternalRequest{}
+	applyReasoningEffort(req, "gemini-2.5-flash-lite", "none")
+	sanitizeGeminiRequest(req, "gemini-2.5-flash-lite")
+	if req.GenerationConfig.ThinkingConfig != nil {
+		t.Errorf("expected no thinkingConfig for effort none, got %+v", req.GenerationConfig.ThinkingConfig)
+	}
+}
+
+func TestApplyReasoningEffort_Defaults(t *testing.T) {
+	t.Setenv("DEFAULT_THINKING_LEVEL", "low, gemini-3-pro=high")
+
+	req := &gemini.GeminiInternalRequest{}
+	applyReasoningEffort(req, "gemini-3-pro", "")
+	if got := req.GenerationConfig.ThinkingConfig.ThinkingLevel; got != "high" {
+		t.Errorf("expected per-model default high, got %q", got)
+	}
+
+	req = &gemini.GeminiInternalRequest{}
+	applyReasoningEffort(req, "gemini-3-flash", "")
+	if got := req.GenerationConfig.ThinkingConfig.ThinkingLevel; got != "low" {
+		t.Errorf("expected global default low, got %q", got)
+	}
+
+	// Explicit thinking configuration from the client is kept
+	budget := 2048
+	req = &gemini.GeminiInternalRequest{GenerationConfig: &gemini.GeminiGenerationConfig{
+		ThinkingConfig: &gemini.ThinkingConfig{ThinkingBudget: &budget},
+	}}
+	applyReasoningEffort(req, "gemini-2.5-pro", "")
+	if cfg := req.GenerationConfig.ThinkingConfig; cfg.ThinkingBudget == nil || *cfg.ThinkingBudget != 2048 {
+		t.Errorf("expected client thinkingBudget to be kept, got %+v", cfg)
+	}
+
+	// Unknown efforts are ignored
+	req = &gemini.GeminiInternalRequest{}
+	applyReasoningEffort(req, "gemini-3-pro", "extreme")
+	if req.GenerationConfig != nil {
+		t.Errorf("expected unknown effort to be ignored, got %+v", req.GenerationConfig)
+	}
+}
@@ -0,0 +1,238 @@
+package server
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/logger"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/openai"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/transform"
+)
+
+// geminiStreamToChunks adapts raw CloudCode SSE lines into StreamChunks
+// (model text, thoughts, tool calls, signatures, finish reasons, block reasons, usage, etc.).
+// Function names are mapped back through names (see transform.SanitizeToolNames).
+// onFirstLine is invoked once the first upstream line arrives, e.g. to stop keepalive pings.
+// The returned channel is closed when the upstream ends or sends [DONE].
+func geminiStreamToChunks(upstream <-chan string, names *transform.ToolNameMap, startTime time.Time, onFirstLine func()) <-chan openai.StreamChunk {
+	chunkIn := make(chan openai.StreamChunk, 32)
+	go func() {
+		defer close(chunkIn)
+		firstUpstream := true
+		firstThoughtSeen := false
+		for line := range upstream {
+			if firstUpstream {
+				onFirstLine() // e.g. stop pinger on first data
+			}
+			// Process only data lines
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+
+			if firstUpstream {
+				logger.Get().Info().
+					Dur("time_to_first_upstream_line", time.Since(startTime)).
+					Msg("First upstream SSE line received")
+				firstUpstream = false
+			}
+
+			// Transform CloudCode wrapper to standard Gemini-format event
+			transformed := TransformSSELine(line)
+			data := strings.TrimSpace(strings.TrimPrefix(transformed, "data: "))
+
+			// Handle upstream DONE
+			if data == "" || data == "[DONE]" || data == "\"[DONE]\"" {
+				logger.Get().Info().Msg("Received upstream DONE")
+				break
+			}
+
+			// Parse JSON payload
+			var obj map[string]interface{}
+			if err := json.Unmarshal([]byte(data), &obj); err != nil {
+				// Fallback: forward as plain text chunk
+				logger.Get().Debug().Err(err).Msg("Failed to parse SSE JSON; forwarding as text")
+				chunkIn <- openai.StreamChunk{Type: "text", Data: data}
+				continue
+			}
+
+			// Usage metadata (optional)
+			if um, ok := obj["usageMetadata"].(map[string]interface{}); ok {
+				payload := map[string]interface{}{}
+				if v, ok := um["promptTokenCount"]; ok {
+					payload["inputTokens"] = v
+				}
+				if v, ok := um["candidatesTokenCount"]; ok {
+					payload["outputTokens"] = v
+				}
+				chunkIn <- openai.StreamChunk{Type: "usage", Data: payload}
+			}
+
+			// Blocked prompts come back without candidates
+			if pf, ok := obj["promptFeedback"].(map[string]interface{}); ok {
+				if br, ok := pf["blockReason"].(string); ok && br != "" {
+					logger.Get().Warn().Str("block_reason", br).Msg("Gemini blocked the prompt")
+					chunkIn <- openai.StreamChunk{Type: "block_reason", Data: br}
+				}
+			}
+
+			// Extract candidate content parts
+			if cands, ok := obj["candidates"].([]interface{}); ok {
+				for ci, c := range cands {
+					cand, ok := c.(map[string]interface{})
+					if !ok {
+						logger.Get().Warn().Interface("candidate", c).Msg("Skipping invalid candidate in Gemini stream")
+						continue
+					}
+					// Candidates carry their index when candidateCount > 1
+					candIdx := ci
+					if v, ok := cand["index"].(float64); ok {
+						candIdx = int(v)
+					}
+
+					// Optional grounding metadata passthrough
+					if gm, ok := cand["groundingMetadata"]; ok && gm != nil {
+						chunkIn <- openai.StreamChunk{Candidate: candIdx, Type: "grounding_metadata", Data: gm}
+					}
+
+					// Retrieve parts
+					var parts []interface{}
+					if content, ok := cand["content"].(map[string]interface{}); ok {
+						if ps, ok := content["parts"].([]interface{}); ok {
+							parts = ps
+						}
+					}
+					if len(parts) == 0 {
+						if ps, ok := cand["parts"].([]interface{}); ok {
+							parts = ps
+						}
+					}
+
+					// Process parts
+					for _, p := range parts {
+						part, ok := p.(map[string]interface{})
+						if !ok {
+							logger.Get().Warn().Interface("part", p).Msg("Skipping invalid part in Gemini stream")
+							continue
+						}
+
+						// Thought signatures precede the part they were attached to so
+						// transformers can associate them with the following content
+						if sig, ok := part["thoughtSignature"].(string); ok && sig != "" {
+							chunkIn <- openai.StreamChunk{Candidate: candIdx, Type: "thought_signature", Data: sig}
+						}
+
+						// Thought tokens (reasoning) — map to reasoning stream
+						if isThought, ok := part["thought"].(bool); ok && isThought {
+							if txt, ok := part["text"].(string); ok && txt != "" {
+								if !firstThoughtSeen {
+									preview := txt
+									if len(preview) > 300 {
+										preview = preview[:300] + "..."
+									}
+									logger.Get().Info().
+										Int("len", len(txt)).
+										Str("preview", preview).
+										Msg("Streaming thinking tokens detected")
+									firstThoughtSeen = true
+								}
+								logger.Get().Debug().
+									Str("token", txt).
+									Msg("SSE thought token received")
+								chunkIn <- openai.StreamChunk{Candidate: candIdx, Type: "real_thinking", Data: txt}
+							}
+							// Skip normal text handling to avoid duplicating this token
+							continue
+						}
+
+						// Text tokens — log per token at DEBUG
+						if txt, ok := part["text"].(string); ok && txt != "" {
+							logger.Get().Debug().
+								Str("token", txt).
+								Msg("SSE text token received")
+							chunkIn <- openai.StreamChunk{Candidate: candIdx, Type: "text", Data: txt}
+						}
+
+						// Function call parts
+						if fc, ok := part["functionCall"].(map[string]interface{}); ok {
+							rawName, _ := fc["name"].(string)
+							name := names.ClientName(strings.TrimSpace(rawName))
+
+							// Robust args extraction without client-specific normalization
+							var args map[string]interface{}
+							var source string
+							tryParse := func(val interface{}, key string) bool {
+								switch v := val.(type) {
+								case map[string]interface{}:
+									args = v
+									source = key
+									return true
+								case string:
+									var m map[string]interface{}
+									if err := json.Unmarshal([]byte(v), &m); err == nil {
+										args = m
+										source = key + " (json)"
+										return true
+									}
+								}
+								return false
+							}
+							if !tryParse(fc["args"], "args") &&
+								!tryParse(fc["argsJson"], "argsJson") &&
+								!tryParse(fc["arguments"], "arguments") &&
+								!tryParse(fc["parameters"], "parameters") {
+								args = map[string]interface{}{}
+								source = "default_empty"
+							}
+
+							// Log tool call inputs (preview at INFO, full JSON at DEBUG)
+							argsJSON, _ := json.Marshal(args)
+							argsPreview := string(argsJSON)
+							if len(argsPreview) > 300 {
+								argsPreview = argsPreview[:300] + "..."
+							}
+							logger.Get().Info().
+								Str("function", name).
+								Int("arg_keys", len(args)).
+								Str("args_preview", argsPreview).
+								Msg("Tool call inputs")
+							logger.Get().Debug().
+								Str("function", name).
+								RawJSON("args", argsJSON).
+								Str("args_source", source).
+								Msg("Tool call full args")
+
+							logger.Get().Info().
+								Str("function", name).
+								Str("args_source", source).
+								Int("arg_keys", len(args)).
+								Msg("Emitting tool call from model")
+
+							// Emit tool call to the client transformer
+							chunkIn <- openai.StreamChunk{
+								Candidate: candIdx,
+								Type:      "tool_code",
+								Data: map[string]interface{}{
+									"name": name,
+									"args": args,
+								},
+							}
+						}
+					}
+
+					if lr, ok := cand["logprobsResult"].(map[string]interface{}); ok {
+						if lp := openai.LogprobsFromGemini(lr); lp != nil {
+							chunkIn <- openai.StreamChunk{Candidate: candIdx, Type: "logprobs", Data: lp}
+						}
+					}
+
+					if fr, ok := cand["finishReason"].(string); ok && fr != "" {
+						chunkIn <- openai.StreamChunk{Candidate: candIdx, Type: "finish_reason", Data: fr}
+					}
+				}
+			}
+		}
+	}()
+
+	return chunkIn
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/env"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/logger"
+)
+
+// maxFetchedMediaBytes caps the size of a single downloaded media URL (Gemini's inline limit is 20MB per request).
+const maxFetchedMediaBytes = 20 << 20
+
+// resolveRemoteMedia downloads http(s) fileData parts and replaces them with inlineData.
+// CloudCode only reads fileData URIs it has access to, so public image URLs sent by
+// OpenAI/Anthropic clients are fetched by the proxy when FETCH_MEDIA_URLS=true.
+// Otherwise the URLs are forwarded unchanged.
+func (s *Server) resolveRemoteMedia(ctx context.Context, req *gemini.GeminiInternalRequest) error {
+	if env.GetOrDefault("FETCH_MEDIA_URLS", "false") != "true" {
+		return nil
+	}
+
+	for ci := range req.Contents {
+		for pi := range req.Contents[ci].Parts {
+			part := &req.Contents[ci].Parts[pi]
+			if part.FileData == nil {
+				continue
+			}
+			uri := part.FileData.FileURI
+			if !strings.HasPrefix(uri, "http://") && !strings.HasPrefix(uri, "https://") {
+				continue
+			}
+
+			blob, err := s.fetchMedia(ctx, uri, part.FileData.MimeType)
+			if err != nil {
+				return err
+			}
+			part.FileData = nil
+			part.InlineData = blob
+		}
+	}
+	return nil
+}
+
+// fetchMedia downloads uri and returns it as an inline blob. The response Content-Type
+// wins over fallbackMime unless it is missing or generic.
+func (s *Server) fetchMedia(ctx context.Context, uri, fallbackMime string) (*gemini.Blob, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid media URL %q: %w", uri, err)
+	}
+
+	start := logger.Get().Debug().Str("url", uri)
+	resp, err := s.mediaClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch media URL %q: %w", uri, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch media URL %q: status %d", uri, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchedMediaBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read media URL %q: %w", uri, err)
+	}
+	if len(data) > maxFetchedMediaBytes {
+		return nil, fmt.Errorf("media URL %q exceeds %d bytes", uri, maxFetchedMediaBytes)
+	}
+
+	mimeType := fallbackMime
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+		if parsed, _, err := mime.ParseMediaType(ct); err == nil && parsed != "application/octet-stream" {
+			mimeType = parsed
+		}
+	}
+
+	start.Str("mime_type", mimeType).Int("bytes", len(data)).Msg("Fetched media URL")
+	return &gemini.Blob{MimeType: mimeType, Data: base64.StdEncoding.EncodeToString(data)}, nil
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
+)
+
+type fakeHTTPClient struct {
+	requests []*http.Request
+	respond  func(req *http.Request) (*http.Response, error)
+}
+
+func (f *fakeHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	f.requests = append(f.requests, req)
+	return f.respond(req)
+}
+
+func newMediaRequest() *gemini.GeminiInternalRequest {
+	return &gemini.GeminiInternalRequest{
+		Contents: []gemini.Content{{
+			Role: "user",
+			Parts: []gemini.ContentPart{
+				{Text: "look"},
+				{FileData: &gemini.FileData{MimeType: "image/jpeg", FileURI: "https://example.com/cat"}},
+				{FileData: &gemini.FileData{MimeType: "application/pdf", FileURI: "gs://bucket/doc.pdf"}},
+			},
+		}},
+	}
+}
+
+func TestResolveRemoteMedia(t *testing.T) {
+	client := &fakeHTTPClient{respond: func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"image/png; charset=binary"}},
+			Body:       io.NopCloser(strings.NewReader("png-bytes")),
+		}, nil
+	}}
+	s := &Server{mediaClient: client}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		req := newMediaRequest()
+		if err := s.resolveRemoteMedia(context.Background(), req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if req.Contents[0].Parts[1].FileData == nil || len(client.requests) != 0 {
+			t.Error("expected URL to be forwarded unchanged")
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		t.Setenv("FETCH_MEDIA_URLS", "true")
+		req := newMediaRequest()
+		if err := s.resolveRemoteMedia(context.Background(), req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		fetched := req.Contents[0].Parts[1]
+		if fetched.FileData != nil || fetched.InlineData == nil {
+			t.Fatalf("expected inlineData, got %+v", fetched)
+		}
+		if fetched.InlineData.MimeType != "image/png" || fetched.InlineData.Data != "cG5nLWJ5dGVz" {
+			t.Errorf("unexpected blob: %+v", fetched.InlineData)
+		}
+		// Non-http URIs are left for CloudCode to resolve
+		if req.Contents[0].Parts[2].FileData == nil {
+			t.Error("expected gs:// fileData to be kept")
+		}
+		if len(client.requests) != 1 {
+			t.Errorf("expected 1 fetch, got %d", len(client.requests))
+		}
+	})
+
+	t.Run("upstream error", func(t *testing.T) {
+		t.Setenv("FETCH_MEDIA_URLS", "true")
+		failing := &Server{mediaClient: &fakeHTTPClient{respond: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader(""))}, nil
+		}}}
+		if err := failing.resolveRemoteMedia(context.Background(), newMediaRequest()); err == nil {
+			t.Error("expected error for failed fetch")
+		}
+	})
+}
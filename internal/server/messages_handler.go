@@ -0,0 +1,222 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/anthropic"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/logger"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/tracing"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/transform"
+)
+
+// anthropicMessagesHandler handles Anthropic-compatible /v1/messages requests.
+// Requests are translated into CloudCode generateContent calls and the results
+// are translated back into Messages API responses or SSE events.
+func (s *Server) anthropicMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	logger.Get().Info().
+		Str("method", r.Method).
+		Str("path", r.URL.Path).
+		Time("start_time", startTime).
+		Msg("Anthropic messages request received")
+
+	if r.Method != http.MethodPost {
+		writeAnthropicError(w, http.StatusMethodNotAllowed, "invalid_request_error", "Method not allowed")
+		return
+	}
+
+	// Read body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logger.Get().Error().Err(err).Msg("Error reading request body")
+		writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	// Parse request
+	var req anthropic.MessagesRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		logger.Get().Error().Err(err).Msg("Error parsing request body")
+		writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", "Error parsing request body: "+err.Error())
+		return
+	}
+
+	logger.Get().Info().
+		Str("requested_model", req.Model).
+		Bool("stream", req.Stream).
+		Int("messages", len(req.Messages)).
+		Int("tools", len(req.Tools)).
+		Bool("thinking", req.Thinking != nil && req.Thinking.Type == "enabled").
+		Msg("Parsed Anthropic request")
+
+	noteRequest(r, req.Model, normalizeModelName(req.Model), req.Stream)
+	if !modelAllowed(r, req.Model, normalizeModelName(req.Model)) {
+		logger.Get().Warn().Str("model", req.Model).Msg("Model not allowed for API key")
+		writeAnthropicError(w, http.StatusForbidden, "permission_error", "Model not allowed for this API key: "+req.Model)
+		return
+	}
+
+	// Transform Anthropic -> Gemini; the project is set by the account the request is dispatched to
+	endTransform := traceStep(r.Context(), "transform.request", tracing.String("gcap.api", "anthropic.messages"))
+	gemReq, err := transform.AnthropicToGeminiRequest(&req, "")
+	if err != nil {
+		endTransform(err)
+		logger.Get().Error().Err(err).Msg("Failed to transform Anthropic request to Gemini request")
+		writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	// Normalize model name for CloudCode compatibility
+	originalModel := gemReq.Model
+	gemReq.Model = normalizeModelName(gemReq.Model)
+	if gemReq.Model != originalModel {
+		logger.Get().Info().
+			Str("original_model", originalModel).
+			Str("normalized_model", gemReq.Model).
+			Msg("Normalized model for CloudCode")
+	}
+	sanitizeGeminiRequest(&gemReq.Request, gemReq.Model)
+	toolNames := transform.SanitizeToolNames(&gemReq.Request)
+	endTransform(nil)
+
+	if err := s.resolveRemoteMedia(r.Context(), &gemReq.Request); err != nil {
+		logger.Get().Error().Err(err).Msg("Failed to resolve remote media")
+		writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	if req.Stream {
+		s.anthropicMessagesStream(w, r, gemReq, toolNames, req.Model, startTime)
+		return
+	}
+
+	// Call non-streaming GenerateContent
+	apiStart := time.Now()
+	resp, err := s.generateContent(r.Context(), gemReq)
+	if err != nil {
+		logger.Get().Error().Err(err).Dur("api_call_duration", time.Since(apiStart)).Msg("GenerateContent failed")
+		writeUpstreamError(w, r, err)
+		return
+	}
+
+	toolNames.RestoreResponse(resp.Response)
+	endTransform = traceStep(r.Context(), "transform.response", tracing.String("gcap.api", "anthropic.messages"))
+	msgResp, err := transform.ToAnthropicMessagesResponse(resp, req.Model)
+	endTransform(err)
+	if err != nil {
+		logger.Get().Error().Err(err).Msg("Failed to transform Gemini response to Anthropic response")
+		writeAnthropicError(w, http.StatusInternalServerError, "api_error", "Failed to transform response")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(msgResp); err != nil {
+		logger.Get().Error().Err(err).Msg("Error writing non-streaming response")
+		return
+	}
+
+	logger.Get().Info().
+		Str("model", gemReq.Model).
+		Str("stop_reason", msgResp.StopReason).
+		Dur("api_call_duration", time.Since(apiStart)).
+		Dur("total_duration", time.Since(startTime)).
+		Msg("Anthropic non-streaming response completed")
+}
+
+// anthropicMessagesStream streams the upstream response as Anthropic SSE events.
+// clientModel is the model name echoed back to the client.
+func (s *Server) anthropicMessagesStream(w http.ResponseWriter, r *http.Request, gemReq *gemini.GenerateContentRequest, toolNames *transform.ToolNameMap, clientModel string, startTime time.Time) {
+	release, ok := s.acquireStream(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+
+	upstream := make(chan string, 32)
+	logger.Get().Info().
+		Str("model", gemReq.Model).
+		Msg("Starting upstream StreamGenerateContent")
+	if err := s.streamGenerateContent(r.Context(), gemReq, upstream); err != nil {
+		logger.Get().Error().Err(err).Msg("StreamGenerateContent call failed")
+		writeUpstreamError(w, r, err)
+		return
+	}
+
+	// Prepare SSE response; headers are only committed once the upstream stream is open
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	var flusher http.Flusher
+	if f, ok := w.(http.Flusher); ok {
+		flusher = f
+		flusher.Flush()
+	}
+
+	// Pinger to keep connection alive until the first upstream line
+	pingerCtx, cancelPinger := context.WithCancel(r.Context())
+	defer cancelPinger()
+
+	go func() {
+		ticker := time.NewTicker(10 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := io.WriteString(w, "event: ping\ndata: {\"type\": \"ping\"}\n\n"); err != nil {
+					logger.Get().Warn().Err(err).Msg("Failed to write SSE ping")
+					return
+				}
+				if flusher != nil {
+					flusher.Flush()
+				}
+			case <-pingerCtx.Done():
+				return
+			}
+		}
+	}()
+
+	chunkIn := geminiStreamToChunks(upstream, toolNames, startTime, cancelPinger)
+	transformer := anthropic.CreateAnthropicStreamTransformer(clientModel)
+	out := transformer(chunkIn)
+
+	firstWrite := true
+	for sse := range out {
+		if _, err := io.WriteString(w, sse); err != nil {
+			logger.Get().Error().Err(err).Msg("Error writing SSE to client")
+			return
+		}
+		if firstWrite {
+			logger.Get().Info().
+				Dur("time_to_first_client_write", time.Since(startTime)).
+				Msg("First Anthropic SSE event written to client")
+			firstWrite = false
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	logger.Get().Info().
+		Str("model", gemReq.Model).
+		Dur("total_duration", time.Since(startTime)).
+		Msg("Anthropic streaming response completed")
+}
+
+// writeAnthropicError writes an error in the Messages API error envelope.
+func writeAnthropicError(w http.ResponseWriter, status int, errType, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(anthropic.ErrorResponse{
+		Type:  "error",
+		Error: anthropic.ErrorDetail{Type: errType, Message: message},
+	})
+}
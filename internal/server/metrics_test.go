@@ -0,0 +1,54 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsRoute(t *testing.T) {
+	tests := map[string]string{
+		"/v1/chat/completions":                                "/v1/chat/completions",
+		"/v1beta/models/gemini-2.5-pro:streamGenerateContent": "/v1beta/models/{model}:streamGenerateContent",
+		"/v1beta/models/gemini-2.5-pro":                       "/v1beta/models/{model}",
+	}
+	for path, want := range tests {
+		if got := metricsRoute(path); got != want {
+			t.Errorf("metricsRoute(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	t.Setenv("ADMIN_API_KEY", "admin-secret")
+	s := &Server{mux: http.NewServeMux()}
+	s.setupRoutes()
+
+	handler := s.inferenceMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		noteRequest(r, "gemini-2.5-pro", "gemini-2.5-pro", false)
+		s.recordUsage(r.Context(), "gemini-2.5-pro", tokenUsage{Prompt: 7, Output: 3, Total: 10})
+		w.WriteHeader(http.StatusTeapot)
+	})
+	req := httptest.NewRequest(http.MethodPost, "/v1/metrics-test", nil)
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	handler(httptest.NewRecorder(), req)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`gcap_requests_total{route="/v1/metrics-test",model="gemini-2.5-pro",status="418"} 1`,
+		`gcap_request_duration_seconds_count{route="/v1/metrics-test",model="gemini-2.5-pro",status="418"} 1`,
+		`# TYPE gcap_tokens_total counter`,
+		`# TYPE gcap_stream_time_to_first_token_seconds histogram`,
+		`# TYPE gcap_credential_expiry_seconds gauge`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q", want)
+		}
+	}
+}
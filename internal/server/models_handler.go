@@ -59,12 +59,51 @@ func (s *Server) modelsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	models := SupportedModels()
+
+	// Handle request for a single model, e.g., /v1/models/gemini-2.5-pro
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) > 2 {
+		requestedModelID := pathParts[2]
+		for _, model := range models {
+			if model.ID == requestedModelID {
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(model)
+				return
+			}
+		}
+		http.NotFound(w, r)
+		return
+	}
+
+	response := ModelsListResponse{
+		Object: "list",
+		Data:   models,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	w.Header().Set("Server", "Google")
+	w.Header().Set("Cache-Control", "public, max-age=600")
+
+	jsonResponse, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(jsonResponse)))
+	w.Write(jsonResponse)
+}
+
+// SupportedModels returns the models served by the proxy.
+func SupportedModels() []ModelInfo {
 	defaultSupports := ModelSupports{
 		ParallelToolCalls: true,
 		Streaming:         true,
 		StructuredOutputs: true,
 		ToolCalls:         true,
-		Vision:            false, // Vision is not supported via this proxy
+		Vision:            true,
 	}
 
 	defaultTokenizer := "o200k_base"
@@ -73,7 +112,7 @@ func (s *Server) modelsHandler(w http.ResponseWriter, r *http.Request) {
 	defaultModelObject := "model"
 	defaultVendor := "Google"
 
-	models := []ModelInfo{
+	return []ModelInfo{
 		{
 			ID:      "gemini-2.5-pro",
 			Object:  defaultModelObject,
@@ -229,38 +268,4 @@ func (s *Server) modelsHandler(w http.ResponseWriter, r *http.Request) {
 			Vendor:              defaultVendor,
 		},
 	}
-
-	// Handle request for a single model, e.g., /v1/models/gemini-2.5-pro
-	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
-	if len(pathParts) > 2 {
-		requestedModelID := pathParts[2]
-		for _, model := range models {
-			if model.ID == requestedModelID {
-				w.Header().Set("Content-Type", "application/json")
-				json.NewEncoder(w).Encode(model)
-				return
-			}
-		}
-		http.NotFound(w, r)
-		return
-	}
-
-	response := ModelsListResponse{
-		Object: "list",
-		Data:   models,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Date", time.Now().UTC().Format(http.TimeFormat))
-	w.Header().Set("Server", "Google")
-	w.Header().Set("Cache-Control", "public, max-age=600")
-
-	jsonResponse, err := json.Marshal(response)
-	if err != nil {
-		http.Error(w, "Failed to marshal response", http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Length", strconv.Itoa(len(jsonResponse)))
-	w.Write(jsonResponse)
 }
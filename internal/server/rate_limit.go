@@ -0,0 +1,114 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/env"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/logger"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/metrics"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/ratelimit"
+)
+
+// newLimiterFromEnv builds the rate limiter with the global default limits.
+// Limits set to 0 (the default) are not enforced.
+func newLimiterFromEnv() *ratelimit.Limiter {
+	envInt := func(name string) int64 {
+		str := env.GetOrDefault(name, "0")
+		v, err := strconv.ParseInt(str, 10, 64)
+		if err != nil || v < 0 {
+			logger.Get().Warn().Str("value", str).Msgf("Invalid %s, rate limit disabled", name)
+			return 0
+		}
+		return v
+	}
+	defaults := ratelimit.Limits{
+		RequestsPerMinute:    int(envInt("RATE_LIMIT_REQUESTS_PER_MINUTE")),
+		TokensPerDay:         envInt("RATE_LIMIT_TOKENS_PER_DAY"),
+		MaxConcurrentStreams: int(envInt("RATE_LIMIT_MAX_CONCURRENT_STREAMS")),
+	}
+
+	store, err := ratelimit.NewDefaultStore()
+	if err != nil {
+		logger.Get().Warn().Err(err).Msg("Rate limit store unavailable, falling back to memory")
+		store = ratelimit.NewMemoryStore()
+	}
+	logger.Get().Info().
+		Str("store", store.Name()).
+		Int("requests_per_minute", defaults.RequestsPerMinute).
+		Int64("tokens_per_day", defaults.TokensPerDay).
+		Int("max_concurrent_streams", defaults.MaxConcurrentStreams).
+		Msg("Using rate limiter")
+	return ratelimit.NewLimiter(store, defaults)
+}
+
+// rateLimitMiddleware enforces the requests per minute and daily token budget of the client key.
+// It must run after authMiddleware.
+func (s *Server) rateLimitMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := clientKey(r.Context())
+		if s.limiter == nil || key == nil {
+			next(w, r)
+			return
+		}
+
+		if err := s.limiter.Allow(key.ID, key.RateLimits); err != nil {
+			if !s.handleLimitError(w, r, err) {
+				// The limiter's store failing must not take the proxy down
+				next(w, r)
+			}
+			return
+		}
+		next(w, r)
+	}
+}
+
+// acquireStream takes a concurrent stream slot of the client key. If the limit is reached it
+// writes a 429 and returns ok=false; otherwise release must be called when the stream ends.
+func (s *Server) acquireStream(w http.ResponseWriter, r *http.Request) (release func(), ok bool) {
+	release = func() {}
+	if key := clientKey(r.Context()); s.limiter != nil && key != nil {
+		var err error
+		release, err = s.limiter.AcquireStream(key.ID, key.RateLimits)
+		if err != nil {
+			if s.handleLimitError(w, r, err) {
+				return nil, false
+			}
+			release = func() {}
+		}
+	}
+
+	route := metricsRoute(r.URL.Path)
+	metrics.StreamsInFlight.Inc(route)
+	return func() {
+		metrics.StreamsInFlight.Dec(route)
+		release()
+	}, true
+}
+
+// handleLimitError writes a 429 for a *ratelimit.LimitError and returns true. Other errors
+// come from the store; they are logged and the request is let through.
+func (s *Server) handleLimitError(w http.ResponseWriter, r *http.Request, err error) bool {
+	key := clientKey(r.Context())
+	var limitErr *ratelimit.LimitError
+	if !errors.As(err, &limitErr) {
+		logger.Get().Error().Err(err).Str("key_id", key.ID).Msg("Rate limiter failed, allowing request")
+		return false
+	}
+
+	logger.Get().Warn().
+		Str("key_id", key.ID).
+		Str("limit", limitErr.Limit).
+		Dur("retry_after", limitErr.RetryAfter).
+		Msgf("Rate limit exceeded: %s %s from %s", r.Method, r.RequestURI, r.RemoteAddr)
+	writeRateLimitError(w, r, limitErr)
+	return true
+}
+
+// writeRateLimitError writes a 429 with Retry-After in the error format of the client's API.
+func writeRateLimitError(w http.ResponseWriter, r *http.Request, limitErr *ratelimit.LimitError) {
+	writeAPIError(w, r, http.StatusTooManyRequests, rpcStatusName(http.StatusTooManyRequests), limitErr.Error(),
+		max(limitErr.RetryAfter, time.Second), nil)
+}
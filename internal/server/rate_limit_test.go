@@ -0,0 +1,90 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/ratelimit"
+)
+
+func TestWriteRateLimitError(t *testing.T) {
+	limitErr := &ratelimit.LimitError{Limit: "requests_per_minute", Max: 10, RetryAfter: 1500 * time.Millisecond}
+
+	tests := []struct {
+		path  string
+		check func(t *testing.T, body map[string]interface{})
+	}{
+		{"/v1/chat/completions", func(t *testing.T, body map[string]interface{}) {
+			e := body["error"].(map[string]interface{})
+			if e["code"] != "rate_limit_exceeded" {
+				t.Errorf("unexpected OpenAI error: %v", e)
+			}
+		}},
+		{"/v1/messages", func(t *testing.T, body map[string]interface{}) {
+			e := body["error"].(map[string]interface{})
+			if body["type"] != "error" || e["type"] != "rate_limit_error" {
+				t.Errorf("unexpected Anthropic error: %v", body)
+			}
+		}},
+		{"/v1beta/models/gemini-2.5-pro:generateContent", func(t *testing.T, body map[string]interface{}) {
+			e := body["error"].(map[string]interface{})
+			if e["status"] != "RESOURCE_EXHAUSTED" || e["code"] != float64(429) {
+				t.Errorf("unexpected Gemini error: %v", e)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			writeRateLimitError(rec, httptest.NewRequest(http.MethodPost, tt.path, nil), limitErr)
+
+			if rec.Code != http.StatusTooManyRequests {
+				t.Errorf("expected 429, got %d", rec.Code)
+			}
+			if got := rec.Header().Get("Retry-After"); got != "2" {
+				t.Errorf("expected Retry-After 2, got %q", got)
+			}
+			var body map[string]interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid JSON body: %v", err)
+			}
+			tt.check(t, body)
+		})
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	s := &Server{limiter: ratelimit.NewLimiter(ratelimit.NewMemoryStore(), ratelimit.Limits{RequestsPerMinute: 1})}
+	t.Setenv("ADMIN_API_KEY", "admin-secret")
+	handler := s.inferenceMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != want {
+			t.Errorf("request %d: expected %d, got %d", i, want, rec.Code)
+		}
+	}
+}
+
+func TestUsageFromStreamLine(t *testing.T) {
+	line := `data: {"response": {"candidates": [], "usageMetadata": {"promptTokenCount": 10, "candidatesTokenCount": 5, "thoughtsTokenCount": 3, "totalTokenCount": 18}}}`
+	u, ok := usageFromStreamLine(line)
+	if !ok {
+		t.Fatal("expected usage")
+	}
+	if u.Prompt != 10 || u.Output != 5 || u.Thoughts != 3 || u.Total != 18 {
+		t.Errorf("unexpected usage: %+v", u)
+	}
+
+	if _, ok := usageFromStreamLine(`data: {"response": {"candidates": []}}`); ok {
+		t.Error("expected no usage")
+	}
+}
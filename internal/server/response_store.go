@@ -0,0 +1,82 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/openai"
+)
+
+// StoredResponse is a Responses API conversation snapshot kept for previous_response_id.
+// Items holds the full conversation up to and including the response's output items.
+type StoredResponse struct {
+	ID        string
+	Items     []openai.ResponseItem
+	CreatedAt time.Time
+}
+
+// ResponseStore persists Responses API conversations between requests.
+type ResponseStore interface {
+	// Get returns the stored response with the given id, if present and not expired
+	Get(id string) (*StoredResponse, bool)
+
+	// Put stores (or replaces) a response
+	Put(resp *StoredResponse)
+}
+
+// memoryResponseStore is an in-process ResponseStore with a TTL and a size cap.
+// On Workers it only lives as long as the isolate, which is good enough for
+// short agent loops.
+type memoryResponseStore struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]*StoredResponse
+	order      []string // insertion order, oldest first
+}
+
+// NewMemoryResponseStore creates an in-memory ResponseStore.
+func NewMemoryResponseStore(ttl time.Duration, maxEntries int) ResponseStore {
+	return &memoryResponseStore{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*StoredResponse),
+	}
+}
+
+func (m *memoryResponseStore) Get(id string) (*StoredResponse, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resp, ok := m.entries[id]
+	if !ok {
+		return nil, false
+	}
+	if m.ttl > 0 && time.Since(resp.CreatedAt) > m.ttl {
+		delete(m.entries, id)
+		return nil, false
+	}
+	return resp, true
+}
+
+func (m *memoryResponseStore) Put(resp *StoredResponse) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.entries[resp.ID]; !exists {
+		m.order = append(m.order, resp.ID)
+	}
+	m.entries[resp.ID] = resp
+
+	// Evict expired entries and enforce the size cap, oldest first
+	for len(m.order) > 0 {
+		oldest := m.order[0]
+		entry, ok := m.entries[oldest]
+		expired := ok && m.ttl > 0 && time.Since(entry.CreatedAt) > m.ttl
+		if ok && !expired && (m.maxEntries <= 0 || len(m.entries) <= m.maxEntries) {
+			break
+		}
+		delete(m.entries, oldest)
+		m.order = m.order[1:]
+	}
+}
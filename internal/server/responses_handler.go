@@ -0,0 +1,270 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/logger"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/openai"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/tracing"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/transform"
+)
+
+// openAIResponsesHandler handles OpenAI Responses API requests (/v1/responses).
+// Conversations referenced through previous_response_id are loaded from the
+// server's ResponseStore and prepended to the request input.
+func (s *Server) openAIResponsesHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	logger.Get().Info().
+		Str("method", r.Method).
+		Str("path", r.URL.Path).
+		Time("start_time", startTime).
+		Msg("OpenAI responses request received")
+
+	if r.Method != http.MethodPost {
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "Method not allowed")
+		return
+	}
+
+	// Read body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logger.Get().Error().Err(err).Msg("Error reading request body")
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	// Parse request
+	var req openai.ResponsesRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		logger.Get().Error().Err(err).Msg("Error parsing request body")
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "Error parsing request body: "+err.Error())
+		return
+	}
+
+	// Resolve stored conversation
+	var items []openai.ResponseItem
+	if req.PreviousResponseID != "" {
+		prev, ok := s.responseStore.Get(req.PreviousResponseID)
+		if !ok {
+			logger.Get().Warn().Str("previous_response_id", req.PreviousResponseID).Msg("Previous response not found")
+			writeOpenAIError(w, http.StatusNotFound, "invalid_request_error", "Previous response with id '"+req.PreviousResponseID+"' not found.")
+			return
+		}
+		items = append(items, prev.Items...)
+	}
+	items = append(items, req.Input...)
+
+	logger.Get().Info().
+		Str("requested_model", req.Model).
+		Bool("stream", req.Stream).
+		Int("input_items", len(req.Input)).
+		Int("history_items", len(items)-len(req.Input)).
+		Int("tools", len(req.Tools)).
+		Str("previous_response_id", req.PreviousResponseID).
+		Msg("Parsed OpenAI responses request")
+
+	noteRequest(r, req.Model, normalizeModelName(req.Model), req.Stream)
+	if !modelAllowed(r, req.Model, normalizeModelName(req.Model)) {
+		logger.Get().Warn().Str("model", req.Model).Msg("Model not allowed for API key")
+		writeOpenAIError(w, http.StatusForbidden, "invalid_request_error", "Model not allowed for this API key: "+req.Model)
+		return
+	}
+
+	// Transform Responses -> Gemini; the project is set by the account the request is dispatched to
+	endTransform := traceStep(r.Context(), "transform.request", tracing.String("gcap.api", "openai.responses"))
+	gemReq, err := transform.ResponsesToGeminiRequest(&req, items, "")
+	if err != nil {
+		endTransform(err)
+		logger.Get().Error().Err(err).Msg("Failed to transform Responses request to Gemini request")
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	// Normalize model name for CloudCode compatibility
+	originalModel := gemReq.Model
+	gemReq.Model = normalizeModelName(gemReq.Model)
+	if gemReq.Model != originalModel {
+		logger.Get().Info().
+			Str("original_model", originalModel).
+			Str("normalized_model", gemReq.Model).
+			Msg("Normalized model for CloudCode")
+	}
+	effort := ""
+	if req.Reasoning != nil {
+		effort = req.Reasoning.Effort
+	}
+	applyReasoningEffort(&gemReq.Request, gemReq.Model, effort)
+	sanitizeGeminiRequest(&gemReq.Request, gemReq.Model)
+	toolNames := transform.SanitizeToolNames(&gemReq.Request)
+	endTransform(nil)
+
+	if err := s.resolveRemoteMedia(r.Context(), &gemReq.Request); err != nil {
+		logger.Get().Error().Err(err).Msg("Failed to resolve remote media")
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	base := openai.Response{
+		ID:                 openai.NewResponseID(),
+		CreatedAt:          startTime.Unix(),
+		Model:              req.Model,
+		PreviousResponseID: req.PreviousResponseID,
+		Metadata:           req.Metadata,
+	}
+
+	// Persist the conversation including the new output for follow-up requests
+	store := func(resp *openai.Response) {
+		if !req.ShouldStore() || resp.Status == openai.ResponseStatusFailed {
+			return
+		}
+		conversation := make([]openai.ResponseItem, 0, len(items)+len(resp.Output))
+		conversation = append(conversation, items...)
+		conversation = append(conversation, resp.Output...)
+		s.responseStore.Put(&StoredResponse{
+			ID:        resp.ID,
+			Items:     conversation,
+			CreatedAt: time.Now(),
+		})
+	}
+
+	if req.Stream {
+		s.openAIResponsesStream(w, r, gemReq, toolNames, base, store, startTime)
+		return
+	}
+
+	// Call non-streaming GenerateContent
+	apiStart := time.Now()
+	geminiResp, err := s.generateContent(r.Context(), gemReq)
+	if err != nil {
+		logger.Get().Error().Err(err).Dur("api_call_duration", time.Since(apiStart)).Msg("GenerateContent failed")
+		writeUpstreamError(w, r, err)
+		return
+	}
+
+	toolNames.RestoreResponse(geminiResp.Response)
+	endTransform = traceStep(r.Context(), "transform.response", tracing.String("gcap.api", "openai.responses"))
+	resp, err := transform.ToResponsesResponse(geminiResp, base)
+	endTransform(err)
+	if err != nil {
+		logger.Get().Error().Err(err).Msg("Failed to transform Gemini response to Responses response")
+		writeOpenAIError(w, http.StatusInternalServerError, "server_error", "Failed to transform response")
+		return
+	}
+	store(resp)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logger.Get().Error().Err(err).Msg("Error writing non-streaming response")
+		return
+	}
+
+	logger.Get().Info().
+		Str("model", gemReq.Model).
+		Str("response_id", resp.ID).
+		Str("status", resp.Status).
+		Dur("api_call_duration", time.Since(apiStart)).
+		Dur("total_duration", time.Since(startTime)).
+		Msg("OpenAI responses non-streaming response completed")
+}
+
+// openAIResponsesStream streams the upstream response as Responses API SSE events.
+func (s *Server) openAIResponsesStream(w http.ResponseWriter, r *http.Request, gemReq *gemini.GenerateContentRequest, toolNames *transform.ToolNameMap, base openai.Response, onDone func(*openai.Response), startTime time.Time) {
+	release, ok := s.acquireStream(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+
+	upstream := make(chan string, 32)
+	logger.Get().Info().
+		Str("model", gemReq.Model).
+		Msg("Starting upstream StreamGenerateContent")
+	if err := s.streamGenerateContent(r.Context(), gemReq, upstream); err != nil {
+		logger.Get().Error().Err(err).Msg("StreamGenerateContent call failed")
+		writeUpstreamError(w, r, err)
+		return
+	}
+
+	// Prepare SSE response; headers are only committed once the upstream stream is open
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	var flusher http.Flusher
+	if f, ok := w.(http.Flusher); ok {
+		flusher = f
+		flusher.Flush()
+	}
+
+	// Pinger to keep connection alive until the first upstream line
+	pingerCtx, cancelPinger := context.WithCancel(r.Context())
+	defer cancelPinger()
+
+	go func() {
+		ticker := time.NewTicker(10 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+					logger.Get().Warn().Err(err).Msg("Failed to write SSE ping")
+					return
+				}
+				if flusher != nil {
+					flusher.Flush()
+				}
+			case <-pingerCtx.Done():
+				return
+			}
+		}
+	}()
+
+	chunkIn := geminiStreamToChunks(upstream, toolNames, startTime, cancelPinger)
+	transformer := openai.CreateResponsesStreamTransformer(base, onDone)
+	out := transformer(chunkIn)
+
+	firstWrite := true
+	for sse := range out {
+		if _, err := io.WriteString(w, sse); err != nil {
+			logger.Get().Error().Err(err).Msg("Error writing SSE to client")
+			return
+		}
+		if firstWrite {
+			logger.Get().Info().
+				Dur("time_to_first_client_write", time.Since(startTime)).
+				Msg("First Responses SSE event written to client")
+			firstWrite = false
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	logger.Get().Info().
+		Str("model", gemReq.Model).
+		Str("response_id", base.ID).
+		Dur("total_duration", time.Since(startTime)).
+		Msg("OpenAI responses streaming response completed")
+}
+
+// writeOpenAIError writes an error in the OpenAI API error envelope.
+func writeOpenAIError(w http.ResponseWriter, status int, errType, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": message,
+			"type":    errType,
+			"param":   nil,
+			"code":    nil,
+		},
+	})
+}
@@ -3,34 +3,69 @@ package server
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/accounts"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/apikeys"
 	"github.com/dvcrn/gemini-code-assist-proxy/internal/credentials"
 	"github.com/dvcrn/gemini-code-assist-proxy/internal/env"
-	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
 	serverhttp "github.com/dvcrn/gemini-code-assist-proxy/internal/http"
 	"github.com/dvcrn/gemini-code-assist-proxy/internal/logger"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/metrics"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/ratelimit"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/tracing"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/usage"
 )
 
 // Server represents the proxy server with its dependencies
 type Server struct {
-	httpClient   serverhttp.HTTPClient
-	provider     credentials.CredentialsProvider
-	oauthCreds   *credentials.OAuthCredentials
-	projectID    string
-	mux          *http.ServeMux
-	geminiClient *gemini.Client
+	httpClient serverhttp.HTTPClient
+	// mediaClient fetches client-supplied media URLs; it only reaches public addresses
+	mediaClient serverhttp.HTTPClient
+	oauthCreds  *credentials.OAuthCredentials
+	mux         *http.ServeMux
+
+	// accounts schedules upstream requests across the configured Code Assist accounts
+	accounts *accounts.Pool
+
+	// apiKeys holds the client API keys; nil if the store is unavailable (only ADMIN_API_KEY works then)
+	apiKeys apikeys.Store
+
+	// limiter enforces per-key rate limits on the inference endpoints
+	limiter *ratelimit.Limiter
+
+	// ledger records every inference request for /admin/usage; nil disables recording
+	ledger usage.Ledger
+
+	// responseStore holds Responses API conversations for previous_response_id
+	responseStore ResponseStore
+
+	// thoughtSignatures maps chat completion tool_call_ids to Gemini thought signatures
+	thoughtSignatures ThoughtSignatureStore
 }
 
 // NewServer creates a new server instance with the given credentials provider
 func NewServer(provider credentials.CredentialsProvider, projectID string) *Server {
+	return NewServerWithAccounts([]*accounts.Account{accounts.NewAccount("default", provider, projectID)})
+}
+
+// NewServerWithAccounts creates a server that spreads requests over a pool of accounts.
+// The first account receives credentials set through /admin/credentials.
+func NewServerWithAccounts(accts []*accounts.Account) *Server {
 	s := &Server{
-		httpClient:   serverhttp.NewHTTPClient(),
-		provider:     provider,
-		projectID:    projectID,
-		mux:          http.NewServeMux(),
-		geminiClient: gemini.NewClient(provider),
+		httpClient:  serverhttp.NewHTTPClient(),
+		mediaClient: serverhttp.NewMediaHTTPClient(),
+		mux:         http.NewServeMux(),
+		accounts:    newAccountPoolFromEnv(accts),
 	}
+	s.apiKeys = newAPIKeyStore()
+	s.limiter = newLimiterFromEnv()
+	s.ledger = newUsageLedger()
+	metrics.CredentialExpiry.Set(s.credentialExpirySamples)
+	tracing.SetTracer(newTracerFromEnv())
+	s.responseStore = newResponseStoreFromEnv()
+	s.thoughtSignatures = newThoughtSignatureStoreFromEnv()
 	s.setupRoutes()
 
 	return s
@@ -51,43 +86,75 @@ func (s *Server) Start(addr string) error {
 	return http.ListenAndServe(addr, loggingMiddleware(s.mux))
 }
 
-// LoadCredentials loads OAuth credentials using the configured provider
+// LoadCredentials loads the OAuth credentials of every account, refreshing tokens that are about to expire
 func (s *Server) LoadCredentials(isPeriodicRefresh bool) error {
-	creds, err := s.provider.GetCredentials()
-	if err != nil {
-		return err
+	var firstErr error
+	for i, account := range s.accounts.Accounts() {
+		creds, err := loadAccountCredentials(account, isPeriodicRefresh)
+		if err != nil {
+			if len(s.accounts.Accounts()) > 1 {
+				logger.Get().Error().Err(err).Str("account", account.Name).Msg("Failed to load account credentials")
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if i == 0 {
+			s.oauthCreds = creds
+		}
 	}
+	return firstErr
+}
 
-	s.oauthCreds = creds
+func loadAccountCredentials(account *accounts.Account, isPeriodicRefresh bool) (*credentials.OAuthCredentials, error) {
+	provider := account.Provider
+	creds, err := provider.GetCredentials()
+	if err != nil {
+		return nil, err
+	}
 
 	// Check if token is expired (with a 5-minute buffer)
 	if creds.ExpiryDate > 0 {
 		expiryTime := time.Unix(creds.ExpiryDate/1000, 0)
 		if time.Now().After(expiryTime.Add(-5 * time.Minute)) {
-			logger.Get().Info().Msg("OAuth token is expired or expiring soon, attempting to refresh...")
-			if err := s.provider.RefreshToken(); err != nil {
-				logger.Get().Error().Err(err).Msg("Failed to refresh OAuth token")
+			logger.Get().Info().Str("account", account.Name).Msg("OAuth token is expired or expiring soon, attempting to refresh...")
+			if err := provider.RefreshToken(); err != nil {
+				logger.Get().Error().Err(err).Str("account", account.Name).Msg("Failed to refresh OAuth token")
 				// Continue with the expired token, the API call might still work or will fail with 401
 			} else {
 				// Reload credentials after refresh
-				creds, err = s.provider.GetCredentials()
+				creds, err = provider.GetCredentials()
 				if err != nil {
-					return err
+					return nil, err
 				}
-				s.oauthCreds = creds
 			}
 		} else {
 			if !isPeriodicRefresh {
 				timeUntilExpiry := time.Until(expiryTime)
-				logger.Get().Info().Dur("valid_for", timeUntilExpiry.Round(time.Second)).Msg("OAuth token valid")
+				logger.Get().Info().Str("account", account.Name).Dur("valid_for", timeUntilExpiry.Round(time.Second)).Msg("OAuth token valid")
 			}
 		}
 	}
 
 	if !isPeriodicRefresh {
-		logger.Get().Info().Str("provider", s.provider.Name()).Msg("Loaded OAuth credentials")
+		logger.Get().Info().Str("account", account.Name).Str("provider", provider.Name()).Msg("Loaded OAuth credentials")
+	}
+	return creds, nil
+}
+
+// credentialExpirySamples returns the seconds until the access token of each account expires.
+func (s *Server) credentialExpirySamples() []metrics.Sample {
+	var samples []metrics.Sample
+	for _, account := range s.accounts.Accounts() {
+		creds, err := account.Provider.GetCredentials()
+		if err != nil || creds.ExpiryDate == 0 {
+			continue
+		}
+		expiry := time.UnixMilli(creds.ExpiryDate)
+		samples = append(samples, metrics.Sample{LabelValues: []string{account.Name}, Value: time.Until(expiry).Seconds()})
 	}
-	return nil
+	return samples
 }
 
 // startTokenRefreshLoop starts a goroutine to periodically refresh the OAuth token.
@@ -117,14 +184,92 @@ func (s *Server) startTokenRefreshLoop() {
 	}()
 }
 
+// newResponseStoreFromEnv creates the Responses API conversation store.
+// RESPONSES_STORE_TTL (default 24h) and RESPONSES_STORE_MAX_ENTRIES (default 1000) bound its size.
+func newResponseStoreFromEnv() ResponseStore {
+	ttlStr := env.GetOrDefault("RESPONSES_STORE_TTL", "24h")
+	ttl, err := time.ParseDuration(ttlStr)
+	if err != nil {
+		logger.Get().Warn().Err(err).Str("value", ttlStr).Msg("Invalid responses store TTL, defaulting to 24 hours")
+		ttl = 24 * time.Hour
+	}
+
+	maxStr := env.GetOrDefault("RESPONSES_STORE_MAX_ENTRIES", "1000")
+	maxEntries, err := strconv.Atoi(maxStr)
+	if err != nil {
+		logger.Get().Warn().Err(err).Str("value", maxStr).Msg("Invalid responses store size, defaulting to 1000")
+		maxEntries = 1000
+	}
+
+	return NewMemoryResponseStore(ttl, maxEntries)
+}
+
+// newThoughtSignatureStoreFromEnv builds the in-memory thought signature store.
+func newThoughtSignatureStoreFromEnv() ThoughtSignatureStore {
+	ttlStr := env.GetOrDefault("THOUGHT_SIGNATURE_TTL", "24h")
+	ttl, err := time.ParseDuration(ttlStr)
+	if err != nil {
+		logger.Get().Warn().Err(err).Str("value", ttlStr).Msg("Invalid thought signature TTL, defaulting to 24 hours")
+		ttl = 24 * time.Hour
+	}
+
+	maxStr := env.GetOrDefault("THOUGHT_SIGNATURE_MAX_ENTRIES", "10000")
+	maxEntries, err := strconv.Atoi(maxStr)
+	if err != nil {
+		logger.Get().Warn().Err(err).Str("value", maxStr).Msg("Invalid thought signature store size, defaulting to 10000")
+		maxEntries = 10000
+	}
+
+	return NewMemoryThoughtSignatureStore(ttl, maxEntries)
+}
+
+// newAccountPoolFromEnv builds the account pool.
+// ACCOUNT_STRATEGY (round_robin or least_used) selects the scheduler and ACCOUNT_COOLDOWN (default 60s)
+// is how long an account rests after a 429 without a retry hint.
+func newAccountPoolFromEnv(accts []*accounts.Account) *accounts.Pool {
+	strategy := accounts.Strategy(env.GetOrDefault("ACCOUNT_STRATEGY", string(accounts.StrategyRoundRobin)))
+	if strategy != accounts.StrategyRoundRobin && strategy != accounts.StrategyLeastUsed {
+		logger.Get().Warn().Str("value", string(strategy)).Msg("Invalid account strategy, defaulting to round_robin")
+		strategy = accounts.StrategyRoundRobin
+	}
+
+	cooldownStr := env.GetOrDefault("ACCOUNT_COOLDOWN", "60s")
+	cooldown, err := time.ParseDuration(cooldownStr)
+	if err != nil {
+		logger.Get().Warn().Err(err).Str("value", cooldownStr).Msg("Invalid account cooldown, defaulting to 60 seconds")
+		cooldown = 60 * time.Second
+	}
+
+	return accounts.NewPool(accts, strategy, cooldown)
+}
+
+// newAPIKeyStore opens the API key store of the platform (a file locally, KV on Workers).
+func newAPIKeyStore() apikeys.Store {
+	store, err := apikeys.NewDefaultStore()
+	if err != nil {
+		logger.Get().Warn().Err(err).Msg("API key store unavailable, only ADMIN_API_KEY is accepted")
+		return nil
+	}
+	logger.Get().Info().Str("store", store.Name()).Msg("Using API key store")
+	return store
+}
+
 // setupRoutes configures all HTTP routes
 func (s *Server) setupRoutes() {
 	s.mux.HandleFunc("/admin/credentials", s.adminMiddleware(s.credentialsHandler))
 	s.mux.HandleFunc("/admin/credentials/status", s.adminMiddleware(s.credentialsStatusHandler))
-	s.mux.HandleFunc("/v1beta/models/", s.adminMiddleware(s.streamGenerateContentHandler))
+	s.mux.HandleFunc("/admin/accounts", s.adminMiddleware(s.accountsHandler))
+	s.mux.HandleFunc("/admin/keys", s.adminMiddleware(s.apiKeysHandler))
+	s.mux.HandleFunc("/admin/keys/", s.adminMiddleware(s.apiKeyHandler))
+	s.mux.HandleFunc("/admin/usage", s.adminMiddleware(s.usageHandler))
+	s.mux.HandleFunc("/admin/usage/records", s.adminMiddleware(s.usageRecordsHandler))
+	s.mux.HandleFunc("/v1beta/models/", s.inferenceMiddleware(s.streamGenerateContentHandler))
+	s.mux.Handle("/metrics", metrics.Default.Handler())
 	s.mux.HandleFunc("/v1/models/", s.modelsHandler)
 	s.mux.HandleFunc("/v1/models", s.modelsHandler)
-	s.mux.HandleFunc("/v1/chat/completions", s.adminMiddleware(s.openAIChatCompletionsHandler))
+	s.mux.HandleFunc("/v1/chat/completions", s.inferenceMiddleware(s.openAIChatCompletionsHandler))
+	s.mux.HandleFunc("/v1/messages", s.inferenceMiddleware(s.anthropicMessagesHandler))
+	s.mux.HandleFunc("/v1/responses", s.inferenceMiddleware(s.openAIResponsesHandler))
 }
 
 // ServeHTTP implements http.Handler interface
@@ -148,7 +293,7 @@ func (s *Server) credentialsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Save credentials
-	if err := s.provider.SaveCredentials(&creds); err != nil {
+	if err := s.accounts.Primary().Provider.SaveCredentials(&creds); err != nil {
 		logger.Get().Error().Err(err).Msg("Failed to save credentials")
 		http.Error(w, "Failed to save credentials", http.StatusInternalServerError)
 		return
@@ -174,12 +319,13 @@ func (s *Server) credentialsStatusHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	// Try to get current credentials
-	creds, err := s.provider.GetCredentials()
+	provider := s.accounts.Primary().Provider
+	creds, err := provider.GetCredentials()
 
 	response := map[string]interface{}{
 		"type":           "oauth",
 		"hasCredentials": err == nil && creds != nil,
-		"provider":       s.provider.Name(),
+		"provider":       provider.Name(),
 	}
 
 	if err == nil && creds != nil {
@@ -10,6 +10,7 @@ import (
 
 	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
 	"github.com/dvcrn/gemini-code-assist-proxy/internal/logger"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/tracing"
 )
 
 func (s *Server) streamGenerateContentHandler(w http.ResponseWriter, r *http.Request) {
@@ -39,7 +40,14 @@ func (s *Server) streamGenerateContentHandler(w http.ResponseWriter, r *http.Req
 		logger.Get().Error().
 			Str("path", r.URL.Path).
 			Msg("Invalid path format")
-		http.Error(w, "Invalid path format", http.StatusBadRequest)
+		writeGeminiError(w, http.StatusBadRequest, "Invalid path format")
+		return
+	}
+
+	noteRequest(r, model, normalizedModel, action == "streamGenerateContent")
+	if !modelAllowed(r, model, normalizedModel) {
+		logger.Get().Warn().Str("model", model).Msg("Model not allowed for API key")
+		writeGeminiError(w, http.StatusForbidden, "Model not allowed for this API key: "+model)
 		return
 	}
 
@@ -50,11 +58,14 @@ func (s *Server) streamGenerateContentHandler(w http.ResponseWriter, r *http.Req
 	case "generateContent":
 		s.handleGenerateContent(w, r, normalizedModel)
 
+	case "countTokens":
+		s.handleCountTokens(w, r, normalizedModel)
+
 	default:
 		logger.Get().Warn().
 			Str("action", action).
 			Msg("Unknown action")
-		http.Error(w, "Unknown action: "+action, http.StatusBadRequest)
+		writeGeminiError(w, http.StatusBadRequest, "Unknown action: "+action)
 		return
 	}
 
@@ -74,7 +85,7 @@ func (s *Server) handleGenerateContent(w http.ResponseWriter, r *http.Request, m
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		logger.Get().Error().Err(err).Msg("Failed to read request body")
-		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		writeGeminiError(w, http.StatusBadRequest, "Error reading request body")
 		return
 	}
 	defer r.Body.Close()
@@ -82,12 +93,14 @@ func (s *Server) handleGenerateContent(w http.ResponseWriter, r *http.Request, m
 	var requestBody gemini.GeminiInternalRequest
 	if err := json.Unmarshal(body, &requestBody); err != nil {
 		logger.Get().Error().Err(err).Msg("Failed to parse request body")
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		writeGeminiError(w, http.StatusBadRequest, "Invalid JSON")
 		return
 	}
 
 	// Sanitize request for CloudCode compatibility
+	endTransform := traceStep(r.Context(), "transform.request", tracing.String("gcap.api", "gemini"))
 	sanitizeGeminiRequest(&requestBody, model)
+	endTransform(nil)
 
 	logger.Get().Debug().
 		Str("model", model).
@@ -96,19 +109,18 @@ func (s *Server) handleGenerateContent(w http.ResponseWriter, r *http.Request, m
 
 	genReq := &gemini.GenerateContentRequest{
 		Model:   model,
-		Project: s.projectID,
 		Request: requestBody,
 	}
 
 	apiCallStart := time.Now()
-	resp, err := s.geminiClient.GenerateContent(genReq)
+	resp, err := s.generateContent(r.Context(), genReq)
 	if err != nil {
 		logger.Get().Error().
 			Err(err).
 			Str("model", model).
 			Dur("api_call_duration", time.Since(apiCallStart)).
 			Msg("GenerateContent failed")
-		http.Error(w, fmt.Sprintf("Error calling GenerateContent: %v", err), http.StatusInternalServerError)
+		writeUpstreamError(w, r, err)
 		return
 	}
 
@@ -131,6 +143,88 @@ func (s *Server) handleGenerateContent(w http.ResponseWriter, r *http.Request, m
 		Msg("generateContent completed")
 }
 
+func (s *Server) handleCountTokens(w http.ResponseWriter, r *http.Request, model string) {
+	startTime := time.Now()
+
+	logger.Get().Info().
+		Str("model", model).
+		Msg("Handling countTokens")
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		logger.Get().Error().Err(err).Msg("Failed to read request body")
+		writeGeminiError(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	contents, err := parseCountTokensContents(body)
+	if err != nil {
+		logger.Get().Error().Err(err).Msg("Failed to parse request body")
+		writeGeminiError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	// CloudCode's countTokens takes no project and expects the "models/" prefix
+	countReq := &gemini.CountTokensRequest{
+		Request: gemini.CountTokensInternalRequest{
+			Model:    "models/" + model,
+			Contents: contents,
+		},
+	}
+
+	apiCallStart := time.Now()
+	resp, err := s.countTokens(r.Context(), countReq)
+	if err != nil {
+		logger.Get().Error().
+			Err(err).
+			Str("model", model).
+			Dur("api_call_duration", time.Since(apiCallStart)).
+			Msg("CountTokens failed")
+		writeUpstreamError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logger.Get().Error().Err(err).Msg("Failed to encode response")
+		return
+	}
+
+	logger.Get().Info().
+		Str("model", model).
+		Int("total_tokens", resp.TotalTokens).
+		Dur("total_duration", time.Since(startTime)).
+		Msg("countTokens completed")
+}
+
+// parseCountTokensContents extracts the contents of a public countTokens request.
+// Clients send either {contents} or {generateContentRequest: {contents, ...}}; for the
+// latter, the system instruction is counted as a leading user turn since CloudCode's
+// countTokens only accepts contents.
+func parseCountTokensContents(body []byte) ([]gemini.Content, error) {
+	var req struct {
+		Contents               []gemini.Content              `json:"contents"`
+		GenerateContentRequest *gemini.GeminiInternalRequest `json:"generateContentRequest"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+
+	if len(req.Contents) > 0 || req.GenerateContentRequest == nil {
+		return req.Contents, nil
+	}
+
+	gen := req.GenerateContentRequest
+	var contents []gemini.Content
+	if gen.SystemInstruction != nil && len(gen.SystemInstruction.Parts) > 0 {
+		contents = append(contents, gemini.Content{Role: "user", Parts: gen.SystemInstruction.Parts})
+	}
+	return append(contents, gen.Contents...), nil
+}
+
 func (s *Server) handleStreamGenerateContent(w http.ResponseWriter, r *http.Request, model string) {
 	startTime := time.Now()
 	logger.Get().Info().
@@ -141,7 +235,7 @@ func (s *Server) handleStreamGenerateContent(w http.ResponseWriter, r *http.Requ
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		logger.Get().Error().Err(err).Msg("Failed to read request body")
-		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		writeGeminiError(w, http.StatusBadRequest, "Error reading request body")
 		return
 	}
 	defer r.Body.Close()
@@ -149,38 +243,31 @@ func (s *Server) handleStreamGenerateContent(w http.ResponseWriter, r *http.Requ
 	var requestBody gemini.GeminiInternalRequest
 	if err := json.Unmarshal(body, &requestBody); err != nil {
 		logger.Get().Error().Err(err).Msg("Failed to parse request body")
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		writeGeminiError(w, http.StatusBadRequest, "Invalid JSON")
 		return
 	}
 
 	// Sanitize request for CloudCode compatibility
+	endTransform := traceStep(r.Context(), "transform.request", tracing.String("gcap.api", "gemini"))
 	sanitizeGeminiRequest(&requestBody, model)
+	endTransform(nil)
 
 	// Build CloudCode request wrapper
 	genReq := &gemini.GenerateContentRequest{
 		Model:   model,
-		Project: s.projectID,
 		Request: requestBody,
 	}
 
-	// Prepare SSE response headers
-	w.Header().Del("Content-Length")
-	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.WriteHeader(http.StatusOK)
-
-	// Flush headers if supported
-	var flusher http.Flusher
-	if f, ok := w.(http.Flusher); ok {
-		flusher = f
-		flusher.Flush()
+	release, ok := s.acquireStream(w, r)
+	if !ok {
+		return
 	}
+	defer release()
 
 	// Start upstream streaming and pipe raw lines
 	lines := make(chan string, 16)
 	apiCallStart := time.Now()
-	if err := s.geminiClient.StreamGenerateContent(r.Context(), genReq, lines); err != nil {
+	if err := s.streamGenerateContent(r.Context(), genReq, lines); err != nil {
 		logger.Get().Error().
 			Err(err).
 			Str("model", model).
@@ -241,21 +328,25 @@ func (s *Server) handleStreamGenerateContent(w http.ResponseWriter, r *http.Requ
 			Int("function_declarations", fnDecls).
 			Int("max_output_tokens", maxTok).
 			Msg("Upstream request summary (on error)")
-		// We already wrote SSE headers; emit an SSE-formatted error payload instead of resetting headers.
-		errPayload := map[string]interface{}{
-			"error": map[string]interface{}{
-				"message": err.Error(),
-			},
-		}
-		if b, mErr := json.Marshal(errPayload); mErr == nil {
-			_, _ = fmt.Fprintf(w, "data: %s\n\n", string(b))
-			if f, ok := w.(http.Flusher); ok {
-				f.Flush()
-			}
-		}
+		// Nothing has been written yet, so the client gets the upstream status and error body
+		writeUpstreamError(w, r, err)
 		return
 	}
 
+	// Prepare SSE response headers; only committed once the upstream stream is open
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	// Flush headers if supported
+	var flusher http.Flusher
+	if f, ok := w.(http.Flusher); ok {
+		flusher = f
+		flusher.Flush()
+	}
+
 	// Stream loop: transform data lines and forward to client
 	firstWrite := true
 	// Send SSE keepalives until first upstream byte to avoid idle timeouts
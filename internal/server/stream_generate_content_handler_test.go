@@ -0,0 +1,105 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCountTokensContents(t *testing.T) {
+	t.Run("contents", func(t *testing.T) {
+		contents, err := parseCountTokensContents([]byte(`{"contents": [{"role": "user", "parts": [{"text": "hello"}]}]}`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(contents) != 1 || contents[0].Parts[0].Text != "hello" {
+			t.Errorf("unexpected contents: %+v", contents)
+		}
+	})
+
+	t.Run("generateContentRequest", func(t *testing.T) {
+		contents, err := parseCountTokensContents([]byte(`{
+			"generateContentRequest": {
+				"model": "models/gemini-2.5-pro",
+				"systemInstruction": {"parts": [{"text": "be brief"}]},
+				"contents": [{"role": "user", "parts": [{"text": "hello"}]}]
+			}
+		}`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(contents) != 2 {
+			t.Fatalf("expected 2 contents, got %d", len(contents))
+		}
+		if contents[0].Role != "user" || contents[0].Parts[0].Text != "be brief" {
+			t.Errorf("system instruction not counted first: %+v", contents[0])
+		}
+		if contents[1].Parts[0].Text != "hello" {
+			t.Errorf("unexpected contents: %+v", contents[1])
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := parseCountTokensContents([]byte(`{`)); err == nil {
+			t.Error("expected error for invalid JSON")
+		}
+	})
+}
+
+// TestGenerateContentHandler_ForwardsRecordedPayloads sends the client payloads of the gemini
+// package through the native handler and checks that Code Assist receives every field, apart
+// from the rewrites of sanitizeGeminiRequest.
+func TestGenerateContentHandler_ForwardsRecordedPayloads(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "gemini", "testdata", "*_request.json"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no payloads found: %v", err)
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			payload, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var forwarded []byte
+			upstream := &fakeHTTPClient{respond: func(req *http.Request) (*http.Response, error) {
+				forwarded, _ = io.ReadAll(req.Body)
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"response":{"candidates":[]}}`))}, nil
+			}}
+			s := &Server{accounts: newTestAccounts(upstream)}
+
+			rec := httptest.NewRecorder()
+			s.streamGenerateContentHandler(rec, httptest.NewRequest(http.MethodPost, "/v1beta/models/gemini-2.5-pro:generateContent", bytes.NewReader(payload)))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+
+			var envelope struct {
+				Request map[string]interface{} `json:"request"`
+			}
+			if err := json.Unmarshal(forwarded, &envelope); err != nil {
+				t.Fatalf("invalid upstream body: %v", err)
+			}
+			var want map[string]interface{}
+			if err := json.Unmarshal(payload, &want); err != nil {
+				t.Fatal(err)
+			}
+			// sanitizeGeminiRequest forces the role of the system instruction
+			if si, ok := want["systemInstruction"].(map[string]interface{}); ok {
+				si["role"] = "system"
+			}
+			if !reflect.DeepEqual(want, envelope.Request) {
+				got, _ := json.MarshalIndent(envelope.Request, "", "  ")
+				t.Errorf("forwarded request differs from the client payload:\n%s", got)
+			}
+		})
+	}
+}
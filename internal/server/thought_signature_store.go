@@ -0,0 +1,118 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/openai"
+)
+
+// ThoughtSignatureStore remembers Gemini thought signatures by the OpenAI tool_call_id
+// they were returned under. Chat completions have no field to carry them, so they are
+// re-attached from here when the client sends the tool calls back as history.
+type ThoughtSignatureStore interface {
+	// Get returns the signature recorded for a tool call, if present and not expired
+	Get(toolCallID string) (string, bool)
+
+	// Put records the signature for a tool call
+	Put(toolCallID, signature string)
+}
+
+type storedSignature struct {
+	signature string
+	createdAt time.Time
+	seq       uint64 // position in the insertion order
+}
+
+// queuedSignature is a position in the insertion order. It is stale once its entry was
+// removed or put again under a later position.
+type queuedSignature struct {
+	toolCallID string
+	seq        uint64
+}
+
+// memoryThoughtSignatureStore is an in-process ThoughtSignatureStore with a TTL and a size cap.
+type memoryThoughtSignatureStore struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]storedSignature
+	order      []queuedSignature // insertion order, oldest first
+	seq        uint64
+}
+
+// NewMemoryThoughtSignatureStore creates an in-memory ThoughtSignatureStore.
+func NewMemoryThoughtSignatureStore(ttl time.Duration, maxEntries int) ThoughtSignatureStore {
+	return &memoryThoughtSignatureStore{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]storedSignature),
+	}
+}
+
+func (m *memoryThoughtSignatureStore) Get(toolCallID string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[toolCallID]
+	if !ok {
+		return "", false
+	}
+	if m.ttl > 0 && time.Since(entry.createdAt) > m.ttl {
+		delete(m.entries, toolCallID)
+		return "", false
+	}
+	return entry.signature, true
+}
+
+func (m *memoryThoughtSignatureStore) Put(toolCallID, signature string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.seq++
+	m.entries[toolCallID] = storedSignature{signature: signature, createdAt: time.Now(), seq: m.seq}
+	m.order = append(m.order, queuedSignature{toolCallID: toolCallID, seq: m.seq})
+
+	// Evict expired entries and enforce the size cap, oldest first
+	for len(m.order) > 0 {
+		oldest := m.order[0]
+		entry, ok := m.entries[oldest.toolCallID]
+		if !ok || entry.seq != oldest.seq {
+			m.order = m.order[1:]
+			continue
+		}
+		expired := m.ttl > 0 && time.Since(entry.createdAt) > m.ttl
+		if !expired && (m.maxEntries <= 0 || len(m.entries) <= m.maxEntries) {
+			break
+		}
+		delete(m.entries, oldest.toolCallID)
+		m.order = m.order[1:]
+	}
+}
+
+// restoreThoughtSignatures re-attaches remembered signatures to the assistant tool calls in messages.
+func (s *Server) restoreThoughtSignatures(messages []openai.Message) {
+	if s.thoughtSignatures == nil {
+		return
+	}
+	for mi := range messages {
+		for ti := range messages[mi].ToolCalls {
+			tc := &messages[mi].ToolCalls[ti]
+			if tc.ThoughtSignature != "" || tc.ID == "" {
+				continue
+			}
+			if sig, ok := s.thoughtSignatures.Get(tc.ID); ok {
+				tc.ThoughtSignature = sig
+			}
+		}
+	}
+}
+
+// rememberThoughtSignature records the signature Gemini returned with a tool call.
+// It is used directly as the stream transformer's recorder.
+func (s *Server) rememberThoughtSignature(toolCallID, signature string) {
+	if s.thoughtSignatures == nil || toolCallID == "" || signature == "" {
+		return
+	}
+	s.thoughtSignatures.Put(toolCallID, signature)
+}
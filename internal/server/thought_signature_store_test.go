@@ -0,0 +1,83 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/openai"
+)
+
+func TestMemoryThoughtSignatureStore_TTLAndCap(t *testing.T) {
+	store := NewMemoryThoughtSignatureStore(time.Hour, 2)
+	store.Put("call_1", "sig-1")
+	store.Put("call_2", "sig-2")
+	store.Put("call_3", "sig-3")
+
+	if _, ok := store.Get("call_1"); ok {
+		t.Error("expected oldest entry to be evicted by the size cap")
+	}
+	if sig, ok := store.Get("call_3"); !ok || sig != "sig-3" {
+		t.Errorf("expected sig-3, got %q (%v)", sig, ok)
+	}
+
+	expiring := NewMemoryThoughtSignatureStore(time.Nanosecond, 0)
+	expiring.Put("call_1", "sig-1")
+	time.Sleep(time.Millisecond)
+	if _, ok := expiring.Get("call_1"); ok {
+		t.Error("expected entry to expire")
+	}
+}
+
+func TestMemoryThoughtSignatureStore_PutAgainAfterExpiry(t *testing.T) {
+	store := NewMemoryThoughtSignatureStore(time.Hour, 3).(*memoryThoughtSignatureStore)
+	store.Put("call_1", "sig-1")
+	store.Put("call_2", "sig-2")
+	store.Put("call_3", "sig-3")
+
+	// call_2 expires and is removed by Get, then the same id is recorded again
+	expired := store.entries["call_2"]
+	expired.createdAt = time.Now().Add(-2 * time.Hour)
+	store.entries["call_2"] = expired
+	if _, ok := store.Get("call_2"); ok {
+		t.Fatal("expected entry to expire")
+	}
+	store.Put("call_2", "sig-2b")
+	store.Put("call_4", "sig-4")
+	store.Put("call_5", "sig-5")
+
+	for _, evicted := range []string{"call_1", "call_3"} {
+		if _, ok := store.Get(evicted); ok {
+			t.Errorf("expected %s to be evicted as one of the oldest entries", evicted)
+		}
+	}
+	if sig, ok := store.Get("call_2"); !ok || sig != "sig-2b" {
+		t.Errorf("expected the re-recorded call_2 to be kept, got %q (%v)", sig, ok)
+	}
+	if len(store.order) != 3 {
+		t.Errorf("expected stale positions to be dropped, got %+v", store.order)
+	}
+}
+
+func TestRestoreThoughtSignatures(t *testing.T) {
+	s := &Server{thoughtSignatures: NewMemoryThoughtSignatureStore(time.Hour, 0)}
+	s.rememberThoughtSignature("call_1", "sig-1")
+	s.rememberThoughtSignature("call_2", "")
+
+	messages := []openai.Message{
+		{Role: "user", Content: "hi"},
+		{Role: "assistant", ToolCalls: []openai.OpenAIToolCall{
+			{ID: "call_1", Function: openai.OpenAIFunctionCall{Name: "read"}},
+			{ID: "call_2", Function: openai.OpenAIFunctionCall{Name: "read"}},
+			{ID: "call_unknown", Function: openai.OpenAIFunctionCall{Name: "read"}},
+		}},
+	}
+	s.restoreThoughtSignatures(messages)
+
+	calls := messages[1].ToolCalls
+	if calls[0].ThoughtSignature != "sig-1" {
+		t.Errorf("expected sig-1 to be restored, got %q", calls[0].ThoughtSignature)
+	}
+	if calls[1].ThoughtSignature != "" || calls[2].ThoughtSignature != "" {
+		t.Errorf("expected no signature for unknown calls, got %q and %q", calls[1].ThoughtSignature, calls[2].ThoughtSignature)
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/env"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/logger"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/tracing"
+)
+
+// newTracerFromEnv builds the OTLP tracer from the standard OpenTelemetry variables.
+// Tracing is disabled (nil) unless a collector endpoint is set.
+func newTracerFromEnv() *tracing.Tracer {
+	endpoint, ok := env.Get("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if !ok {
+		base, ok := env.Get("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if !ok {
+			return nil
+		}
+		endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+	}
+
+	ratioStr := env.GetOrDefault("OTEL_TRACES_SAMPLER_ARG", "1")
+	ratio, err := strconv.ParseFloat(ratioStr, 64)
+	if err != nil || ratio < 0 || ratio > 1 {
+		logger.Get().Warn().Str("value", ratioStr).Msg("Invalid OTEL_TRACES_SAMPLER_ARG, sampling every trace")
+		ratio = 1
+	}
+
+	// OTEL_EXPORTER_OTLP_HEADERS is a list of key=value pairs separated by commas
+	headers := map[string]string{}
+	if str, ok := env.Get("OTEL_EXPORTER_OTLP_HEADERS"); ok {
+		for _, pair := range strings.Split(str, ",") {
+			k, v, ok := strings.Cut(pair, "=")
+			if !ok || strings.TrimSpace(k) == "" {
+				logger.Get().Warn().Str("value", pair).Msg("Ignoring invalid OTEL_EXPORTER_OTLP_HEADERS entry")
+				continue
+			}
+			headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+
+	serviceName := env.GetOrDefault("OTEL_SERVICE_NAME", "gemini-code-assist-proxy")
+	logger.Get().Info().
+		Str("endpoint", endpoint).
+		Str("service_name", serviceName).
+		Float64("sample_ratio", ratio).
+		Msg("Exporting traces to OTLP collector")
+	return tracing.NewTracer(tracing.NewOTLPExporter(endpoint, serviceName, headers), tracing.Config{SampleRatio: ratio})
+}
+
+// tracingMiddleware wraps the request in a server span, continuing the trace of an incoming
+// W3C traceparent header.
+func (s *Server) tracingMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		route := metricsRoute(r.URL.Path)
+		ctx, span := tracing.Start(tracing.Extract(r.Context(), r.Header), r.Method+" "+route, tracing.KindServer,
+			tracing.String("http.request.method", r.Method),
+			tracing.String("http.route", route),
+			tracing.String("url.path", r.URL.Path))
+		if span == nil {
+			next(w, r)
+			return
+		}
+		defer span.End()
+
+		sr := &statusRecorder{ResponseWriter: w}
+		next(sr, r.WithContext(ctx))
+
+		status := sr.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(tracing.Int("http.response.status_code", status))
+		if status >= 500 {
+			span.SetError(http.StatusText(status))
+		}
+	}
+}
+
+// traceStep starts an internal span for a step of handling a request. The returned function
+// ends it, recording err if it is not nil.
+func traceStep(ctx context.Context, name string, attrs ...tracing.Attr) func(err error) {
+	_, span := tracing.Start(ctx, name, tracing.KindInternal, attrs...)
+	return func(err error) {
+		span.RecordError(err)
+		span.End()
+	}
+}
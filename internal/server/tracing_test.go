@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/tracing"
+)
+
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []*tracing.Span
+}
+
+func (e *spanRecorder) Export(ctx context.Context, spans []*tracing.Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func TestTracingMiddlewareContinuesIncomingTrace(t *testing.T) {
+	t.Setenv("ADMIN_API_KEY", "admin-secret")
+	exporter := &spanRecorder{}
+	tracer := tracing.NewTracer(exporter, tracing.Config{SampleRatio: 1, FlushInterval: time.Hour})
+	tracing.SetTracer(tracer)
+	defer tracing.SetTracer(nil)
+
+	s := &Server{mux: http.NewServeMux()}
+	var handlerSpan *tracing.Span
+	handler := s.inferenceMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		handlerSpan = tracing.SpanFromContext(r.Context())
+		traceStep(r.Context(), "transform.request")(nil)
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler(httptest.NewRecorder(), req)
+
+	if handlerSpan == nil {
+		t.Fatal("expected the handler to run in a span")
+	}
+	if got := handlerSpan.SpanContext().TraceID.String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected the incoming trace ID, got %s", got)
+	}
+
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(exporter.spans) != 2 {
+		t.Fatalf("expected the server and transform spans, got %d", len(exporter.spans))
+	}
+	for _, span := range exporter.spans {
+		if span.SpanContext().TraceID != handlerSpan.SpanContext().TraceID {
+			t.Errorf("span %s is not part of the incoming trace", span.SpanContext().SpanID)
+		}
+	}
+}
+
+func TestTracingMiddlewareDisabled(t *testing.T) {
+	tracing.SetTracer(nil)
+	s := &Server{mux: http.NewServeMux()}
+	called := false
+	handler := s.tracingMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		if tracing.SpanFromContext(r.Context()) != nil {
+			t.Error("expected no span without a tracer")
+		}
+	})
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+	if !called {
+		t.Error("expected the handler to be called")
+	}
+}
+
+func TestNewTracerFromEnv(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	if newTracerFromEnv() != nil {
+		t.Error("expected tracing to be disabled without an endpoint")
+	}
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318/")
+	tracer := newTracerFromEnv()
+	if tracer == nil {
+		t.Fatal("expected a tracer")
+	}
+	tracer.Shutdown(context.Background())
+}
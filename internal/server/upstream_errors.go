@@ -0,0 +1,191 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/accounts"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
+)
+
+// upstreamFailure is the failure of an upstream call as reported to the client.
+type upstreamFailure struct {
+	status     int    // HTTP status of the response
+	rpcStatus  string // google.rpc code name, e.g. RESOURCE_EXHAUSTED
+	message    string
+	details    []json.RawMessage
+	retryAfter time.Duration
+}
+
+// translateUpstreamError maps an error of the account pool or gemini.Client to the status and
+// message the client gets. Google error bodies are passed on; a 401 means the proxy's own
+// credentials were rejected, which is a 502 for the client.
+func translateUpstreamError(err error) upstreamFailure {
+	var unavailable *accounts.UnavailableError
+	var apiErr *gemini.APIError
+	switch {
+	case errors.As(err, &unavailable):
+		f := upstreamFailure{
+			status:     http.StatusTooManyRequests,
+			message:    unavailable.Error(),
+			retryAfter: unavailable.RetryAfter,
+		}
+		f.rpcStatus = rpcStatusName(f.status)
+		return f
+
+	case errors.As(err, &apiErr):
+		f := upstreamFailure{status: apiErr.StatusCode}
+		if f.status == http.StatusUnauthorized || f.status < 400 || f.status > 599 {
+			f.status = http.StatusBadGateway
+		}
+		if gs, ok := apiErr.GoogleStatus(); ok {
+			f.rpcStatus, f.message, f.details = gs.Status, gs.Message, gs.Details
+		}
+		if f.message == "" {
+			f.message = strings.TrimSpace(string(apiErr.Body))
+			if f.message == "" || len(f.message) > 1024 {
+				f.message = http.StatusText(apiErr.StatusCode)
+			}
+		}
+		if f.rpcStatus == "" || f.status == http.StatusBadGateway {
+			f.rpcStatus = rpcStatusName(f.status)
+		}
+		if f.status == http.StatusTooManyRequests || f.status == http.StatusServiceUnavailable {
+			f.retryAfter, _ = apiErr.RetryDelay()
+		}
+		return f
+
+	case errors.Is(err, context.DeadlineExceeded):
+		return upstreamFailure{status: http.StatusGatewayTimeout, rpcStatus: "DEADLINE_EXCEEDED", message: "Upstream request timed out"}
+
+	default:
+		return upstreamFailure{status: http.StatusBadGateway, rpcStatus: "UNAVAILABLE", message: "Upstream request failed: " + err.Error()}
+	}
+}
+
+// rpcStatusName returns the google.rpc code name of an HTTP status.
+func rpcStatusName(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "INVALID_ARGUMENT"
+	case http.StatusUnauthorized:
+		return "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusConflict:
+		return "ABORTED"
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusNotImplemented:
+		return "UNIMPLEMENTED"
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	case http.StatusGatewayTimeout:
+		return "DEADLINE_EXCEEDED"
+	case http.StatusInternalServerError:
+		return "INTERNAL"
+	}
+	return "UNKNOWN"
+}
+
+// writeUpstreamError writes the failure of an upstream call with a matching status, shaped
+// like the errors of the API the client speaks.
+func writeUpstreamError(w http.ResponseWriter, r *http.Request, err error) {
+	f := translateUpstreamError(err)
+	writeAPIError(w, r, f.status, f.rpcStatus, f.message, f.retryAfter, f.details)
+}
+
+// writeAPIError writes an error shaped like the errors of the API the client speaks:
+// Anthropic for /v1/messages, OpenAI for the other /v1 routes, Gemini (google.rpc.Status)
+// otherwise. A positive retryAfter is sent as Retry-After and, on the Gemini routes, as a
+// google.rpc.RetryInfo detail unless details are given.
+func writeAPIError(w http.ResponseWriter, r *http.Request, status int, rpcStatus, message string, retryAfter time.Duration, details []json.RawMessage) {
+	retrySeconds := 0
+	if retryAfter > 0 {
+		retrySeconds = max(int(math.Ceil(retryAfter.Seconds())), 1)
+		w.Header().Set("Retry-After", strconv.Itoa(retrySeconds))
+	}
+
+	switch {
+	case r.URL.Path == "/v1/messages":
+		writeAnthropicError(w, status, anthropicErrorType(status), message)
+
+	case strings.HasPrefix(r.URL.Path, "/v1/"):
+		errType, code := "server_error", strings.ToLower(rpcStatus)
+		switch {
+		case status == http.StatusTooManyRequests:
+			errType, code = "rate_limit_error", "rate_limit_exceeded"
+		case status < 500:
+			errType = "invalid_request_error"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": map[string]interface{}{
+				"message": message,
+				"type":    errType,
+				"param":   nil,
+				"code":    code,
+			},
+		})
+
+	default:
+		if details == nil && retrySeconds > 0 {
+			retryInfo, _ := json.Marshal(map[string]interface{}{
+				"@type":      "type.googleapis.com/google.rpc.RetryInfo",
+				"retryDelay": strconv.Itoa(retrySeconds) + "s",
+			})
+			details = []json.RawMessage{retryInfo}
+		}
+		writeGoogleStatus(w, status, rpcStatus, message, details)
+	}
+}
+
+// writeGeminiError writes an error in the Gemini API error envelope.
+func writeGeminiError(w http.ResponseWriter, status int, message string) {
+	writeGoogleStatus(w, status, rpcStatusName(status), message, nil)
+}
+
+// writeGoogleStatus writes a google.rpc.Status error body.
+func writeGoogleStatus(w http.ResponseWriter, status int, rpcStatus, message string, details []json.RawMessage) {
+	if details == nil {
+		details = []json.RawMessage{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    status,
+			"message": message,
+			"status":  rpcStatus,
+			"details": details,
+		},
+	})
+}
+
+// anthropicErrorType returns the Messages API error type of an HTTP status.
+func anthropicErrorType(status int) string {
+	switch status {
+	case http.StatusBadRequest, http.StatusConflict:
+		return "invalid_request_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case http.StatusServiceUnavailable:
+		return "overloaded_error"
+	}
+	return "api_error"
+}
@@ -0,0 +1,205 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/accounts"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
+)
+
+const quotaErrorBody = `[{"error":{"code":429,"message":"Quota exceeded","status":"RESOURCE_EXHAUSTED","details":[{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"2.5s"}]}}]`
+
+func TestTranslateUpstreamError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		status     int
+		rpcStatus  string
+		message    string
+		retryAfter time.Duration
+	}{
+		{
+			name:       "quota exhausted",
+			err:        &gemini.APIError{Method: "generateContent", StatusCode: 429, Body: []byte(quotaErrorBody)},
+			status:     http.StatusTooManyRequests,
+			rpcStatus:  "RESOURCE_EXHAUSTED",
+			message:    "Quota exceeded",
+			retryAfter: 2500 * time.Millisecond,
+		},
+		{
+			name:      "invalid argument",
+			err:       &gemini.APIError{StatusCode: 400, Body: []byte(`{"error":{"code":400,"message":"Invalid JSON payload","status":"INVALID_ARGUMENT"}}`)},
+			status:    http.StatusBadRequest,
+			rpcStatus: "INVALID_ARGUMENT",
+			message:   "Invalid JSON payload",
+		},
+		{
+			name:      "permission denied",
+			err:       &gemini.APIError{StatusCode: 403, Body: []byte(`{"error":{"code":403,"message":"Permission denied","status":"PERMISSION_DENIED"}}`)},
+			status:    http.StatusForbidden,
+			rpcStatus: "PERMISSION_DENIED",
+			message:   "Permission denied",
+		},
+		{
+			name:      "unavailable without a JSON body",
+			err:       &gemini.APIError{StatusCode: 503, Body: []byte("overloaded")},
+			status:    http.StatusServiceUnavailable,
+			rpcStatus: "UNAVAILABLE",
+			message:   "overloaded",
+		},
+		{
+			name:      "rejected proxy credentials",
+			err:       &gemini.APIError{StatusCode: 401, Body: []byte(`{"error":{"code":401,"message":"Invalid token","status":"UNAUTHENTICATED"}}`)},
+			status:    http.StatusBadGateway,
+			rpcStatus: "UNAVAILABLE",
+			message:   "Invalid token",
+		},
+		{
+			name:       "all accounts cooling down",
+			err:        &accounts.UnavailableError{RetryAfter: time.Minute},
+			status:     http.StatusTooManyRequests,
+			rpcStatus:  "RESOURCE_EXHAUSTED",
+			retryAfter: time.Minute,
+		},
+		{
+			name:      "transport error",
+			err:       errors.New("connection reset"),
+			status:    http.StatusBadGateway,
+			rpcStatus: "UNAVAILABLE",
+			message:   "Upstream request failed: connection reset",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := translateUpstreamError(tt.err)
+			if f.status != tt.status || f.rpcStatus != tt.rpcStatus || f.retryAfter != tt.retryAfter {
+				t.Errorf("got status %d %s retry after %s, want %d %s %s", f.status, f.rpcStatus, f.retryAfter, tt.status, tt.rpcStatus, tt.retryAfter)
+			}
+			if tt.message != "" && f.message != tt.message {
+				t.Errorf("got message %q, want %q", f.message, tt.message)
+			}
+		})
+	}
+}
+
+func TestWriteUpstreamError(t *testing.T) {
+	apiErr := &gemini.APIError{Method: "streamGenerateContent", StatusCode: 429, Body: []byte(quotaErrorBody)}
+
+	tests := []struct {
+		path  string
+		check func(t *testing.T, body map[string]interface{})
+	}{
+		{"/v1/chat/completions", func(t *testing.T, body map[string]interface{}) {
+			e := body["error"].(map[string]interface{})
+			if e["message"] != "Quota exceeded" || e["type"] != "rate_limit_error" || e["code"] != "rate_limit_exceeded" {
+				t.Errorf("unexpected OpenAI error: %v", e)
+			}
+		}},
+		{"/v1/messages", func(t *testing.T, body map[string]interface{}) {
+			e := body["error"].(map[string]interface{})
+			if body["type"] != "error" || e["type"] != "rate_limit_error" || e["message"] != "Quota exceeded" {
+				t.Errorf("unexpected Anthropic error: %v", body)
+			}
+		}},
+		{"/v1beta/models/gemini-2.5-pro:streamGenerateContent", func(t *testing.T, body map[string]interface{}) {
+			e := body["error"].(map[string]interface{})
+			if e["status"] != "RESOURCE_EXHAUSTED" || e["code"] != float64(429) || e["message"] != "Quota exceeded" {
+				t.Errorf("unexpected Gemini error: %v", e)
+			}
+			if details, _ := e["details"].([]interface{}); len(details) != 1 {
+				t.Errorf("expected the upstream details to be passed on, got %v", e["details"])
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			writeUpstreamError(rec, httptest.NewRequest(http.MethodPost, tt.path, nil), apiErr)
+
+			if rec.Code != http.StatusTooManyRequests {
+				t.Errorf("expected 429, got %d", rec.Code)
+			}
+			if got := rec.Header().Get("Retry-After"); got != "3" {
+				t.Errorf("expected Retry-After 3, got %q", got)
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("expected a JSON error, got %q", got)
+			}
+			var body map[string]interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid JSON body: %v", err)
+			}
+			tt.check(t, body)
+		})
+	}
+}
+
+func TestWriteAPIError_GeminiDetails(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter time.Duration
+		want       string
+	}{
+		{"without delay", 0, `[]`},
+		{"with delay", 1500 * time.Millisecond, `[{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"2s"}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/v1beta/models/gemini-2.5-pro:generateContent", nil)
+			writeAPIError(rec, req, http.StatusServiceUnavailable, "UNAVAILABLE", "overloaded", tt.retryAfter, nil)
+
+			var body struct {
+				Error struct {
+					Details json.RawMessage `json:"details"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid JSON body: %v", err)
+			}
+			if string(body.Error.Details) != tt.want {
+				t.Errorf("got details %s, want %s", body.Error.Details, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerErrorsUseAPIEnvelopes(t *testing.T) {
+	s := &Server{}
+
+	rec := httptest.NewRecorder()
+	s.openAIChatCompletionsHandler(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader("{")))
+	var openAIErr struct {
+		Error struct {
+			Message string `json:"message"`
+			Type    string `json:"type"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &openAIErr); err != nil {
+		t.Fatalf("invalid JSON body: %v", err)
+	}
+	if rec.Code != http.StatusBadRequest || openAIErr.Error.Type != "invalid_request_error" || openAIErr.Error.Message == "" {
+		t.Errorf("unexpected OpenAI error: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	s.streamGenerateContentHandler(rec, httptest.NewRequest(http.MethodPost, "/v1beta/models/gemini-2.5-pro:generateContent", strings.NewReader("{")))
+	var geminiErr struct {
+		Error struct {
+			Code   int    `json:"code"`
+			Status string `json:"status"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &geminiErr); err != nil {
+		t.Fatalf("invalid JSON body: %v", err)
+	}
+	if rec.Code != http.StatusBadRequest || geminiErr.Error.Code != 400 || geminiErr.Error.Status != "INVALID_ARGUMENT" {
+		t.Errorf("unexpected Gemini error: %d %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,248 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/logger"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/metrics"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/tracing"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/usage"
+)
+
+// tokenUsage holds the token counts Gemini reports in usageMetadata.
+type tokenUsage struct {
+	Prompt   int64
+	Output   int64
+	Thoughts int64
+	Cached   int64
+	Total    int64
+}
+
+// parseUsageMetadata reads usageMetadata from a Gemini response.
+func parseUsageMetadata(resp map[string]interface{}) (tokenUsage, bool) {
+	um, ok := resp["usageMetadata"].(map[string]interface{})
+	if !ok {
+		return tokenUsage{}, false
+	}
+	count := func(field string) int64 {
+		v, _ := um[field].(float64)
+		return int64(v)
+	}
+	u := tokenUsage{
+		Prompt:   count("promptTokenCount"),
+		Output:   count("candidatesTokenCount"),
+		Thoughts: count("thoughtsTokenCount"),
+		Cached:   count("cachedContentTokenCount"),
+		Total:    count("totalTokenCount"),
+	}
+	if u.Total == 0 {
+		u.Total = u.Prompt + u.Output + u.Thoughts
+	}
+	return u, true
+}
+
+// usageFromStreamLine reads usageMetadata from an upstream SSE line. Every chunk reports the
+// usage so far, so the last one seen is the usage of the whole stream.
+func usageFromStreamLine(line string) (tokenUsage, bool) {
+	if !strings.HasPrefix(line, "data: ") || !strings.Contains(line, "usageMetadata") {
+		return tokenUsage{}, false
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &obj); err != nil {
+		return tokenUsage{}, false
+	}
+	return parseUsageMetadata(unwrapCloudCodeResponse(obj))
+}
+
+// recordUsage charges the tokens of a completed upstream call to the client of ctx
+// and adds them to the metrics and the ledger record of the request.
+func (s *Server) recordUsage(ctx context.Context, model string, tokens tokenUsage) {
+	metrics.Tokens.Add(float64(tokens.Prompt), model, "prompt")
+	metrics.Tokens.Add(float64(tokens.Output), model, "output")
+	metrics.Tokens.Add(float64(tokens.Thoughts), model, "thoughts")
+	metrics.Tokens.Add(float64(tokens.Cached), model, "cached")
+
+	if ru := requestUsageFrom(ctx); ru != nil {
+		ru.mu.Lock()
+		ru.tokens.Prompt += tokens.Prompt
+		ru.tokens.Output += tokens.Output
+		ru.tokens.Thoughts += tokens.Thoughts
+		ru.tokens.Cached += tokens.Cached
+		ru.tokens.Total += tokens.Total
+		ru.mu.Unlock()
+	}
+
+	key := clientKey(ctx)
+	if s.limiter == nil || key == nil {
+		return
+	}
+	if err := s.limiter.AddTokens(key.ID, tokens.Total); err != nil {
+		logger.Get().Warn().Err(err).Str("key_id", key.ID).Msg("Failed to record token usage")
+	}
+}
+
+// newUsageLedger opens the usage ledger of the platform (a file locally, memory on Workers).
+func newUsageLedger() usage.Ledger {
+	ledger, err := usage.NewDefaultLedger()
+	if err != nil {
+		logger.Get().Warn().Err(err).Msg("Usage ledger unavailable, requests are not recorded")
+		return nil
+	}
+	logger.Get().Info().Str("ledger", ledger.Name()).Msg("Using usage ledger")
+	return ledger
+}
+
+// requestUsage collects what the metrics and the ledger record about a request while it is
+// handled. Tokens may be added by the goroutine relaying the upstream stream, hence the mutex.
+type requestUsage struct {
+	start time.Time
+	route string
+
+	mu             sync.Mutex
+	requestedModel string
+	model          string
+	stream         bool
+	tokens         tokenUsage
+
+	// relays are the stream relays still charging tokens to the request
+	relays sync.WaitGroup
+}
+
+type requestUsageContextKey struct{}
+
+func requestUsageFrom(ctx context.Context) *requestUsage {
+	ru, _ := ctx.Value(requestUsageContextKey{}).(*requestUsage)
+	return ru
+}
+
+// trackRelay registers a stream relay of the request in ctx. The returned func marks it finished.
+func trackRelay(ctx context.Context) func() {
+	ru := requestUsageFrom(ctx)
+	if ru == nil {
+		return func() {}
+	}
+	ru.relays.Add(1)
+	return ru.relays.Done
+}
+
+// observeFirstToken records the time to the first event of the upstream stream.
+func observeFirstToken(ctx context.Context) {
+	ru := requestUsageFrom(ctx)
+	if ru == nil {
+		return
+	}
+	ru.mu.Lock()
+	model := ru.model
+	ru.mu.Unlock()
+	metrics.TimeToFirstToken.Observe(time.Since(ru.start).Seconds(), ru.route, model)
+}
+
+// metricsRoute returns the route label of a path; the model of native Gemini paths is
+// replaced by a placeholder to keep the label set small.
+func metricsRoute(path string) string {
+	if strings.HasPrefix(path, "/v1beta/models/") {
+		if _, action := parseGeminiPath(path); action != "" {
+			return "/v1beta/models/{model}:" + action
+		}
+		return "/v1beta/models/{model}"
+	}
+	return path
+}
+
+// noteRequest records the requested and normalized model of the request in its ledger record.
+func noteRequest(r *http.Request, requestedModel, model string, stream bool) {
+	if ru := requestUsageFrom(r.Context()); ru != nil {
+		ru.mu.Lock()
+		ru.requestedModel, ru.model, ru.stream = requestedModel, model, stream
+		ru.mu.Unlock()
+	}
+}
+
+// statusRecorder captures the status code written by a handler. It keeps http.Flusher
+// working for the SSE handlers.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	if sr.status == 0 {
+		sr.status = status
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	return sr.ResponseWriter.Write(b)
+}
+
+func (sr *statusRecorder) Flush() {
+	if f, ok := sr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// usageMiddleware records every request in the metrics and the usage ledger once it is handled.
+// It must run after authMiddleware.
+func (s *Server) usageMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ru := &requestUsage{start: start, route: metricsRoute(r.URL.Path)}
+		sr := &statusRecorder{ResponseWriter: w}
+		ctx, cancel := context.WithCancel(context.WithValue(r.Context(), requestUsageContextKey{}, ru))
+		next(sr, r.WithContext(ctx))
+		// Stream relays the handler stopped reading finish the upstream stream without forwarding;
+		// their tokens are charged before the request is recorded
+		cancel()
+		ru.relays.Wait()
+
+		rec := usage.Record{
+			Time:      start.UTC(),
+			Route:     r.URL.Path,
+			Status:    sr.status,
+			LatencyMs: time.Since(start).Milliseconds(),
+		}
+		if rec.Status == 0 {
+			rec.Status = http.StatusOK
+		}
+		if key := clientKey(r.Context()); key != nil {
+			rec.KeyID, rec.KeyName = key.ID, key.Name
+		}
+		ru.mu.Lock()
+		rec.RequestedModel, rec.Model, rec.Stream = ru.requestedModel, ru.model, ru.stream
+		rec.PromptTokens = ru.tokens.Prompt
+		rec.OutputTokens = ru.tokens.Output
+		rec.ThoughtsTokens = ru.tokens.Thoughts
+		rec.CachedTokens = ru.tokens.Cached
+		rec.TotalTokens = ru.tokens.Total
+		ru.mu.Unlock()
+
+		tracing.SpanFromContext(r.Context()).SetAttributes(
+			tracing.String("gcap.key_id", rec.KeyID),
+			tracing.String("gen_ai.request.model", rec.Model),
+			tracing.Bool("gcap.stream", rec.Stream),
+			tracing.Int64("gen_ai.usage.input_tokens", rec.PromptTokens),
+			tracing.Int64("gen_ai.usage.output_tokens", rec.OutputTokens),
+		)
+
+		status := strconv.Itoa(rec.Status)
+		metrics.Requests.Inc(ru.route, rec.Model, status)
+		metrics.RequestDuration.Observe(time.Since(start).Seconds(), ru.route, rec.Model, status)
+
+		if s.ledger == nil {
+			return
+		}
+		if err := s.ledger.Append(rec); err != nil {
+			logger.Get().Warn().Err(err).Msg("Failed to append usage record")
+		}
+	}
+}
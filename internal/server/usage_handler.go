@@ -0,0 +1,150 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/logger"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/usage"
+)
+
+// parseUsageFilter reads the from, to, key and model query parameters. from and to are dates
+// (YYYY-MM-DD, UTC, both inclusive) or RFC 3339 timestamps.
+func parseUsageFilter(r *http.Request) (usage.Filter, error) {
+	q := r.URL.Query()
+	filter := usage.Filter{KeyID: q.Get("key"), Model: q.Get("model")}
+
+	parse := func(name string, endOfDay bool) (time.Time, error) {
+		v := q.Get(name)
+		if v == "" {
+			return time.Time{}, nil
+		}
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t, nil
+		}
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid %s %q, expected YYYY-MM-DD or RFC 3339", name, v)
+		}
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+
+	var err error
+	if filter.From, err = parse("from", false); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parse("to", true); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// queryUsage runs the filter of the request against the ledger, writing an error response on failure.
+func (s *Server) queryUsage(w http.ResponseWriter, r *http.Request) ([]usage.Record, bool) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	if s.ledger == nil {
+		http.Error(w, "Usage ledger not available", http.StatusServiceUnavailable)
+		return nil, false
+	}
+	if format := r.URL.Query().Get("format"); format != "" && format != "json" && format != "csv" {
+		http.Error(w, "format must be json or csv", http.StatusBadRequest)
+		return nil, false
+	}
+
+	filter, err := parseUsageFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	records, err := s.ledger.Query(filter)
+	if err != nil {
+		logger.Get().Error().Err(err).Msg("Failed to query usage ledger")
+		http.Error(w, "Failed to query usage ledger", http.StatusInternalServerError)
+		return nil, false
+	}
+	return records, true
+}
+
+// usageHandler handles GET /admin/usage: token and request totals grouped by day, model or key
+func (s *Server) usageHandler(w http.ResponseWriter, r *http.Request) {
+	groupBy, err := usage.ParseGroupBy(r.URL.Query().Get("group_by"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	records, ok := s.queryUsage(w, r)
+	if !ok {
+		return
+	}
+	summaries, total := usage.Summarize(records, groupBy)
+
+	if r.URL.Query().Get("format") == "csv" {
+		rows := [][]string{{string(groupBy), "name", "requests", "errors", "prompt_tokens", "output_tokens", "thoughts_tokens", "cached_tokens", "total_tokens", "avg_latency_ms"}}
+		for _, sm := range append(summaries, total) {
+			rows = append(rows, []string{
+				sm.Group, sm.Name, itoa(sm.Requests), itoa(sm.Errors),
+				itoa(sm.PromptTokens), itoa(sm.OutputTokens), itoa(sm.ThoughtsTokens), itoa(sm.CachedTokens), itoa(sm.TotalTokens),
+				itoa(sm.AvgLatencyMs),
+			})
+		}
+		writeCSV(w, "usage_by_"+string(groupBy)+".csv", rows)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"group_by": groupBy,
+		"groups":   summaries,
+		"total":    total,
+	})
+}
+
+// usageRecordsHandler handles GET /admin/usage/records: the raw ledger records, for export
+func (s *Server) usageRecordsHandler(w http.ResponseWriter, r *http.Request) {
+	records, ok := s.queryUsage(w, r)
+	if !ok {
+		return
+	}
+
+	if r.URL.Query().Get("format") == "csv" {
+		rows := [][]string{{"time", "key_id", "key_name", "route", "requested_model", "model", "stream", "status", "latency_ms", "prompt_tokens", "output_tokens", "thoughts_tokens", "cached_tokens", "total_tokens"}}
+		for _, rec := range records {
+			rows = append(rows, []string{
+				rec.Time.Format(time.RFC3339Nano), rec.KeyID, rec.KeyName, rec.Route, rec.RequestedModel, rec.Model,
+				strconv.FormatBool(rec.Stream), strconv.Itoa(rec.Status), itoa(rec.LatencyMs),
+				itoa(rec.PromptTokens), itoa(rec.OutputTokens), itoa(rec.ThoughtsTokens), itoa(rec.CachedTokens), itoa(rec.TotalTokens),
+			})
+		}
+		writeCSV(w, "usage_records.csv", rows)
+		return
+	}
+
+	if records == nil {
+		records = []usage.Record{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"records": records})
+}
+
+func writeCSV(w http.ResponseWriter, filename string, rows [][]string) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	cw := csv.NewWriter(w)
+	if err := cw.WriteAll(rows); err != nil {
+		logger.Get().Error().Err(err).Msg("Failed to write CSV")
+	}
+}
+
+func itoa(v int64) string {
+	return strconv.FormatInt(v, 10)
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/usage"
+)
+
+func TestUsageMiddlewareRecordsRequests(t *testing.T) {
+	t.Setenv("ADMIN_API_KEY", "admin-secret")
+	ledger := usage.NewMemoryLedger(0)
+	s := &Server{mux: http.NewServeMux(), ledger: ledger}
+	s.setupRoutes()
+
+	handler := s.inferenceMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		noteRequest(r, "gemini-2.5-pro-latest", "gemini-2.5-pro", true)
+		s.recordUsage(r.Context(), "gemini-2.5-pro", tokenUsage{Prompt: 10, Output: 5, Thoughts: 2, Cached: 4, Total: 17})
+		w.WriteHeader(http.StatusAccepted)
+	})
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	handler(httptest.NewRecorder(), req)
+
+	records, _ := ledger.Query(usage.Filter{})
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+	rec := records[0]
+	if rec.KeyID != "admin" || rec.Route != "/v1/chat/completions" || rec.Status != http.StatusAccepted || !rec.Stream {
+		t.Errorf("unexpected record: %+v", rec)
+	}
+	if rec.RequestedModel != "gemini-2.5-pro-latest" || rec.Model != "gemini-2.5-pro" {
+		t.Errorf("unexpected models: %+v", rec)
+	}
+	if rec.PromptTokens != 10 || rec.OutputTokens != 5 || rec.ThoughtsTokens != 2 || rec.CachedTokens != 4 || rec.TotalTokens != 17 {
+		t.Errorf("unexpected tokens: %+v", rec)
+	}
+
+	// Reported by /admin/usage
+	req = httptest.NewRequest(http.MethodGet, "/admin/usage?group_by=model", nil)
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Groups []usage.Summary `json:"groups"`
+		Total  usage.Summary   `json:"total"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(resp.Groups) != 1 || resp.Groups[0].Group != "gemini-2.5-pro" || resp.Total.TotalTokens != 17 {
+		t.Errorf("unexpected summary: %+v", resp)
+	}
+}
+
+func TestUsageHandlerFormats(t *testing.T) {
+	t.Setenv("ADMIN_API_KEY", "admin-secret")
+	ledger := usage.NewMemoryLedger(0)
+	ledger.Append(usage.Record{KeyID: "key_a", Model: "gemini-2.5-pro", Status: 200, TotalTokens: 10})
+	s := &Server{mux: http.NewServeMux(), ledger: ledger}
+	s.setupRoutes()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("/admin/usage/records?format=csv")
+	if !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/csv") {
+		t.Errorf("expected CSV, got %q", rr.Header().Get("Content-Type"))
+	}
+	rows, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if len(rows) != 2 || rows[1][1] != "key_a" {
+		t.Errorf("unexpected rows: %v", rows)
+	}
+
+	rr = get("/admin/usage?group_by=key&format=csv")
+	rows, err = csv.NewReader(rr.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if len(rows) != 3 || rows[0][0] != "key" || rows[2][0] != "total" {
+		t.Errorf("unexpected rows: %v", rows)
+	}
+
+	for _, path := range []string{"/admin/usage?group_by=week", "/admin/usage?from=yesterday", "/admin/usage?format=xml"} {
+		if rr := get(path); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", path, rr.Code)
+		}
+	}
+}
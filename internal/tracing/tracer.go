@@ -0,0 +1,168 @@
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/logger"
+)
+
+// Exporter sends finished spans to a tracing backend.
+type Exporter interface {
+	Export(ctx context.Context, spans []*Span) error
+}
+
+// Config tunes sampling and batching of a Tracer. Zero batching values use the defaults.
+type Config struct {
+	// SampleRatio is the fraction of new traces recorded, from 0 to 1. Traces continued from
+	// an incoming traceparent follow the caller's sampling decision instead.
+	SampleRatio float64
+	// BatchSize is the number of spans that triggers an export (default 512)
+	BatchSize int
+	// MaxQueueSize is the number of spans buffered before new ones are dropped (default 2048)
+	MaxQueueSize int
+	// FlushInterval is the longest a span waits before being exported (default 5s)
+	FlushInterval time.Duration
+}
+
+// Tracer batches finished spans and exports them in the background.
+type Tracer struct {
+	exporter     Exporter
+	bound        uint64
+	batchSize    int
+	maxQueueSize int
+
+	mu      sync.Mutex
+	queue   []*Span
+	dropped int
+
+	flush    chan chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewTracer creates a tracer exporting to exporter and starts its export loop.
+func NewTracer(exporter Exporter, cfg Config) *Tracer {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 512
+	}
+	if cfg.MaxQueueSize <= 0 {
+		cfg.MaxQueueSize = 2048
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 5 * time.Second
+	}
+
+	t := &Tracer{
+		exporter:     exporter,
+		bound:        sampleBound(cfg.SampleRatio),
+		batchSize:    cfg.BatchSize,
+		maxQueueSize: cfg.MaxQueueSize,
+		flush:        make(chan chan struct{}, 1),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	go t.run(cfg.FlushInterval)
+	return t
+}
+
+// sample decides whether a new trace is recorded, from the low 8 bytes of its ID so that
+// the decision is the same in every process seeing the trace.
+func (t *Tracer) sample(id TraceID) bool {
+	if t.bound == 0 {
+		return false
+	}
+	return binary.BigEndian.Uint64(id[8:]) <= t.bound
+}
+
+func (t *Tracer) enqueue(s *Span) {
+	t.mu.Lock()
+	if len(t.queue) >= t.maxQueueSize {
+		t.dropped++
+		t.mu.Unlock()
+		return
+	}
+	t.queue = append(t.queue, s)
+	full := len(t.queue) >= t.batchSize
+	t.mu.Unlock()
+
+	if full {
+		select {
+		case t.flush <- nil:
+		default: // a flush is already pending
+		}
+	}
+}
+
+func (t *Tracer) run(interval time.Duration) {
+	defer close(t.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.export()
+		case ack := <-t.flush:
+			t.export()
+			if ack != nil {
+				close(ack)
+			}
+		case <-t.stop:
+			t.export()
+			return
+		}
+	}
+}
+
+func (t *Tracer) export() {
+	t.mu.Lock()
+	batch, dropped := t.queue, t.dropped
+	t.queue, t.dropped = nil, 0
+	t.mu.Unlock()
+
+	if dropped > 0 {
+		logger.Get().Warn().Int("spans", dropped).Msg("Trace export queue full, spans dropped")
+	}
+	for len(batch) > 0 {
+		n := min(len(batch), t.batchSize)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := t.exporter.Export(ctx, batch[:n]); err != nil {
+			logger.Get().Warn().Err(err).Int("spans", n).Msg("Failed to export spans")
+		}
+		cancel()
+		batch = batch[n:]
+	}
+}
+
+// Flush exports all queued spans and waits until done or ctx expires.
+func (t *Tracer) Flush(ctx context.Context) error {
+	ack := make(chan struct{})
+	select {
+	case t.flush <- ack:
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown exports the queued spans and stops the export loop.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	t.stopOnce.Do(func() { close(t.stop) })
+	select {
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
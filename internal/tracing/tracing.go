@@ -0,0 +1,303 @@
+// Package tracing is a minimal OpenTelemetry tracer: spans with attributes and events,
+// W3C trace context extraction, and batched export to an OTLP/HTTP collector.
+//
+// Like the metrics package it avoids the OpenTelemetry SDK (which does not build for Workers)
+// and only implements what the proxy needs. Until a tracer is installed with SetTracer, Start
+// returns nil spans, whose methods do nothing.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// String returns the trace ID in hex, as shown by tracing backends.
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+// String returns the span ID in hex.
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// SpanContext is the part of a span that is propagated across process boundaries.
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Sampled    bool
+	TraceState string
+	Remote     bool
+}
+
+// IsValid reports whether both IDs are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Kind is the OTLP span kind.
+type Kind int
+
+// The span kinds used by the proxy.
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+// Attr is a span or event attribute.
+type Attr struct {
+	Key   string
+	Value interface{} // string, int64, float64 or bool
+}
+
+// String returns a string attribute.
+func String(key, value string) Attr { return Attr{key, value} }
+
+// Int returns an integer attribute.
+func Int(key string, value int) Attr { return Attr{key, int64(value)} }
+
+// Int64 returns an integer attribute.
+func Int64(key string, value int64) Attr { return Attr{key, value} }
+
+// Float64 returns a floating point attribute.
+func Float64(key string, value float64) Attr { return Attr{key, value} }
+
+// Bool returns a boolean attribute.
+func Bool(key string, value bool) Attr { return Attr{key, value} }
+
+// Event is a timestamped annotation of a span.
+type Event struct {
+	Name  string
+	Time  time.Time
+	Attrs []Attr
+}
+
+// Span is an operation being traced. A nil *Span is valid and records nothing.
+type Span struct {
+	tracer *Tracer
+
+	mu            sync.Mutex
+	sc            SpanContext
+	parent        SpanID
+	name          string
+	kind          Kind
+	start         time.Time
+	end           time.Time
+	attrs         []Attr
+	events        []Event
+	statusError   bool
+	statusMessage string
+}
+
+// SpanContext returns the IDs of the span.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetAttributes adds attributes to the span, replacing those with the same key.
+func (s *Span) SetAttributes(attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range attrs {
+		replaced := false
+		for i := range s.attrs {
+			if s.attrs[i].Key == a.Key {
+				s.attrs[i], replaced = a, true
+				break
+			}
+		}
+		if !replaced {
+			s.attrs = append(s.attrs, a)
+		}
+	}
+}
+
+// AddEvent records an event at the current time.
+func (s *Span) AddEvent(name string, attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, Event{Name: name, Time: time.Now(), Attrs: attrs})
+}
+
+// RecordError marks the span as failed and records err as an exception event.
+// A nil error is ignored.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.AddEvent("exception", String("exception.message", err.Error()))
+	s.SetError(err.Error())
+}
+
+// SetError marks the span as failed with the given status message.
+func (s *Span) SetError(message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statusError, s.statusMessage = true, message
+}
+
+// End finishes the span and queues it for export. Calls after the first are ignored.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if !s.end.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	s.end = time.Now()
+	s.mu.Unlock()
+	s.tracer.enqueue(s)
+}
+
+type spanContextKey struct{}
+
+type remoteContextKey struct{}
+
+// SpanFromContext returns the current span of ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanContextKey{}).(*Span)
+	return s
+}
+
+// parentOf returns the span context new spans of ctx are children of.
+func parentOf(ctx context.Context) (SpanContext, bool) {
+	if s := SpanFromContext(ctx); s != nil {
+		return s.sc, true
+	}
+	if sc, ok := ctx.Value(remoteContextKey{}).(SpanContext); ok {
+		return sc, true
+	}
+	return SpanContext{}, false
+}
+
+var global atomic.Pointer[Tracer]
+
+// SetTracer installs the tracer used by Start; nil disables tracing.
+func SetTracer(t *Tracer) {
+	global.Store(t)
+}
+
+// Enabled reports whether a tracer is installed.
+func Enabled() bool {
+	return global.Load() != nil
+}
+
+// Start begins a span as a child of the current span of ctx (or of the remote parent extracted
+// from an incoming request) and returns a context holding it. It returns a nil span if tracing
+// is disabled or the trace is not sampled.
+func Start(ctx context.Context, name string, kind Kind, attrs ...Attr) (context.Context, *Span) {
+	t := global.Load()
+	if t == nil {
+		return ctx, nil
+	}
+
+	sc := SpanContext{SpanID: newSpanID()}
+	var parentID SpanID
+	if parent, ok := parentOf(ctx); ok {
+		if !parent.Sampled {
+			return ctx, nil
+		}
+		sc.TraceID, sc.TraceState, parentID = parent.TraceID, parent.TraceState, parent.SpanID
+	} else {
+		sc.TraceID = newTraceID()
+		if !t.sample(sc.TraceID) {
+			return ctx, nil
+		}
+	}
+	sc.Sampled = true
+
+	s := &Span{tracer: t, sc: sc, parent: parentID, name: name, kind: kind, start: time.Now()}
+	s.SetAttributes(attrs...)
+	return context.WithValue(ctx, spanContextKey{}, s), s
+}
+
+// Extract reads the W3C traceparent and tracestate headers and returns a context whose spans
+// continue the caller's trace. Invalid or missing headers leave ctx unchanged.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	sc, err := ParseTraceparent(header.Get("traceparent"))
+	if err != nil {
+		return ctx
+	}
+	sc.TraceState = header.Get("tracestate")
+	return context.WithValue(ctx, remoteContextKey{}, sc)
+}
+
+// ParseTraceparent parses a W3C traceparent header (version-traceid-spanid-flags).
+func ParseTraceparent(v string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, errors.New("malformed traceparent")
+	}
+	// Version ff is invalid; version 00 has exactly four fields
+	if parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, errors.New("unsupported traceparent version")
+	}
+
+	var sc SpanContext
+	var flags [1]byte
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, errors.New("malformed trace ID")
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, errors.New("malformed span ID")
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return SpanContext{}, errors.New("malformed trace flags")
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, errors.New("all-zero trace or span ID")
+	}
+	sc.Sampled = flags[0]&1 == 1
+	sc.Remote = true
+	return sc, nil
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	rand.Read(id[:])
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	rand.Read(id[:])
+	return id
+}
+
+// sampleBound converts a ratio into the bound the trace ID is compared to, like the
+// TraceIDRatioBased sampler of OpenTelemetry.
+func sampleBound(ratio float64) uint64 {
+	switch {
+	case ratio >= 1:
+		return math.MaxUint64
+	case ratio <= 0:
+		return 0
+	default:
+		return uint64(ratio * math.MaxUint64)
+	}
+}
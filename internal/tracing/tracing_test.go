@@ -0,0 +1,195 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingExporter struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+func (e *recordingExporter) Export(ctx context.Context, spans []*Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *recordingExporter) names() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var names []string
+	for _, s := range e.spans {
+		names = append(names, s.name)
+	}
+	return names
+}
+
+func installTracer(t *testing.T, exporter Exporter, ratio float64) *Tracer {
+	tracer := NewTracer(exporter, Config{SampleRatio: ratio, FlushInterval: time.Hour})
+	SetTracer(tracer)
+	t.Cleanup(func() {
+		SetTracer(nil)
+		tracer.Shutdown(context.Background())
+	})
+	return tracer
+}
+
+func TestParseTraceparent(t *testing.T) {
+	sc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.NoError(t, err)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+	assert.True(t, sc.Sampled)
+	assert.True(t, sc.Remote)
+
+	sc, err = ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	require.NoError(t, err)
+	assert.False(t, sc.Sampled)
+
+	// Future versions may append fields
+	_, err = ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra")
+	assert.NoError(t, err)
+
+	for _, v := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+	} {
+		_, err := ParseTraceparent(v)
+		assert.Error(t, err, v)
+	}
+}
+
+func TestStartDisabled(t *testing.T) {
+	SetTracer(nil)
+	ctx, span := Start(context.Background(), "op", KindInternal)
+	assert.Nil(t, span)
+	assert.Nil(t, SpanFromContext(ctx))
+
+	// Methods of a nil span are no-ops
+	span.SetAttributes(String("k", "v"))
+	span.AddEvent("event")
+	span.RecordError(errors.New("boom"))
+	span.End()
+}
+
+func TestStartContinuesRemoteTrace(t *testing.T) {
+	exporter := &recordingExporter{}
+	tracer := installTracer(t, exporter, 1)
+
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	header.Set("tracestate", "vendor=value")
+	ctx, server := Start(Extract(context.Background(), header), "server", KindServer)
+	require.NotNil(t, server)
+	_, child := Start(ctx, "child", KindInternal)
+	require.NotNil(t, child)
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", server.parent.String())
+	assert.Equal(t, "vendor=value", server.SpanContext().TraceState)
+	assert.Equal(t, server.SpanContext().TraceID, child.SpanContext().TraceID)
+	assert.Equal(t, server.SpanContext().SpanID, child.parent)
+
+	child.End()
+	server.End()
+	server.End() // ignored
+	require.NoError(t, tracer.Flush(context.Background()))
+	assert.Equal(t, []string{"child", "server"}, exporter.names())
+}
+
+func TestStartSampling(t *testing.T) {
+	installTracer(t, &recordingExporter{}, 0)
+
+	_, span := Start(context.Background(), "root", KindServer)
+	assert.Nil(t, span, "ratio 0 records no new traces")
+
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, span = Start(Extract(context.Background(), header), "sampled parent", KindServer)
+	assert.NotNil(t, span, "the sampling decision of the caller wins")
+
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	_, span = Start(Extract(context.Background(), header), "unsampled parent", KindServer)
+	assert.Nil(t, span)
+}
+
+func TestSetAttributesReplacesKeys(t *testing.T) {
+	installTracer(t, &recordingExporter{}, 1)
+
+	_, span := Start(context.Background(), "op", KindInternal, String("a", "1"))
+	span.SetAttributes(String("a", "2"), Int("b", 3))
+	assert.Equal(t, []Attr{{"a", "2"}, {"b", int64(3)}}, span.attrs)
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var body map[string]interface{}
+	var gotHeader string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Get("X-Token")
+		b, _ := io.ReadAll(r.Body)
+		json.Unmarshal(b, &body)
+	}))
+	defer srv.Close()
+
+	exporter := NewOTLPExporter(srv.URL+"/v1/traces", "proxy-test", map[string]string{"X-Token": "secret"})
+	tracer := installTracer(t, exporter, 1)
+
+	ctx, parent := Start(context.Background(), "parent", KindServer, Int("http.response.status_code", 200))
+	_, child := Start(ctx, "child", KindClient, Bool("retried", true))
+	child.AddEvent("retry", String("reason", "token_refreshed"))
+	child.RecordError(errors.New("boom"))
+	child.End()
+	parent.End()
+	require.NoError(t, tracer.Flush(context.Background()))
+
+	assert.Equal(t, "secret", gotHeader)
+	resourceSpans := body["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	resource := resourceSpans["resource"].(map[string]interface{})
+	assert.Equal(t, []interface{}{map[string]interface{}{"key": "service.name", "value": map[string]interface{}{"stringValue": "proxy-test"}}}, resource["attributes"])
+
+	spans := resourceSpans["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})
+	require.Len(t, spans, 2)
+	c, p := spans[0].(map[string]interface{}), spans[1].(map[string]interface{})
+	assert.Equal(t, "child", c["name"])
+	assert.Equal(t, float64(KindClient), c["kind"])
+	assert.Equal(t, p["spanId"], c["parentSpanId"])
+	assert.Equal(t, p["traceId"], c["traceId"])
+	assert.Len(t, c["traceId"], 32)
+	assert.Equal(t, map[string]interface{}{"code": float64(2), "message": "boom"}, c["status"])
+	assert.Len(t, c["events"], 2)
+	assert.Equal(t, []interface{}{map[string]interface{}{"key": "retried", "value": map[string]interface{}{"boolValue": true}}}, c["attributes"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"key": "http.response.status_code", "value": map[string]interface{}{"intValue": "200"}}}, p["attributes"])
+	assert.NotContains(t, p, "parentSpanId")
+}
+
+func TestTracerDropsWhenQueueFull(t *testing.T) {
+	exporter := &recordingExporter{}
+	tracer := NewTracer(exporter, Config{SampleRatio: 1, BatchSize: 100, MaxQueueSize: 2, FlushInterval: time.Hour})
+	SetTracer(tracer)
+	defer SetTracer(nil)
+
+	for i := 0; i < 3; i++ {
+		_, span := Start(context.Background(), "op", KindInternal)
+		span.End()
+	}
+	require.NoError(t, tracer.Shutdown(context.Background()))
+	assert.Len(t, exporter.names(), 2)
+}
@@ -0,0 +1,269 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/anthropic"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/logger"
+)
+
+// AnthropicToGeminiRequest converts an Anthropic Messages request to a Gemini generateContent request.
+func AnthropicToGeminiRequest(req *anthropic.MessagesRequest, projectID string) (*gemini.GenerateContentRequest, error) {
+	contents, err := convertAnthropicMessagesToGeminiContents(req.Messages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert messages: %w", err)
+	}
+
+	var systemInstruction *gemini.SystemInstruction
+	for _, block := range req.System {
+		if block.Type != "text" || block.Text == "" {
+			continue
+		}
+		if systemInstruction == nil {
+			systemInstruction = &gemini.SystemInstruction{Role: "system"}
+		}
+		systemInstruction.Parts = append(systemInstruction.Parts, gemini.ContentPart{Text: block.Text})
+	}
+
+	internalReq := gemini.GeminiInternalRequest{
+		Contents:          contents,
+		SystemInstruction: systemInstruction,
+		Tools:             convertAnthropicToolsToGeminiTools(req.Tools),
+		ToolConfig:        convertAnthropicToolChoice(req.ToolChoice),
+		GenerationConfig:  convertAnthropicGenerationConfig(req),
+	}
+
+	return &gemini.GenerateContentRequest{
+		Model:   req.Model,
+		Project: projectID,
+		Request: internalReq,
+	}, nil
+}
+
+// convertAnthropicMessagesToGeminiContents maps Anthropic content blocks onto Gemini parts.
+// thinking/redacted_thinking blocks are not replayed as text; their signature is re-attached
+// to the next part of the same message, which is where Gemini originally emitted it.
+func convertAnthropicMessagesToGeminiContents(messages []anthropic.Message) ([]gemini.Content, error) {
+	// Build tool_use_id -> function name map from assistant tool_use blocks
+	toolNameByID := map[string]string{}
+	for _, m := range messages {
+		for _, block := range m.Content {
+			if block.Type == "tool_use" && block.ID != "" && block.Name != "" {
+				toolNameByID[block.ID] = block.Name
+			}
+		}
+	}
+
+	var contents []gemini.Content
+	for _, msg := range messages {
+		role := "user"
+		if msg.Role == "assistant" {
+			role = "model"
+		}
+
+		var parts []gemini.ContentPart
+		pendingSignature := ""
+		appendPart := func(p gemini.ContentPart) {
+			if pendingSignature != "" {
+				p.ThoughtSignature = pendingSignature
+				pendingSignature = ""
+			}
+			parts = append(parts, p)
+		}
+
+		for _, block := range msg.Content {
+			switch block.Type {
+			case "text":
+				if block.Text != "" {
+					appendPart(gemini.ContentPart{Text: block.Text})
+				}
+
+			case "image", "document":
+				part, err := convertAnthropicSource(block.Type, block.Source)
+				if err != nil {
+					return nil, fmt.Errorf("invalid %s block: %w", block.Type, err)
+				}
+				appendPart(part)
+
+			case "thinking":
+				if block.Signature != "" {
+					pendingSignature = block.Signature
+				}
+
+			case "redacted_thinking":
+				if block.Data != "" {
+					pendingSignature = block.Data
+				}
+
+			case "tool_use":
+				args, _ := block.Input.(map[string]interface{})
+				if args == nil {
+					args = map[string]interface{}{}
+				}
+				appendPart(gemini.ContentPart{
+					FunctionCall: &gemini.FunctionCall{
+						Name: block.Name,
+						Args: args,
+					},
+				})
+
+			case "tool_result":
+				name, ok := toolNameByID[block.ToolUseID]
+				if !ok || name == "" {
+					return nil, fmt.Errorf("tool_result references unknown tool_use_id %q", block.ToolUseID)
+				}
+				output := anthropicBlocksText(block.Content)
+
+				preview := output
+				if len(preview) > 300 {
+					preview = preview[:300] + "..."
+				}
+				logger.Get().Info().
+					Str("function", name).
+					Str("tool_use_id", block.ToolUseID).
+					Bool("is_error", block.IsError).
+					Int("response_len", len(output)).
+					Str("response_preview", preview).
+					Msg("Forwarding tool result to Gemini")
+
+				key := "output"
+				if block.IsError {
+					key = "error"
+				}
+				appendPart(gemini.ContentPart{
+					FunctionResponse: &gemini.FunctionResponse{
+						Name:     name,
+						Response: map[string]interface{}{key: output},
+					},
+				})
+
+			default:
+				logger.Get().Warn().
+					Str("type", block.Type).
+					Str("role", msg.Role).
+					Msg("Skipping unsupported Anthropic content block")
+			}
+		}
+
+		if len(parts) == 0 {
+			continue
+		}
+		contents = append(contents, gemini.Content{Role: role, Parts: parts})
+	}
+
+	return contents, nil
+}
+
+// anthropicBlocksText concatenates the text blocks of a tool_result content list.
+func anthropicBlocksText(blocks anthropic.Content) string {
+	var b strings.Builder
+	for _, block := range blocks {
+		if block.Type != "text" || block.Text == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(block.Text)
+	}
+	return b.String()
+}
+
+func convertAnthropicToolsToGeminiTools(tools []anthropic.Tool) []gemini.Tool {
+	var fns []gemini.FunctionDeclaration
+	for _, t := range tools {
+		// Server tools (web_search, bash, text_editor, ...) have no Gemini equivalent here
+		if t.Type != "" && t.Type != "custom" {
+			logger.Get().Warn().Str("type", t.Type).Str("name", t.Name).Msg("Skipping unsupported Anthropic server tool")
+			continue
+		}
+
+		var schema *gemini.GeminiParameterSchema
+		if m, ok := t.InputSchema.(map[string]interface{}); ok {
+			schema = convertToGeminiSchema(m)
+		}
+
+		fns = append(fns, gemini.FunctionDeclaration{
+			Name:        t.Name,
+			Description: t.Description,
+			Parameters:  schema,
+		})
+	}
+
+	if len(fns) == 0 {
+		return nil
+	}
+	return []gemini.Tool{{FunctionDeclarations: fns}}
+}
+
+// convertAnthropicSource converts the source of an image or document block into a Gemini part.
+func convertAnthropicSource(blockType string, src *anthropic.ImageSource) (gemini.ContentPart, error) {
+	if src == nil {
+		return gemini.ContentPart{}, fmt.Errorf("missing source")
+	}
+	switch src.Type {
+	case "base64":
+		return inlineBase64Part(src.Data, src.MediaType)
+	case "url":
+		fallbackMime := "application/pdf"
+		if blockType == "image" {
+			fallbackMime = "image/jpeg"
+		}
+		return mediaURLToPart(src.URL, fallbackMime)
+	case "text":
+		return gemini.ContentPart{Text: src.Data}, nil
+	default:
+		return gemini.ContentPart{}, fmt.Errorf("unsupported source type %q", src.Type)
+	}
+}
+
+// convertAnthropicToolChoice maps tool_choice onto Gemini's functionCallingConfig.
+func convertAnthropicToolChoice(choice *anthropic.ToolChoice) *gemini.ToolConfig {
+	if choice == nil {
+		return nil
+	}
+
+	cfg := &gemini.FunctionCallingConfig{}
+	switch choice.Type {
+	case "auto":
+		cfg.Mode = "AUTO"
+	case "any":
+		cfg.Mode = "ANY"
+	case "tool":
+		cfg.Mode = "ANY"
+		if choice.Name != "" {
+			cfg.AllowedFunctionNames = []string{choice.Name}
+		}
+	case "none":
+		cfg.Mode = "NONE"
+	default:
+		return nil
+	}
+	return &gemini.ToolConfig{FunctionCallingConfig: cfg}
+}
+
+func convertAnthropicGenerationConfig(req *anthropic.MessagesRequest) *gemini.GeminiGenerationConfig {
+	cfg := &gemini.GeminiGenerationConfig{
+		MaxOutputTokens: req.MaxTokens,
+		StopSequences:   req.StopSequences,
+	}
+	if req.Temperature != nil {
+		cfg.Temperature = req.Temperature
+	}
+	if req.TopP != nil {
+		cfg.TopP = req.TopP
+	}
+	if req.TopK != nil {
+		cfg.TopK = *req.TopK
+	}
+	if req.Thinking != nil && req.Thinking.Type == "enabled" {
+		cfg.ThinkingConfig = &gemini.ThinkingConfig{IncludeThoughts: true}
+		if req.Thinking.BudgetTokens > 0 {
+			budget := req.Thinking.BudgetTokens
+			cfg.ThinkingConfig.ThinkingBudget = &budget
+		}
+	}
+	return cfg
+}
@@ -0,0 +1,123 @@
+package transform
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/anthropic"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnthropicToGeminiRequest_ToolLoop(t *testing.T) {
+	payload := `{
+		"model": "claude-sonnet-4",
+		"max_tokens": 1024,
+		"system": [{"type": "text", "text": "You are helpful."}],
+		"thinking": {"type": "enabled", "budget_tokens": 2048},
+		"tool_choice": {"type": "tool", "name": "read"},
+		"tools": [{
+			"name": "read",
+			"description": "Read a file",
+			"input_schema": {"type": "object", "properties": {"path": {"type": "string"}}, "required": ["path"]}
+		}],
+		"messages": [
+			{"role": "user", "content": "Read poem.md"},
+			{"role": "assistant", "content": [
+				{"type": "thinking", "thinking": "I should read it.", "signature": "sig-1"},
+				{"type": "tool_use", "id": "toolu_1", "name": "read", "input": {"path": "poem.md"}}
+			]},
+			{"role": "user", "content": [
+				{"type": "tool_result", "tool_use_id": "toolu_1", "content": [{"type": "text", "text": "Once upon a midnight dreary"}]}
+			]}
+		]
+	}`
+
+	var req anthropic.MessagesRequest
+	require.NoError(t, json.Unmarshal([]byte(payload), &req))
+
+	got, err := AnthropicToGeminiRequest(&req, "test-project")
+	require.NoError(t, err)
+
+	assert.Equal(t, "test-project", got.Project)
+	require.NotNil(t, got.Request.SystemInstruction)
+	assert.Equal(t, "You are helpful.", got.Request.SystemInstruction.Parts[0].Text)
+
+	contents := got.Request.Contents
+	require.Len(t, contents, 3)
+	assert.Equal(t, "user", contents[0].Role)
+	assert.Equal(t, "Read poem.md", contents[0].Parts[0].Text)
+
+	// Thinking text is not replayed, but its signature moves onto the function call
+	require.Len(t, contents[1].Parts, 1)
+	call := contents[1].Parts[0]
+	assert.Equal(t, "model", contents[1].Role)
+	require.NotNil(t, call.FunctionCall)
+	assert.Equal(t, "read", call.FunctionCall.Name)
+	assert.Equal(t, "poem.md", call.FunctionCall.Args["path"])
+	assert.Equal(t, "sig-1", call.ThoughtSignature)
+
+	resp := contents[2].Parts[0].FunctionResponse
+	require.NotNil(t, resp)
+	assert.Equal(t, "read", resp.Name, "function name must be resolved from tool_use_id")
+	assert.Equal(t, "Once upon a midnight dreary", resp.Response["output"])
+
+	require.Len(t, got.Request.Tools, 1)
+	assert.Equal(t, "OBJECT", got.Request.Tools[0].FunctionDeclarations[0].Parameters.Type)
+
+	require.NotNil(t, got.Request.ToolConfig)
+	assert.Equal(t, &gemini.FunctionCallingConfig{Mode: "ANY", AllowedFunctionNames: []string{"read"}}, got.Request.ToolConfig.FunctionCallingConfig)
+
+	cfg := got.Request.GenerationConfig
+	require.NotNil(t, cfg)
+	assert.Equal(t, 1024, cfg.MaxOutputTokens)
+	require.NotNil(t, cfg.ThinkingConfig)
+	assert.True(t, cfg.ThinkingConfig.IncludeThoughts)
+	require.NotNil(t, cfg.ThinkingConfig.ThinkingBudget)
+	assert.Equal(t, 2048, *cfg.ThinkingConfig.ThinkingBudget)
+}
+
+func TestAnthropicToGeminiRequest_UnknownToolUseID(t *testing.T) {
+	req := &anthropic.MessagesRequest{
+		Model: "gemini-2.5-pro",
+		Messages: []anthropic.Message{
+			{Role: "user", Content: anthropic.Content{{Type: "tool_result", ToolUseID: "toolu_missing"}}},
+		},
+	}
+	_, err := AnthropicToGeminiRequest(req, "p")
+	assert.Error(t, err)
+}
+
+func TestToAnthropicMessagesResponse(t *testing.T) {
+	raw := `{"response": {
+		"candidates": [{
+			"content": {"role": "model", "parts": [
+				{"text": "Thinking about it", "thought": true},
+				{"text": "Here you go.", "thoughtSignature": "sig-2"},
+				{"functionCall": {"name": "read", "args": {"path": "a.txt"}}}
+			]},
+			"finishReason": "STOP"
+		}],
+		"usageMetadata": {"promptTokenCount": 12, "candidatesTokenCount": 5, "thoughtsTokenCount": 3}
+	}}`
+	var resp gemini.GenerateContentResponse
+	require.NoError(t, json.Unmarshal([]byte(raw), &resp))
+
+	got, err := ToAnthropicMessagesResponse(&resp, "claude-sonnet-4")
+	require.NoError(t, err)
+
+	require.Len(t, got.Content, 3)
+	assert.Equal(t, "thinking", got.Content[0].Type)
+	assert.Equal(t, "Thinking about it", got.Content[0].Thinking)
+	assert.Equal(t, "sig-2", got.Content[0].Signature)
+	assert.Equal(t, "text", got.Content[1].Type)
+	assert.Equal(t, "Here you go.", got.Content[1].Text)
+	assert.Equal(t, "tool_use", got.Content[2].Type)
+	assert.Equal(t, "read", got.Content[2].Name)
+
+	assert.Equal(t, anthropic.StopReasonToolUse, got.StopReason)
+	assert.Equal(t, "claude-sonnet-4", got.Model)
+	assert.Equal(t, 12, got.Usage.InputTokens)
+	assert.Equal(t, 8, got.Usage.OutputTokens)
+}
@@ -0,0 +1,121 @@
+package transform
+
+import (
+	"fmt"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/anthropic"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
+	"github.com/google/uuid"
+)
+
+// ToAnthropicMessagesResponse converts a Gemini generateContent response into an Anthropic Messages response.
+// Only the first candidate is used since the Messages API has no notion of multiple choices.
+func ToAnthropicMessagesResponse(geminiResp *gemini.GenerateContentResponse, model string) (*anthropic.MessagesResponse, error) {
+	if geminiResp == nil || geminiResp.Response == nil {
+		return nil, fmt.Errorf("empty response")
+	}
+
+	content := []anthropic.ContentBlock{}
+	finishReason := ""
+	sawToolUse := false
+
+	if cands, ok := geminiResp.Response["candidates"].([]interface{}); ok && len(cands) > 0 {
+		cand, ok := cands[0].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("failed to cast candidate to map")
+		}
+		finishReason, _ = cand["finishReason"].(string)
+
+		var parts []interface{}
+		if c, ok := cand["content"].(map[string]interface{}); ok {
+			parts, _ = c["parts"].([]interface{})
+		}
+
+		for _, p := range parts {
+			part, ok := p.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			isThought, _ := part["thought"].(bool)
+			sig, _ := part["thoughtSignature"].(string)
+			last := len(content) - 1
+
+			if isThought {
+				txt, _ := part["text"].(string)
+				if last >= 0 && content[last].Type == "thinking" && content[last].Signature == "" {
+					content[last].Thinking += txt
+					content[last].Signature = sig
+				} else {
+					content = append(content, anthropic.ContentBlock{Type: "thinking", Thinking: txt, Signature: sig})
+				}
+				continue
+			}
+
+			// A signature on a regular part closes the preceding thinking block;
+			// without one, keep it as an opaque redacted_thinking block.
+			if sig != "" {
+				if last >= 0 && content[last].Type == "thinking" && content[last].Signature == "" {
+					content[last].Signature = sig
+				} else {
+					content = append(content, anthropic.ContentBlock{Type: "redacted_thinking", Data: sig})
+				}
+			}
+
+			if txt, ok := part["text"].(string); ok && txt != "" {
+				last = len(content) - 1
+				if last >= 0 && content[last].Type == "text" {
+					content[last].Text += txt
+				} else {
+					content = append(content, anthropic.ContentBlock{Type: "text", Text: txt})
+				}
+			}
+
+			if fc, ok := part["functionCall"].(map[string]interface{}); ok {
+				name, _ := fc["name"].(string)
+				args, _ := fc["args"].(map[string]interface{})
+				if args == nil {
+					args = map[string]interface{}{}
+				}
+				content = append(content, anthropic.ContentBlock{
+					Type:  "tool_use",
+					ID:    fmt.Sprintf("toolu_%s", uuid.New().String()),
+					Name:  name,
+					Input: args,
+				})
+				sawToolUse = true
+			}
+		}
+	}
+
+	stopReason := anthropic.StopReasonFromGemini(finishReason)
+	if sawToolUse {
+		stopReason = anthropic.StopReasonToolUse
+	}
+
+	var usage anthropic.Usage
+	if um, ok := geminiResp.Response["usageMetadata"].(map[string]interface{}); ok {
+		if v, ok := um["promptTokenCount"].(float64); ok {
+			usage.InputTokens = int(v)
+		}
+		if v, ok := um["candidatesTokenCount"].(float64); ok {
+			usage.OutputTokens = int(v)
+		}
+		if v, ok := um["thoughtsTokenCount"].(float64); ok {
+			usage.OutputTokens += int(v)
+		}
+		if v, ok := um["cachedContentTokenCount"].(float64); ok {
+			usage.CacheReadInputTokens = int(v)
+		}
+	}
+
+	return &anthropic.MessagesResponse{
+		ID:         fmt.Sprintf("msg_%s", uuid.New().String()),
+		Type:       anthropic.MessageObject,
+		Role:       "assistant",
+		Model:      model,
+		Content:    content,
+		StopReason: stopReason,
+		Usage:      usage,
+	}, nil
+}
@@ -1,7 +1,9 @@
 package transform
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
@@ -9,44 +11,115 @@ import (
 	"github.com/google/uuid"
 )
 
+// ToOpenAIChatCompletionResponse converts a Gemini generateContent response into an OpenAI chat completion.
+// Text parts become the message content, thought parts become reasoning_content and
+// functionCall parts become tool_calls. finishReason and promptFeedback.blockReason are
+// mapped onto OpenAI finish reasons (see openai.FinishReasonFromGemini).
 func ToOpenAIChatCompletionResponse(geminiResp *gemini.GenerateContentResponse, model string) (*openai.ChatCompletionResponse, error) {
+	if geminiResp == nil || geminiResp.Response == nil {
+		return nil, fmt.Errorf("empty response")
+	}
+
 	choices := []openai.Choice{}
-	for i, candidate := range geminiResp.Response["candidates"].([]interface{}) {
+	candidates, _ := geminiResp.Response["candidates"].([]interface{})
+	for i, candidate := range candidates {
 		candidateMap, ok := candidate.(map[string]interface{})
 		if !ok {
 			return nil, fmt.Errorf("failed to cast candidate to map")
 		}
 
-		content, ok := candidateMap["content"].(map[string]interface{})
-		if !ok {
-			return nil, fmt.Errorf("failed to cast content to map")
+		index := i
+		if v, ok := candidateMap["index"].(float64); ok {
+			index = int(v)
 		}
 
-		parts, ok := content["parts"].([]interface{})
-		if !ok {
-			return nil, fmt.Errorf("failed to cast parts to slice")
+		// parts may be under content.parts or parts
+		var parts []interface{}
+		if content, ok := candidateMap["content"].(map[string]interface{}); ok {
+			parts, _ = content["parts"].([]interface{})
+		}
+		if len(parts) == 0 {
+			parts, _ = candidateMap["parts"].([]interface{})
 		}
 
-		var contentText string
+		var text, reasoning strings.Builder
+		var toolCalls []openai.OpenAIToolCall
 		for _, part := range parts {
 			partMap, ok := part.(map[string]interface{})
 			if !ok {
 				return nil, fmt.Errorf("failed to cast part to map")
 			}
-			text, ok := partMap["text"].(string)
-			if ok {
-				contentText += text
+
+			sig, _ := partMap["thoughtSignature"].(string)
+			if fc, ok := partMap["functionCall"].(map[string]interface{}); ok {
+				name, _ := fc["name"].(string)
+				args, _ := fc["args"].(map[string]interface{})
+				if args == nil {
+					args = map[string]interface{}{}
+				}
+				argsJSON, err := json.Marshal(args)
+				if err != nil {
+					return nil, fmt.Errorf("failed to marshal arguments of %s: %w", name, err)
+				}
+				toolCalls = append(toolCalls, openai.OpenAIToolCall{
+					Index: len(toolCalls),
+					ID:    fmt.Sprintf("call_%s", uuid.New().String()),
+					Type:  "function",
+					Function: openai.OpenAIFunctionCall{
+						Name:      name,
+						Arguments: string(argsJSON),
+					},
+					ThoughtSignature: sig,
+				})
+				continue
+			}
+
+			txt, ok := partMap["text"].(string)
+			if !ok {
+				continue
+			}
+			if isThought, _ := partMap["thought"].(bool); isThought {
+				reasoning.WriteString(txt)
+			} else {
+				text.WriteString(txt)
 			}
 		}
 
-		choices = append(choices, openai.Choice{
-			Index: i,
-			Message: openai.Message{
-				Role:    "assistant",
-				Content: contentText,
-			},
-			FinishReason: "stop", // TODO: Map finish reason
-		})
+		message := openai.Message{
+			Role:             "assistant",
+			ReasoningContent: reasoning.String(),
+			ToolCalls:        toolCalls,
+		}
+		// OpenAI sends null content for pure tool call turns
+		if text.Len() > 0 || len(toolCalls) == 0 {
+			message.Content = text.String()
+		}
+
+		finishReason, _ := candidateMap["finishReason"].(string)
+		choice := openai.Choice{
+			Index:        index,
+			Message:      message,
+			FinishReason: openai.FinishReasonFromGemini(finishReason, len(toolCalls) > 0),
+		}
+		if lr, ok := candidateMap["logprobsResult"].(map[string]interface{}); ok {
+			choice.Logprobs = openai.LogprobsFromGemini(lr)
+		}
+		choices = append(choices, choice)
+	}
+
+	// A blocked prompt has no candidates; report it as a refusal instead of an empty choice list
+	if len(choices) == 0 {
+		if pf, ok := geminiResp.Response["promptFeedback"].(map[string]interface{}); ok {
+			if blockReason, ok := pf["blockReason"].(string); ok && blockReason != "" {
+				choices = append(choices, openai.Choice{
+					Message: openai.Message{
+						Role:    "assistant",
+						Refusal: openai.PromptBlockedRefusal(blockReason),
+					},
+					FinishReason: openai.FinishReasonContentFilter,
+				})
+			}
+		}
 	}
 
 	var promptTokens, completionTokens, totalTokens int
@@ -0,0 +1,190 @@
+package transform
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToOpenAIChatCompletionResponse_ToolCalls(t *testing.T) {
+	geminiResp := &gemini.GenerateContentResponse{Response: map[string]interface{}{
+		"candidates": []interface{}{
+			map[string]interface{}{
+				"finishReason": "STOP",
+				"content": map[string]interface{}{
+					"role": "model",
+					"parts": []interface{}{
+						map[string]interface{}{"text": "Need both files.", "thought": true},
+						map[string]interface{}{"functionCall": map[string]interface{}{"name": "read", "args": map[string]interface{}{"path": "a.md"}}, "thoughtSignature": "sig-1"},
+						map[string]interface{}{"functionCall": map[string]interface{}{"name": "read", "args": map[string]interface{}{"path": "b.md"}}},
+					},
+				},
+			},
+		},
+		"usageMetadata": map[string]interface{}{
+			"promptTokenCount":     float64(10),
+			"candidatesTokenCount": float64(4),
+			"totalTokenCount":      float64(20),
+		},
+	}}
+
+	resp, err := ToOpenAIChatCompletionResponse(geminiResp, "gemini-2.5-pro")
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(resp.ID, "chatcmpl-"))
+	assert.Equal(t, "chat.completion", resp.Object)
+	require.Len(t, resp.Choices, 1)
+
+	choice := resp.Choices[0]
+	assert.Equal(t, "tool_calls", choice.FinishReason)
+	assert.Nil(t, choice.Message.Content)
+	assert.Equal(t, "Need both files.", choice.Message.ReasoningContent)
+	require.Len(t, choice.Message.ToolCalls, 2)
+	for i, tc := range choice.Message.ToolCalls {
+		assert.Equal(t, i, tc.Index)
+		assert.Equal(t, "function", tc.Type)
+		assert.Equal(t, "read", tc.Function.Name)
+		assert.True(t, strings.HasPrefix(tc.ID, "call_"))
+	}
+	assert.NotEqual(t, choice.Message.ToolCalls[0].ID, choice.Message.ToolCalls[1].ID)
+	assert.Equal(t, "sig-1", choice.Message.ToolCalls[0].ThoughtSignature)
+	assert.Empty(t, choice.Message.ToolCalls[1].ThoughtSignature)
+	assert.JSONEq(t, `{"path":"b.md"}`, choice.Message.ToolCalls[1].Function.Arguments)
+
+	assert.Equal(t, 10, resp.Usage.PromptTokens)
+	assert.Equal(t, 4, resp.Usage.CompletionTokens)
+	assert.Equal(t, 20, resp.Usage.TotalTokens)
+
+	// content must be serialized as null, not omitted or ""
+	out, err := json.Marshal(resp)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), `"content":null`)
+	assert.NotContains(t, string(out), "sig-1")
+}
+
+func TestToOpenAIChatCompletionResponse_Text(t *testing.T) {
+	geminiResp := &gemini.GenerateContentResponse{Response: map[string]interface{}{
+		"candidates": []interface{}{
+			map[string]interface{}{
+				"content": map[string]interface{}{
+					"parts": []interface{}{
+						map[string]interface{}{"text": "Hello, "},
+						map[string]interface{}{"text": "world"},
+					},
+				},
+			},
+		},
+	}}
+
+	first, err := ToOpenAIChatCompletionResponse(geminiResp, "gemini-2.5-flash")
+	require.NoError(t, err)
+	second, err := ToOpenAIChatCompletionResponse(geminiResp, "gemini-2.5-flash")
+	require.NoError(t, err)
+
+	assert.NotEqual(t, first.ID, second.ID)
+	require.Len(t, first.Choices, 1)
+	assert.Equal(t, "stop", first.Choices[0].FinishReason)
+	assert.Equal(t, "Hello, world", first.Choices[0].Message.Content)
+	assert.Empty(t, first.Choices[0].Message.ToolCalls)
+	assert.Empty(t, first.Choices[0].Message.ReasoningContent)
+}
+
+func TestToOpenAIChatCompletionResponse_Empty(t *testing.T) {
+	_, err := ToOpenAIChatCompletionResponse(&gemini.GenerateContentResponse{}, "gemini-2.5-pro")
+	assert.Error(t, err)
+}
+
+func TestToOpenAIChatCompletionResponse_FinishReason(t *testing.T) {
+	tests := []struct {
+		geminiReason string
+		withCall     bool
+		expected     string
+	}{
+		{"STOP", false, "stop"},
+		{"STOP", true, "tool_calls"},
+		{"MAX_TOKENS", false, "length"},
+		{"MAX_TOKENS", true, "length"},
+		{"RECITATION", false, "content_filter"},
+		{"BLOCKLIST", false, "content_filter"},
+		{"MALFORMED_FUNCTION_CALL", false, "error"},
+		{"", false, "stop"},
+	}
+
+	for _, tt := range tests {
+		parts := []interface{}{map[string]interface{}{"text": "partial"}}
+		if tt.withCall {
+			parts = append(parts, map[string]interface{}{"functionCall": map[string]interface{}{"name": "f"}})
+		}
+		geminiResp := &gemini.GenerateContentResponse{Response: map[string]interface{}{
+			"candidates": []interface{}{
+				map[string]interface{}{"finishReason": tt.geminiReason, "content": map[string]interface{}{"parts": parts}},
+			},
+		}}
+
+		resp, err := ToOpenAIChatCompletionResponse(geminiResp, "gemini-2.5-pro")
+		require.NoError(t, err)
+		require.Len(t, resp.Choices, 1)
+		assert.Equal(t, tt.expected, resp.Choices[0].FinishReason, "finishReason %q (tool call: %v)", tt.geminiReason, tt.withCall)
+	}
+}
+
+func TestToOpenAIChatCompletionResponse_BlockedPrompt(t *testing.T) {
+	geminiResp := &gemini.GenerateContentResponse{Response: map[string]interface{}{
+		"promptFeedback": map[string]interface{}{"blockReason": "PROHIBITED_CONTENT"},
+		"usageMetadata":  map[string]interface{}{"promptTokenCount": float64(12)},
+	}}
+
+	resp, err := ToOpenAIChatCompletionResponse(geminiResp, "gemini-2.5-pro")
+	require.NoError(t, err)
+	require.Len(t, resp.Choices, 1)
+	assert.Equal(t, "content_filter", resp.Choices[0].FinishReason)
+	assert.Nil(t, resp.Choices[0].Message.Content)
+	assert.Contains(t, resp.Choices[0].Message.Refusal, "PROHIBITED_CONTENT")
+	assert.Equal(t, 12, resp.Usage.PromptTokens)
+}
+
+func TestToOpenAIChatCompletionResponse_MultipleCandidates(t *testing.T) {
+	geminiResp := &gemini.GenerateContentResponse{Response: map[string]interface{}{
+		"candidates": []interface{}{
+			map[string]interface{}{
+				"index":        float64(0),
+				"finishReason": "STOP",
+				"content":      map[string]interface{}{"parts": []interface{}{map[string]interface{}{"text": "Hi"}}},
+				"logprobsResult": map[string]interface{}{
+					"chosenCandidates": []interface{}{map[string]interface{}{"token": "Hi", "logProbability": -0.5}},
+					"topCandidates": []interface{}{map[string]interface{}{"candidates": []interface{}{
+						map[string]interface{}{"token": "Hi", "logProbability": -0.5},
+						map[string]interface{}{"token": "Hello", "logProbability": -1.5},
+					}}},
+				},
+			},
+			map[string]interface{}{
+				"index":        float64(1),
+				"finishReason": "MAX_TOKENS",
+				"content":      map[string]interface{}{"parts": []interface{}{map[string]interface{}{"text": "Hello th"}}},
+			},
+		},
+	}}
+
+	resp, err := ToOpenAIChatCompletionResponse(geminiResp, "gemini-2.5-pro")
+	require.NoError(t, err)
+	require.Len(t, resp.Choices, 2)
+
+	assert.Equal(t, 0, resp.Choices[0].Index)
+	assert.Equal(t, "Hi", resp.Choices[0].Message.Content)
+	require.NotNil(t, resp.Choices[0].Logprobs)
+	require.Len(t, resp.Choices[0].Logprobs.Content, 1)
+	assert.Equal(t, "Hi", resp.Choices[0].Logprobs.Content[0].Token)
+	assert.Equal(t, -0.5, resp.Choices[0].Logprobs.Content[0].Logprob)
+	assert.Equal(t, []int{'H', 'i'}, resp.Choices[0].Logprobs.Content[0].Bytes)
+	require.Len(t, resp.Choices[0].Logprobs.Content[0].TopLogprobs, 2)
+	assert.Equal(t, "Hello", resp.Choices[0].Logprobs.Content[0].TopLogprobs[1].Token)
+
+	assert.Equal(t, 1, resp.Choices[1].Index)
+	assert.Equal(t, "length", resp.Choices[1].FinishReason)
+	assert.Nil(t, resp.Choices[1].Logprobs)
+}
@@ -0,0 +1,131 @@
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/openai"
+	"github.com/google/uuid"
+)
+
+// ToResponsesResponse converts a Gemini generateContent response into a Responses API response.
+// base provides the id/model/previous_response_id of the response; only the first candidate is used.
+func ToResponsesResponse(geminiResp *gemini.GenerateContentResponse, base openai.Response) (*openai.Response, error) {
+	if geminiResp == nil || geminiResp.Response == nil {
+		return nil, fmt.Errorf("empty response")
+	}
+
+	resp := base
+	resp.Object = openai.ResponseObject
+	if resp.CreatedAt == 0 {
+		resp.CreatedAt = time.Now().Unix()
+	}
+	resp.Output = []openai.ResponseItem{}
+
+	finishReason := ""
+	if cands, ok := geminiResp.Response["candidates"].([]interface{}); ok && len(cands) > 0 {
+		cand, ok := cands[0].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("failed to cast candidate to map")
+		}
+		finishReason, _ = cand["finishReason"].(string)
+
+		var parts []interface{}
+		if c, ok := cand["content"].(map[string]interface{}); ok {
+			parts, _ = c["parts"].([]interface{})
+		}
+
+		for _, p := range parts {
+			part, ok := p.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			isThought, _ := part["thought"].(bool)
+			sig, _ := part["thoughtSignature"].(string)
+			txt, _ := part["text"].(string)
+			last := len(resp.Output) - 1
+
+			if isThought {
+				if last >= 0 && resp.Output[last].Type == "reasoning" && resp.Output[last].EncryptedContent == "" {
+					resp.Output[last].Summary[0].Text += txt
+					resp.Output[last].EncryptedContent = sig
+				} else {
+					resp.Output = append(resp.Output, openai.ResponseItem{
+						Type:             "reasoning",
+						ID:               openai.NewResponseItemID("rs"),
+						Summary:          []openai.ResponseSummaryPart{{Type: "summary_text", Text: txt}},
+						EncryptedContent: sig,
+					})
+				}
+				continue
+			}
+
+			// Signatures on regular parts travel as the preceding reasoning item's encrypted_content
+			if sig != "" {
+				if last >= 0 && resp.Output[last].Type == "reasoning" && resp.Output[last].EncryptedContent == "" {
+					resp.Output[last].EncryptedContent = sig
+				} else {
+					resp.Output = append(resp.Output, openai.ResponseItem{
+						Type:             "reasoning",
+						ID:               openai.NewResponseItemID("rs"),
+						EncryptedContent: sig,
+					})
+				}
+				last = len(resp.Output) - 1
+			}
+
+			if txt != "" {
+				if last >= 0 && resp.Output[last].Type == "message" {
+					resp.Output[last].Content[0].Text += txt
+				} else {
+					resp.Output = append(resp.Output, openai.ResponseItem{
+						Type:    "message",
+						ID:      openai.NewResponseItemID("msg"),
+						Status:  openai.ResponseStatusCompleted,
+						Role:    "assistant",
+						Content: openai.ResponseContent{{Type: "output_text", Text: txt, Annotations: []interface{}{}}},
+					})
+				}
+			}
+
+			if fc, ok := part["functionCall"].(map[string]interface{}); ok {
+				name, _ := fc["name"].(string)
+				args, _ := fc["args"].(map[string]interface{})
+				if args == nil {
+					args = map[string]interface{}{}
+				}
+				argsJSON, _ := json.Marshal(args)
+				resp.Output = append(resp.Output, openai.ResponseItem{
+					Type:      "function_call",
+					ID:        openai.NewResponseItemID("fc"),
+					Status:    openai.ResponseStatusCompleted,
+					CallID:    fmt.Sprintf("call_%s", uuid.New().String()),
+					Name:      name,
+					Arguments: string(argsJSON),
+				})
+			}
+		}
+	}
+
+	resp.Status, resp.IncompleteDetails = openai.ResponseStatusFromGemini(finishReason)
+
+	if um, ok := geminiResp.Response["usageMetadata"].(map[string]interface{}); ok {
+		usage := &openai.ResponseUsage{}
+		if v, ok := um["promptTokenCount"].(float64); ok {
+			usage.InputTokens = int(v)
+		}
+		if v, ok := um["candidatesTokenCount"].(float64); ok {
+			usage.OutputTokens = int(v)
+		}
+		if v, ok := um["thoughtsTokenCount"].(float64); ok {
+			usage.OutputTokensDetails.ReasoningTokens = int(v)
+			usage.OutputTokens += int(v)
+		}
+		usage.TotalTokens = usage.InputTokens + usage.OutputTokens
+		resp.Usage = usage
+	}
+
+	return &resp, nil
+}
@@ -0,0 +1,119 @@
+package transform
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
+)
+
+// mediaURLToPart converts an image/file URL into a Gemini part.
+// data: URIs become inlineData; http(s) URLs become fileData, which the server may
+// later resolve into inlineData (see FETCH_MEDIA_URLS). fallbackMime is used when the
+// MIME type cannot be derived from the URL.
+func mediaURLToPart(rawURL, fallbackMime string) (gemini.ContentPart, error) {
+	if strings.HasPrefix(rawURL, "data:") {
+		blob, err := parseDataURI(rawURL)
+		if err != nil {
+			return gemini.ContentPart{}, err
+		}
+		return gemini.ContentPart{InlineData: blob}, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "gs") {
+		return gemini.ContentPart{}, fmt.Errorf("unsupported media URL %q", truncateForError(rawURL))
+	}
+
+	mimeType := mimeTypeFromName(u.Path)
+	if mimeType == "" {
+		mimeType = fallbackMime
+	}
+	return gemini.ContentPart{FileData: &gemini.FileData{MimeType: mimeType, FileURI: rawURL}}, nil
+}
+
+// parseDataURI decodes a base64 data URI (data:<mime>;base64,<data>) into a Gemini blob.
+func parseDataURI(uri string) (*gemini.Blob, error) {
+	header, data, ok := strings.Cut(strings.TrimPrefix(uri, "data:"), ",")
+	if !ok {
+		return nil, fmt.Errorf("malformed data URI %q", truncateForError(uri))
+	}
+
+	params := strings.Split(header, ";")
+	mimeType := params[0]
+	isBase64 := false
+	for _, p := range params[1:] {
+		if p == "base64" {
+			isBase64 = true
+		}
+	}
+	if mimeType == "" {
+		mimeType = "text/plain"
+	}
+
+	if !isBase64 {
+		// Plain (percent-encoded) data; Gemini only takes base64
+		decoded, err := url.PathUnescape(data)
+		if err != nil {
+			return nil, fmt.Errorf("malformed data URI %q: %w", truncateForError(uri), err)
+		}
+		data = base64.StdEncoding.EncodeToString([]byte(decoded))
+	}
+
+	return &gemini.Blob{MimeType: mimeType, Data: data}, nil
+}
+
+// inlineBase64Part builds an inlineData part from raw base64 (or a data URI) and a MIME type.
+func inlineBase64Part(data, mimeType string) (gemini.ContentPart, error) {
+	if strings.HasPrefix(data, "data:") {
+		blob, err := parseDataURI(data)
+		if err != nil {
+			return gemini.ContentPart{}, err
+		}
+		return gemini.ContentPart{InlineData: blob}, nil
+	}
+	if data == "" {
+		return gemini.ContentPart{}, fmt.Errorf("empty inline data")
+	}
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	return gemini.ContentPart{InlineData: &gemini.Blob{MimeType: mimeType, Data: data}}, nil
+}
+
+// audioFormatMimeTypes maps OpenAI input_audio formats to MIME types Gemini accepts.
+var audioFormatMimeTypes = map[string]string{
+	"wav":  "audio/wav",
+	"mp3":  "audio/mp3",
+	"aiff": "audio/aiff",
+	"aac":  "audio/aac",
+	"ogg":  "audio/ogg",
+	"flac": "audio/flac",
+	"pcm":  "audio/pcm",
+}
+
+// mimeTypeFromName guesses a MIME type from a file name or URL path extension.
+func mimeTypeFromName(name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	if ext == "" {
+		return ""
+	}
+	mimeType := mime.TypeByExtension(ext)
+	if mimeType == "" {
+		return ""
+	}
+	// Drop parameters such as "; charset=utf-8"
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	return strings.TrimSpace(mimeType)
+}
+
+func truncateForError(s string) string {
+	if len(s) > 64 {
+		return s[:64] + "..."
+	}
+	return s
+}
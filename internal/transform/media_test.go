@@ -0,0 +1,120 @@
+package transform
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/anthropic"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToGeminiRequest_MultimodalParts(t *testing.T) {
+	payload := `{
+		"model": "gemini-2.5-pro",
+		"messages": [{
+			"role": "user",
+			"content": [
+				{"type": "text", "text": "What is in these?"},
+				{"type": "image_url", "image_url": {"url": "data:image/png;base64,iVBORw0KGgo=", "detail": "high"}},
+				{"type": "image_url", "image_url": {"url": "https://example.com/cat.webp"}},
+				{"type": "input_audio", "input_audio": {"data": "UklGRg==", "format": "wav"}},
+				{"type": "file", "file": {"filename": "spec.pdf", "file_data": "JVBERi0="}}
+			]
+		}]
+	}`
+
+	var req openai.ChatCompletionRequest
+	require.NoError(t, json.Unmarshal([]byte(payload), &req))
+
+	got, err := ToGeminiRequest(&req, "test-project")
+	require.NoError(t, err)
+	require.Len(t, got.Request.Contents, 1)
+
+	parts := got.Request.Contents[0].Parts
+	require.Len(t, parts, 5)
+	assert.Equal(t, "What is in these?", parts[0].Text)
+	assert.Equal(t, &gemini.Blob{MimeType: "image/png", Data: "iVBORw0KGgo="}, parts[1].InlineData)
+	assert.Equal(t, &gemini.FileData{MimeType: "image/webp", FileURI: "https://example.com/cat.webp"}, parts[2].FileData)
+	assert.Equal(t, &gemini.Blob{MimeType: "audio/wav", Data: "UklGRg=="}, parts[3].InlineData)
+	assert.Equal(t, &gemini.Blob{MimeType: "application/pdf", Data: "JVBERi0="}, parts[4].InlineData)
+}
+
+func TestToGeminiRequest_InvalidImageURL(t *testing.T) {
+	req := openai.ChatCompletionRequest{
+		Model: "gemini-2.5-pro",
+		Messages: []openai.Message{{
+			Role: "user",
+			Content: []interface{}{
+				map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "file:///etc/passwd"}},
+			},
+		}},
+	}
+
+	_, err := ToGeminiRequest(&req, "test-project")
+	assert.Error(t, err)
+}
+
+func TestParseDataURI(t *testing.T) {
+	blob, err := parseDataURI("data:text/plain,hello%20world")
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain", blob.MimeType)
+	assert.Equal(t, "aGVsbG8gd29ybGQ=", blob.Data)
+
+	_, err = parseDataURI("data:image/png;base64")
+	assert.Error(t, err)
+}
+
+func TestAnthropicToGeminiRequest_ImageAndDocumentBlocks(t *testing.T) {
+	payload := `{
+		"model": "claude-sonnet-4",
+		"max_tokens": 256,
+		"messages": [{
+			"role": "user",
+			"content": [
+				{"type": "image", "source": {"type": "base64", "media_type": "image/jpeg", "data": "/9j/4AAQ"}},
+				{"type": "image", "source": {"type": "url", "url": "https://example.com/chart"}},
+				{"type": "document", "source": {"type": "base64", "media_type": "application/pdf", "data": "JVBERi0="}},
+				{"type": "text", "text": "Summarize."}
+			]
+		}]
+	}`
+
+	var req anthropic.MessagesRequest
+	require.NoError(t, json.Unmarshal([]byte(payload), &req))
+
+	got, err := AnthropicToGeminiRequest(&req, "test-project")
+	require.NoError(t, err)
+
+	parts := got.Request.Contents[0].Parts
+	require.Len(t, parts, 4)
+	assert.Equal(t, &gemini.Blob{MimeType: "image/jpeg", Data: "/9j/4AAQ"}, parts[0].InlineData)
+	assert.Equal(t, &gemini.FileData{MimeType: "image/jpeg", FileURI: "https://example.com/chart"}, parts[1].FileData)
+	assert.Equal(t, &gemini.Blob{MimeType: "application/pdf", Data: "JVBERi0="}, parts[2].InlineData)
+	assert.Equal(t, "Summarize.", parts[3].Text)
+}
+
+func TestResponsesToGeminiRequest_InputImage(t *testing.T) {
+	payload := `{
+		"model": "gemini-2.5-pro",
+		"input": [{"role": "user", "content": [
+			{"type": "input_text", "text": "Describe"},
+			{"type": "input_image", "image_url": "data:image/gif;base64,R0lGOD=="},
+			{"type": "input_file", "filename": "notes.txt", "file_data": "data:text/plain;base64,aGk="}
+		]}]
+	}`
+
+	var req openai.ResponsesRequest
+	require.NoError(t, json.Unmarshal([]byte(payload), &req))
+
+	got, err := ResponsesToGeminiRequest(&req, req.Input, "test-project")
+	require.NoError(t, err)
+
+	parts := got.Request.Contents[0].Parts
+	require.Len(t, parts, 3)
+	assert.Equal(t, "Describe", parts[0].Text)
+	assert.Equal(t, &gemini.Blob{MimeType: "image/gif", Data: "R0lGOD=="}, parts[1].InlineData)
+	assert.Equal(t, &gemini.Blob{MimeType: "text/plain", Data: "aGk="}, parts[2].InlineData)
+}
@@ -24,18 +24,25 @@ func ToGeminiRequest(openAIReq *openai.ChatCompletionRequest, projectID string)
 	geminiTools := convertToolsToGeminiTools(openAIReq.Tools)
 
 	// Handle generation config
-	var genCfg *gemini.GeminiGenerationConfig
-	if openAIReq.Temperature > 0 || openAIReq.MaxTokens > 0 {
-		genCfg = &gemini.GeminiGenerationConfig{
-			Temperature:     openAIReq.Temperature,
-			MaxOutputTokens: openAIReq.MaxTokens,
-		}
+	genCfg, err := convertOpenAIGenerationConfig(openAIReq)
+	if err != nil {
+		return nil, err
+	}
+
+	// Handle tool_choice; Gemini rejects a functionCallingConfig without declarations
+	var toolConfig *gemini.ToolConfig
+	if len(geminiTools) > 0 {
+		toolConfig = convertOpenAIToolChoice(openAIReq.ToolChoice)
+	}
+	if openAIReq.ParallelToolCalls != nil && !*openAIReq.ParallelToolCalls {
+		logger.Get().Debug().Msg("parallel_tool_calls=false has no Gemini equivalent; ignoring")
 	}
 
 	internalReq = gemini.GeminiInternalRequest{
 		Contents:          geminiContents,
 		SystemInstruction: systemInstruction,
 		Tools:             geminiTools,
+		ToolConfig:        toolConfig,
 		GenerationConfig:  genCfg,
 	}
 
@@ -186,12 +193,17 @@ func convertMessagesToGeminiContents(messages []openai.Message) (geminiContents
 				})
 			} else {
 				for _, part := range content {
-					if p, ok := part.(map[string]interface{}); ok && p["type"] == "text" {
-						if txt, ok2 := p["text"].(string); ok2 {
-							parts = append(parts, gemini.ContentPart{Text: txt})
-						}
+					p, ok := part.(map[string]interface{})
+					if !ok {
+						continue
+					}
+					converted, ok, err := convertOpenAIContentPart(p)
+					if err != nil {
+						return nil, nil, err
+					}
+					if ok {
+						parts = append(parts, converted)
 					}
-					// TODO: Handle other part types like images
 				}
 			}
 		default:
@@ -210,6 +222,7 @@ func convertMessagesToGeminiContents(messages []openai.Message) (geminiContents
 						Name: tc.Function.Name,
 						Args: args,
 					},
+					ThoughtSignature: tc.ThoughtSignature,
 				})
 			}
 		}
@@ -282,72 +295,158 @@ func convertToolsToGeminiTools(tools []openai.Tool) []gemini.Tool {
 	}
 }
 
-// convertToGeminiSchema recursively converts a generic map representing a JSON schema
-// into the strongly-typed GeminiParameterSchema struct, only mapping supported fields.
-func convertToGeminiSchema(input map[string]interface{}) *gemini.GeminiParameterSchema {
-	if input == nil {
-		return nil
+// convertOpenAIGenerationConfig maps OpenAI sampling parameters onto a Gemini generationConfig.
+// It returns nil when the request sets none of them.
+func convertOpenAIGenerationConfig(req *openai.ChatCompletionRequest) (*gemini.GeminiGenerationConfig, error) {
+	cfg := &gemini.GeminiGenerationConfig{
+		Temperature:      req.Temperature,
+		TopP:             req.TopP,
+		StopSequences:    req.Stop,
+		Seed:             req.Seed,
+		PresencePenalty:  req.PresencePenalty,
+		FrequencyPenalty: req.FrequencyPenalty,
+		MaxOutputTokens:  req.MaxTokens,
 	}
-
-	// Handle complex schemas with anyOf or oneOf by prioritizing the array definition.
-	var subSchemas []interface{}
-	if anyOf, ok := input["anyOf"].([]interface{}); ok {
-		subSchemas = anyOf
-	} else if oneOf, ok := input["oneOf"].([]interface{}); ok {
-		subSchemas = oneOf
+	if req.MaxCompletionTokens > 0 {
+		cfg.MaxOutputTokens = req.MaxCompletionTokens
 	}
-
-	if subSchemas != nil {
-		for _, subSchema := range subSchemas {
-			if subSchemaMap, ok := subSchema.(map[string]interface{}); ok {
-				if subSchemaMap["type"] == "array" {
-					// Found the preferred array schema, convert it.
-					// We also merge the description from the parent level.
-					if parentDesc, ok := input["description"].(string); ok {
-						subSchemaMap["description"] = parentDesc
-					}
-					return convertToGeminiSchema(subSchemaMap)
-				}
-			}
-		}
+	if req.N > 1 {
+		cfg.CandidateCount = req.N
 	}
-
-	output := &gemini.GeminiParameterSchema{}
-	if t, ok := input["type"].(string); ok {
-		output.Type = strings.ToUpper(t)
+	if req.Logprobs {
+		cfg.ResponseLogprobs = true
+		cfg.Logprobs = req.TopLogprobs
 	}
-	if d, ok := input["description"].(string); ok {
-		output.Description = d
+	if err := applyOpenAIResponseFormat(cfg, req.ResponseFormat); err != nil {
+		return nil, err
 	}
 
-	if r, ok := input["required"].([]interface{}); ok {
-		for _, v := range r {
-			if s, ok := v.(string); ok {
-				output.Required = append(output.Required, s)
-			}
-		}
+	if cfg.Temperature == nil && cfg.TopP == nil && len(cfg.StopSequences) == 0 && cfg.Seed == nil &&
+		cfg.PresencePenalty == nil && cfg.FrequencyPenalty == nil && cfg.MaxOutputTokens == 0 &&
+		cfg.CandidateCount == 0 && !cfg.ResponseLogprobs && cfg.ResponseMimeType == "" {
+		return nil, nil
 	}
+	return cfg, nil
+}
 
-	if e, ok := input["enum"].([]interface{}); ok {
-		for _, v := range e {
-			if s, ok := v.(string); ok {
-				output.Enum = append(output.Enum, s)
-			}
+// applyOpenAIResponseFormat translates response_format into Gemini's JSON mode.
+// json_schema schemas go through convertToGeminiSchema like tool parameters.
+func applyOpenAIResponseFormat(cfg *gemini.GeminiGenerationConfig, rf *openai.ResponseFormat) error {
+	if rf == nil {
+		return nil
+	}
+	switch rf.Type {
+	case "", "text":
+		return nil
+	case "json_object":
+		cfg.ResponseMimeType = "application/json"
+		return nil
+	case "json_schema":
+		if rf.JSONSchema == nil || rf.JSONSchema.Schema == nil {
+			return fmt.Errorf("response_format json_schema requires a schema")
 		}
+		cfg.ResponseMimeType = "application/json"
+		cfg.ResponseSchema = convertToGeminiSchema(rf.JSONSchema.Schema)
+		return nil
+	default:
+		return fmt.Errorf("unsupported response_format type %q", rf.Type)
 	}
+}
 
-	if p, ok := input["properties"].(map[string]interface{}); ok {
-		output.Properties = make(map[string]*gemini.GeminiParameterSchema)
-		for k, v := range p {
-			if vMap, ok := v.(map[string]interface{}); ok {
-				output.Properties[k] = convertToGeminiSchema(vMap)
-			}
+// convertOpenAIToolChoice maps an OpenAI tool_choice ("none", "auto", "required" or a
+// named function object) onto Gemini's functionCallingConfig.
+// Both the chat completions ({type, function: {name}}) and the Responses ({type, name})
+// object shapes are accepted.
+func convertOpenAIToolChoice(choice interface{}) *gemini.ToolConfig {
+	cfg := &gemini.FunctionCallingConfig{}
+	switch v := choice.(type) {
+	case nil:
+		return nil
+	case string:
+		switch v {
+		case "none":
+			cfg.Mode = "NONE"
+		case "auto":
+			cfg.Mode = "AUTO"
+		case "required":
+			cfg.Mode = "ANY"
+		default:
+			logger.Get().Warn().Str("tool_choice", v).Msg("Ignoring unsupported tool_choice")
+			return nil
+		}
+	case map[string]interface{}:
+		name, _ := v["name"].(string)
+		if fn, ok := v["function"].(map[string]interface{}); ok && name == "" {
+			name, _ = fn["name"].(string)
 		}
+		if name == "" {
+			logger.Get().Warn().Interface("tool_choice", v).Msg("Ignoring tool_choice without a function name")
+			return nil
+		}
+		cfg.Mode = "ANY"
+		cfg.AllowedFunctionNames = []string{name}
+	default:
+		return nil
 	}
+	return &gemini.ToolConfig{FunctionCallingConfig: cfg}
+}
 
-	if i, ok := input["items"].(map[string]interface{}); ok {
-		output.Items = convertToGeminiSchema(i)
-	}
+// convertOpenAIContentPart converts a single OpenAI message content part into a Gemini part.
+// It returns ok=false for parts that are skipped.
+func convertOpenAIContentPart(p map[string]interface{}) (part gemini.ContentPart, ok bool, err error) {
+	partType, _ := p["type"].(string)
+	switch partType {
+	case "text":
+		txt, ok := p["text"].(string)
+		return gemini.ContentPart{Text: txt}, ok, nil
+
+	case "image_url":
+		// image_url is {"url": "...", "detail": "..."}; some clients send the URL string directly
+		var imageURL string
+		switch v := p["image_url"].(type) {
+		case string:
+			imageURL = v
+		case map[string]interface{}:
+			imageURL, _ = v["url"].(string)
+		}
+		if imageURL == "" {
+			return gemini.ContentPart{}, false, fmt.Errorf("image_url part without url")
+		}
+		part, err := mediaURLToPart(imageURL, "image/jpeg")
+		return part, err == nil, err
+
+	case "input_audio":
+		audio, _ := p["input_audio"].(map[string]interface{})
+		data, _ := audio["data"].(string)
+		format, _ := audio["format"].(string)
+		mimeType, known := audioFormatMimeTypes[strings.ToLower(format)]
+		if !known {
+			mimeType = "audio/" + strings.ToLower(format)
+		}
+		part, err := inlineBase64Part(data, mimeType)
+		if err != nil {
+			return gemini.ContentPart{}, false, fmt.Errorf("invalid input_audio part: %w", err)
+		}
+		return part, true, nil
+
+	case "file":
+		file, _ := p["file"].(map[string]interface{})
+		data, _ := file["file_data"].(string)
+		filename, _ := file["filename"].(string)
+		if data == "" {
+			// Uploaded file ids refer to OpenAI's file store, which Gemini cannot read
+			fileID, _ := file["file_id"].(string)
+			logger.Get().Warn().Str("file_id", fileID).Msg("Skipping file part without file_data")
+			return gemini.ContentPart{}, false, nil
+		}
+		part, err := inlineBase64Part(data, mimeTypeFromName(filename))
+		if err != nil {
+			return gemini.ContentPart{}, false, fmt.Errorf("invalid file part: %w", err)
+		}
+		return part, true, nil
 
-	return output
+	default:
+		logger.Get().Warn().Str("type", partType).Msg("Skipping unsupported OpenAI content part")
+		return gemini.ContentPart{}, false, nil
+	}
 }
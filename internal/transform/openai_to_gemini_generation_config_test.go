@@ -0,0 +1,67 @@
+package transform
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToGeminiRequest_GenerationParams(t *testing.T) {
+	payload := `{
+		"model": "gemini-2.5-pro",
+		"messages": [{"role": "user", "content": "Hi"}],
+		"temperature": 0,
+		"top_p": 0.9,
+		"stop": "END",
+		"n": 2,
+		"seed": 42,
+		"presence_penalty": 0.5,
+		"frequency_penalty": -0.25,
+		"max_tokens": 100,
+		"max_completion_tokens": 200,
+		"logprobs": true,
+		"top_logprobs": 3
+	}`
+
+	var req openai.ChatCompletionRequest
+	require.NoError(t, json.Unmarshal([]byte(payload), &req))
+
+	got, err := ToGeminiRequest(&req, "test-project")
+	require.NoError(t, err)
+
+	out, err := json.Marshal(got.Request.GenerationConfig)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"temperature": 0,
+		"topP": 0.9,
+		"stopSequences": ["END"],
+		"candidateCount": 2,
+		"seed": 42,
+		"presencePenalty": 0.5,
+		"frequencyPenalty": -0.25,
+		"maxOutputTokens": 200,
+		"responseLogprobs": true,
+		"logprobs": 3
+	}`, string(out))
+}
+
+func TestToGeminiRequest_GenerationParamsUnset(t *testing.T) {
+	payload := `{"model": "gemini-2.5-pro", "messages": [{"role": "user", "content": "Hi"}], "n": 1, "stop": ["a", "b"]}`
+
+	var req openai.ChatCompletionRequest
+	require.NoError(t, json.Unmarshal([]byte(payload), &req))
+
+	got, err := ToGeminiRequest(&req, "test-project")
+	require.NoError(t, err)
+	require.NotNil(t, got.Request.GenerationConfig)
+	assert.Equal(t, []string{"a", "b"}, got.Request.GenerationConfig.StopSequences)
+	assert.Zero(t, got.Request.GenerationConfig.CandidateCount, "n=1 is the Gemini default")
+
+	req = openai.ChatCompletionRequest{Model: "gemini-2.5-pro", Messages: []openai.Message{{Role: "user", Content: "Hi"}}}
+	got, err = ToGeminiRequest(&req, "test-project")
+	require.NoError(t, err)
+	assert.Nil(t, got.Request.GenerationConfig)
+}
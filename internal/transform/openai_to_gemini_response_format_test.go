@@ -0,0 +1,75 @@
+package transform
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToGeminiRequest_ResponseFormat(t *testing.T) {
+	t.Run("json_object", func(t *testing.T) {
+		req := openai.ChatCompletionRequest{
+			Model:          "gemini-2.5-pro",
+			Messages:       []openai.Message{{Role: "user", Content: "List three colors as JSON"}},
+			ResponseFormat: &openai.ResponseFormat{Type: "json_object"},
+		}
+		got, err := ToGeminiRequest(&req, "test-project")
+		require.NoError(t, err)
+		require.NotNil(t, got.Request.GenerationConfig)
+		assert.Equal(t, "application/json", got.Request.GenerationConfig.ResponseMimeType)
+		assert.Nil(t, got.Request.GenerationConfig.ResponseSchema)
+	})
+
+	t.Run("json_schema", func(t *testing.T) {
+		payload := `{
+			"model": "gemini-2.5-pro",
+			"messages": [{"role": "user", "content": "Extract the person"}],
+			"response_format": {
+				"type": "json_schema",
+				"json_schema": {
+					"name": "person",
+					"strict": true,
+					"schema": {
+						"type": "object",
+						"properties": {
+							"name": {"type": "string"},
+							"tags": {"type": "array", "items": {"type": "string"}}
+						},
+						"required": ["name"],
+						"additionalProperties": false
+					}
+				}
+			}
+		}`
+		var req openai.ChatCompletionRequest
+		require.NoError(t, json.Unmarshal([]byte(payload), &req))
+
+		got, err := ToGeminiRequest(&req, "test-project")
+		require.NoError(t, err)
+		cfg := got.Request.GenerationConfig
+		require.NotNil(t, cfg)
+		assert.Equal(t, "application/json", cfg.ResponseMimeType)
+		assert.Equal(t, &gemini.GeminiParameterSchema{
+			Type: "OBJECT",
+			Properties: map[string]*gemini.GeminiParameterSchema{
+				"name": {Type: "STRING"},
+				"tags": {Type: "ARRAY", Items: &gemini.GeminiParameterSchema{Type: "STRING"}},
+			},
+			Required: []string{"name"},
+		}, cfg.ResponseSchema)
+	})
+
+	t.Run("json_schema without schema", func(t *testing.T) {
+		req := openai.ChatCompletionRequest{
+			Model:          "gemini-2.5-pro",
+			Messages:       []openai.Message{{Role: "user", Content: "hi"}},
+			ResponseFormat: &openai.ResponseFormat{Type: "json_schema"},
+		}
+		_, err := ToGeminiRequest(&req, "test-project")
+		assert.Error(t, err)
+	})
+}
@@ -67,20 +67,26 @@ func TestConvertToGeminiSchema(t *testing.T) {
 				},
 			},
 			expectedSchema: &gemini.GeminiParameterSchema{
-				Type:        "ARRAY",
 				Description: "The updated todo list",
-				Items: &gemini.GeminiParameterSchema{
-					Type:     "OBJECT",
-					Required: []string{"content", "status"},
-					Properties: map[string]*gemini.GeminiParameterSchema{
-						"content": {
-							Type: "STRING",
-						},
-						"status": {
-							Type: "STRING",
-							Enum: []string{"pending", "completed"},
+				AnyOf: []*gemini.GeminiParameterSchema{
+					{
+						Type:     "ARRAY",
+						MaxItems: int64Ptr(50),
+						Items: &gemini.GeminiParameterSchema{
+							Type:     "OBJECT",
+							Required: []string{"content", "status"},
+							Properties: map[string]*gemini.GeminiParameterSchema{
+								"content": {
+									Type: "STRING",
+								},
+								"status": {
+									Type: "STRING",
+									Enum: []string{"pending", "completed"},
+								},
+							},
 						},
 					},
+					{Type: "STRING"},
 				},
 			},
 		},
@@ -101,10 +107,15 @@ func TestConvertToGeminiSchema(t *testing.T) {
 				},
 			},
 			expectedSchema: &gemini.GeminiParameterSchema{
-				Type:        "ARRAY",
 				Description: "A parameter that can be one of several types.",
-				Items: &gemini.GeminiParameterSchema{
-					Type: "NUMBER",
+				AnyOf: []*gemini.GeminiParameterSchema{
+					{Type: "STRING"},
+					{
+						Type: "ARRAY",
+						Items: &gemini.GeminiParameterSchema{
+							Type: "NUMBER",
+						},
+					},
 				},
 			},
 		},
@@ -3,6 +3,7 @@ package transform
 import (
 	"testing"
 
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
 	"github.com/dvcrn/gemini-code-assist-proxy/internal/openai"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -69,3 +70,79 @@ func TestToolResponseNameResolutionViaToolCallID(t *testing.T) {
 		t.Fatalf("functionResponse.response.output missing or not a string: %#v", part.FunctionResponse.Response)
 	}
 }
+
+func TestToGeminiRequest_ToolChoice(t *testing.T) {
+	tools := []openai.Tool{
+		{Type: "function", Function: openai.Function{Name: "plan", Parameters: map[string]interface{}{"type": "object"}}},
+		{Type: "function", Function: openai.Function{Name: "read", Parameters: map[string]interface{}{"type": "object"}}},
+	}
+
+	testCases := []struct {
+		name       string
+		toolChoice interface{}
+		tools      []openai.Tool
+		expected   *gemini.FunctionCallingConfig
+	}{
+		{name: "unset", toolChoice: nil, tools: tools, expected: nil},
+		{name: "none", toolChoice: "none", tools: tools, expected: &gemini.FunctionCallingConfig{Mode: "NONE"}},
+		{name: "auto", toolChoice: "auto", tools: tools, expected: &gemini.FunctionCallingConfig{Mode: "AUTO"}},
+		{name: "required", toolChoice: "required", tools: tools, expected: &gemini.FunctionCallingConfig{Mode: "ANY"}},
+		{
+			name:       "named function",
+			toolChoice: map[string]interface{}{"type": "function", "function": map[string]interface{}{"name": "plan"}},
+			tools:      tools,
+			expected:   &gemini.FunctionCallingConfig{Mode: "ANY", AllowedFunctionNames: []string{"plan"}},
+		},
+		{name: "required without tools", toolChoice: "required", tools: nil, expected: nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			parallel := false
+			req := &openai.ChatCompletionRequest{
+				Model:             "gemini-2.5-pro",
+				Messages:          []openai.Message{{Role: "user", Content: "Make a plan"}},
+				Tools:             tc.tools,
+				ToolChoice:        tc.toolChoice,
+				ParallelToolCalls: &parallel,
+			}
+
+			got, err := ToGeminiRequest(req, "test-project")
+			require.NoError(t, err)
+
+			if tc.expected == nil {
+				assert.Nil(t, got.Request.ToolConfig)
+				return
+			}
+			require.NotNil(t, got.Request.ToolConfig)
+			assert.Equal(t, tc.expected, got.Request.ToolConfig.FunctionCallingConfig)
+		})
+	}
+}
+
+func TestToGeminiRequest_ToolCallThoughtSignature(t *testing.T) {
+	req := &openai.ChatCompletionRequest{
+		Model: "gemini-3-pro-preview",
+		Messages: []openai.Message{
+			{Role: "user", Content: "Read a and b"},
+			{
+				Role: "assistant",
+				ToolCalls: []openai.OpenAIToolCall{
+					{ID: "call_1", Type: "function", Function: openai.OpenAIFunctionCall{Name: "read", Arguments: `{"path":"a"}`}, ThoughtSignature: "sig-1"},
+					{ID: "call_2", Type: "function", Function: openai.OpenAIFunctionCall{Name: "read", Arguments: `{"path":"b"}`}},
+				},
+			},
+			{Role: "tool", ToolCallID: "call_1", Content: "A"},
+			{Role: "tool", ToolCallID: "call_2", Content: "B"},
+		},
+	}
+
+	got, err := ToGeminiRequest(req, "test-project")
+	require.NoError(t, err)
+	require.Len(t, got.Request.Contents, 3)
+
+	modelTurn := got.Request.Contents[1]
+	require.Len(t, modelTurn.Parts, 2)
+	assert.Equal(t, "sig-1", modelTurn.Parts[0].ThoughtSignature)
+	assert.Empty(t, modelTurn.Parts[1].ThoughtSignature)
+}
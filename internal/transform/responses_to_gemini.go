@@ -0,0 +1,266 @@
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/logger"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/openai"
+)
+
+// ResponsesToGeminiRequest converts an OpenAI Responses request to a Gemini generateContent request.
+// items is the full conversation: any stored history for previous_response_id followed by req.Input.
+func ResponsesToGeminiRequest(req *openai.ResponsesRequest, items []openai.ResponseItem, projectID string) (*gemini.GenerateContentRequest, error) {
+	contents, systemParts, err := convertResponseItemsToGeminiContents(items)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert input items: %w", err)
+	}
+
+	var systemInstruction *gemini.SystemInstruction
+	if req.Instructions != "" {
+		systemParts = append([]gemini.ContentPart{{Text: req.Instructions}}, systemParts...)
+	}
+	if len(systemParts) > 0 {
+		systemInstruction = &gemini.SystemInstruction{Role: "system", Parts: systemParts}
+	}
+
+	var genCfg *gemini.GeminiGenerationConfig
+	if req.MaxOutputTokens > 0 || req.Temperature != nil || req.TopP != nil || req.Reasoning != nil {
+		genCfg = &gemini.GeminiGenerationConfig{MaxOutputTokens: req.MaxOutputTokens}
+		if req.Temperature != nil {
+			genCfg.Temperature = req.Temperature
+		}
+		if req.TopP != nil {
+			genCfg.TopP = req.TopP
+		}
+		if req.Reasoning != nil {
+			genCfg.ThinkingConfig = &gemini.ThinkingConfig{IncludeThoughts: true}
+		}
+	}
+
+	tools := convertResponsesToolsToGeminiTools(req.Tools)
+	var toolConfig *gemini.ToolConfig
+	if len(tools) > 0 {
+		toolConfig = convertOpenAIToolChoice(req.ToolChoice)
+	}
+
+	internalReq := gemini.GeminiInternalRequest{
+		Contents:          contents,
+		SystemInstruction: systemInstruction,
+		Tools:             tools,
+		ToolConfig:        toolConfig,
+		GenerationConfig:  genCfg,
+	}
+
+	return &gemini.GenerateContentRequest{
+		Model:   req.Model,
+		Project: projectID,
+		Request: internalReq,
+	}, nil
+}
+
+// convertResponseItemsToGeminiContents maps Responses input items onto Gemini contents.
+// Consecutive items of the same role are merged into one turn so that parallel
+// function_call items and their function_call_output items each form a single turn.
+// Reasoning items are not replayed as text; their encrypted_content (a Gemini thought
+// signature) is re-attached to the following model part.
+func convertResponseItemsToGeminiContents(items []openai.ResponseItem) ([]gemini.Content, []gemini.ContentPart, error) {
+	// Build call_id -> function name map from function_call items
+	nameByCallID := map[string]string{}
+	for _, item := range items {
+		if item.ItemType() == "function_call" && item.CallID != "" && item.Name != "" {
+			nameByCallID[item.CallID] = item.Name
+		}
+	}
+
+	var contents []gemini.Content
+	var systemParts []gemini.ContentPart
+	pendingSignature := ""
+
+	flushSignature := func() {
+		// A signature with no following model part belongs to the last part of the model turn
+		if pendingSignature == "" || len(contents) == 0 {
+			pendingSignature = ""
+			return
+		}
+		last := &contents[len(contents)-1]
+		if last.Role == "model" && len(last.Parts) > 0 && last.Parts[len(last.Parts)-1].ThoughtSignature == "" {
+			last.Parts[len(last.Parts)-1].ThoughtSignature = pendingSignature
+		}
+		pendingSignature = ""
+	}
+
+	appendPart := func(role string, part gemini.ContentPart) {
+		if role == "model" && pendingSignature != "" {
+			part.ThoughtSignature = pendingSignature
+			pendingSignature = ""
+		} else if role != "model" {
+			flushSignature()
+		}
+		if n := len(contents); n > 0 && contents[n-1].Role == role {
+			contents[n-1].Parts = append(contents[n-1].Parts, part)
+			return
+		}
+		contents = append(contents, gemini.Content{Role: role, Parts: []gemini.ContentPart{part}})
+	}
+
+	for _, item := range items {
+		switch item.ItemType() {
+		case "message":
+			switch item.Role {
+			case "system", "developer":
+				if text := responseContentText(item.Content); text != "" {
+					systemParts = append(systemParts, gemini.ContentPart{Text: text})
+				}
+			case "assistant":
+				if text := responseContentText(item.Content); text != "" {
+					appendPart("model", gemini.ContentPart{Text: text})
+				}
+			default:
+				parts, err := responseContentParts(item.Content)
+				if err != nil {
+					return nil, nil, err
+				}
+				for _, part := range parts {
+					appendPart("user", part)
+				}
+			}
+
+		case "function_call":
+			var args map[string]interface{}
+			if err := json.Unmarshal([]byte(item.Arguments), &args); err != nil {
+				args = map[string]interface{}{}
+			}
+			appendPart("model", gemini.ContentPart{
+				FunctionCall: &gemini.FunctionCall{Name: item.Name, Args: args},
+			})
+
+		case "function_call_output":
+			name, ok := nameByCallID[item.CallID]
+			if !ok {
+				return nil, nil, fmt.Errorf("function_call_output references unknown call_id %q", item.CallID)
+			}
+			output := item.OutputText()
+
+			preview := output
+			if len(preview) > 300 {
+				preview = preview[:300] + "..."
+			}
+			logger.Get().Info().
+				Str("function", name).
+				Str("call_id", item.CallID).
+				Int("response_len", len(output)).
+				Str("response_preview", preview).
+				Msg("Forwarding tool response to Gemini")
+
+			appendPart("user", gemini.ContentPart{
+				FunctionResponse: &gemini.FunctionResponse{
+					Name:     name,
+					Response: map[string]interface{}{"output": output},
+				},
+			})
+
+		case "reasoning":
+			if item.EncryptedContent != "" {
+				pendingSignature = item.EncryptedContent
+			}
+
+		default:
+			logger.Get().Warn().Str("type", item.Type).Msg("Skipping unsupported Responses input item")
+		}
+	}
+	flushSignature()
+
+	return contents, systemParts, nil
+}
+
+// responseContentText concatenates the text parts of a message item.
+func responseContentText(content openai.ResponseContent) string {
+	var b strings.Builder
+	for _, part := range content {
+		switch part.Type {
+		case "input_text", "output_text", "text":
+			if part.Text == "" {
+				continue
+			}
+			if b.Len() > 0 {
+				b.WriteString("\n")
+			}
+			b.WriteString(part.Text)
+		default:
+			logger.Get().Warn().Str("type", part.Type).Msg("Skipping unsupported Responses content part")
+		}
+	}
+	return b.String()
+}
+
+// responseContentParts converts the parts of a user message item into Gemini parts,
+// including input_image and input_file media.
+func responseContentParts(content openai.ResponseContent) ([]gemini.ContentPart, error) {
+	var parts []gemini.ContentPart
+	for _, part := range content {
+		switch part.Type {
+		case "input_text", "output_text", "text":
+			if part.Text != "" {
+				parts = append(parts, gemini.ContentPart{Text: part.Text})
+			}
+		case "input_image":
+			if part.ImageURL == "" {
+				logger.Get().Warn().Str("file_id", part.FileID).Msg("Skipping input_image without image_url")
+				continue
+			}
+			p, err := mediaURLToPart(part.ImageURL, "image/jpeg")
+			if err != nil {
+				return nil, fmt.Errorf("invalid input_image part: %w", err)
+			}
+			parts = append(parts, p)
+		case "input_file":
+			var p gemini.ContentPart
+			var err error
+			switch {
+			case part.FileData != "":
+				p, err = inlineBase64Part(part.FileData, mimeTypeFromName(part.Filename))
+			case part.FileURL != "":
+				p, err = mediaURLToPart(part.FileURL, "application/pdf")
+			default:
+				logger.Get().Warn().Str("file_id", part.FileID).Msg("Skipping input_file without file_data or file_url")
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid input_file part: %w", err)
+			}
+			parts = append(parts, p)
+		default:
+			logger.Get().Warn().Str("type", part.Type).Msg("Skipping unsupported Responses content part")
+		}
+	}
+	return parts, nil
+}
+
+func convertResponsesToolsToGeminiTools(tools []openai.ResponsesTool) []gemini.Tool {
+	var fns []gemini.FunctionDeclaration
+	for _, t := range tools {
+		if t.Type != "function" {
+			logger.Get().Warn().Str("type", t.Type).Msg("Skipping unsupported Responses tool type")
+			continue
+		}
+
+		var schema *gemini.GeminiParameterSchema
+		if m, ok := t.Parameters.(map[string]interface{}); ok {
+			schema = convertToGeminiSchema(m)
+		}
+
+		fns = append(fns, gemini.FunctionDeclaration{
+			Name:        t.Name,
+			Description: t.Description,
+			Parameters:  schema,
+		})
+	}
+
+	if len(fns) == 0 {
+		return nil
+	}
+	return []gemini.Tool{{FunctionDeclarations: fns}}
+}
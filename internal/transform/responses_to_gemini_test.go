@@ -0,0 +1,125 @@
+package transform
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponsesToGeminiRequest_StringInput(t *testing.T) {
+	payload := `{"model": "gemini-2.5-pro", "instructions": "Be brief.", "input": "Hi there"}`
+
+	var req openai.ResponsesRequest
+	require.NoError(t, json.Unmarshal([]byte(payload), &req))
+
+	got, err := ResponsesToGeminiRequest(&req, req.Input, "test-project")
+	require.NoError(t, err)
+
+	assert.Equal(t, "test-project", got.Project)
+	require.NotNil(t, got.Request.SystemInstruction)
+	assert.Equal(t, "Be brief.", got.Request.SystemInstruction.Parts[0].Text)
+	require.Len(t, got.Request.Contents, 1)
+	assert.Equal(t, "user", got.Request.Contents[0].Role)
+	assert.Equal(t, "Hi there", got.Request.Contents[0].Parts[0].Text)
+}
+
+func TestResponsesToGeminiRequest_ParallelToolCalls(t *testing.T) {
+	payload := `{
+		"model": "gemini-2.5-pro",
+		"tool_choice": "required",
+		"tools": [{"type": "function", "name": "read", "parameters": {"type": "object", "properties": {"path": {"type": "string"}}}}],
+		"input": [
+			{"role": "developer", "content": "Use tools."},
+			{"role": "user", "content": [{"type": "input_text", "text": "Read a.md and b.md"}]},
+			{"type": "reasoning", "id": "rs_1", "summary": [], "encrypted_content": "sig-1"},
+			{"type": "function_call", "call_id": "call_a", "name": "read", "arguments": "{\"path\":\"a.md\"}"},
+			{"type": "function_call", "call_id": "call_b", "name": "read", "arguments": "{\"path\":\"b.md\"}"},
+			{"type": "function_call_output", "call_id": "call_a", "output": "A"},
+			{"type": "function_call_output", "call_id": "call_b", "output": "B"}
+		]
+	}`
+
+	var req openai.ResponsesRequest
+	require.NoError(t, json.Unmarshal([]byte(payload), &req))
+
+	got, err := ResponsesToGeminiRequest(&req, req.Input, "test-project")
+	require.NoError(t, err)
+
+	require.NotNil(t, got.Request.SystemInstruction)
+	assert.Equal(t, "Use tools.", got.Request.SystemInstruction.Parts[0].Text)
+
+	contents := got.Request.Contents
+	require.Len(t, contents, 3)
+
+	// Both calls form one model turn; the reasoning signature lands on the first call
+	assert.Equal(t, "model", contents[1].Role)
+	require.Len(t, contents[1].Parts, 2)
+	assert.Equal(t, "sig-1", contents[1].Parts[0].ThoughtSignature)
+	assert.Equal(t, "a.md", contents[1].Parts[0].FunctionCall.Args["path"])
+	assert.Empty(t, contents[1].Parts[1].ThoughtSignature)
+
+	// Both outputs form one user turn with names resolved from call_id
+	assert.Equal(t, "user", contents[2].Role)
+	require.Len(t, contents[2].Parts, 2)
+	assert.Equal(t, "read", contents[2].Parts[0].FunctionResponse.Name)
+	assert.Equal(t, map[string]interface{}{"output": "B"}, contents[2].Parts[1].FunctionResponse.Response)
+
+	require.Len(t, got.Request.Tools, 1)
+	assert.Equal(t, "read", got.Request.Tools[0].FunctionDeclarations[0].Name)
+	require.NotNil(t, got.Request.ToolConfig)
+	assert.Equal(t, "ANY", got.Request.ToolConfig.FunctionCallingConfig.Mode)
+}
+
+func TestResponsesToGeminiRequest_UnknownCallID(t *testing.T) {
+	items := []openai.ResponseItem{
+		{Type: "function_call_output", CallID: "call_missing", Output: "x"},
+	}
+	_, err := ResponsesToGeminiRequest(&openai.ResponsesRequest{Model: "gemini-2.5-pro"}, items, "p")
+	assert.Error(t, err)
+}
+
+func TestToResponsesResponse(t *testing.T) {
+	geminiResp := &gemini.GenerateContentResponse{Response: map[string]interface{}{
+		"candidates": []interface{}{
+			map[string]interface{}{
+				"finishReason": "STOP",
+				"content": map[string]interface{}{
+					"role": "model",
+					"parts": []interface{}{
+						map[string]interface{}{"text": "Thinking...", "thought": true},
+						map[string]interface{}{"text": "Reading.", "thoughtSignature": "sig-1"},
+						map[string]interface{}{"functionCall": map[string]interface{}{"name": "read", "args": map[string]interface{}{"path": "a.md"}}},
+					},
+				},
+			},
+		},
+		"usageMetadata": map[string]interface{}{
+			"promptTokenCount":     float64(10),
+			"candidatesTokenCount": float64(4),
+			"thoughtsTokenCount":   float64(6),
+		},
+	}}
+
+	resp, err := ToResponsesResponse(geminiResp, openai.Response{ID: "resp_1", Model: "gemini-2.5-pro"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "resp_1", resp.ID)
+	assert.Equal(t, openai.ResponseObject, resp.Object)
+	assert.Equal(t, openai.ResponseStatusCompleted, resp.Status)
+	require.Len(t, resp.Output, 3)
+	assert.Equal(t, "reasoning", resp.Output[0].Type)
+	assert.Equal(t, "sig-1", resp.Output[0].EncryptedContent)
+	assert.Equal(t, "Reading.", resp.Output[1].Content[0].Text)
+	assert.Equal(t, "function_call", resp.Output[2].Type)
+	assert.JSONEq(t, `{"path":"a.md"}`, resp.Output[2].Arguments)
+
+	require.NotNil(t, resp.Usage)
+	assert.Equal(t, 10, resp.Usage.InputTokens)
+	assert.Equal(t, 10, resp.Usage.OutputTokens)
+	assert.Equal(t, 6, resp.Usage.OutputTokensDetails.ReasoningTokens)
+	assert.Equal(t, 20, resp.Usage.TotalTokens)
+}
@@ -0,0 +1,412 @@
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/logger"
+)
+
+// geminiSchemaFormats lists the formats Gemini accepts per type; other formats are dropped.
+var geminiSchemaFormats = map[string]map[string]bool{
+	"STRING":  {"enum": true, "date-time": true},
+	"NUMBER":  {"float": true, "double": true},
+	"INTEGER": {"int32": true, "int64": true},
+}
+
+// convertToGeminiSchema recursively converts a generic map representing a JSON schema
+// (as sent by OpenAI, Anthropic and MCP clients) into Gemini's OpenAPI-style schema:
+//   - local $ref pointers ($defs, definitions, ...) are inlined; a reference back into a
+//     schema that is already being expanded becomes a plain object
+//   - allOf branches are merged into one schema
+//   - type: [x, "null"] and "null" anyOf/oneOf branches become nullable
+//   - anyOf/oneOf with several branches become anyOf, a single branch is merged into its parent
+//   - const and non-string enums become string enums or a hint in the description
+//   - keywords Gemini does not support are dropped
+func convertToGeminiSchema(input map[string]interface{}) *gemini.GeminiParameterSchema {
+	if input == nil {
+		return nil
+	}
+	c := &schemaConverter{root: input, expanding: map[string]bool{}}
+	return c.convert(input)
+}
+
+type schemaConverter struct {
+	root      map[string]interface{}
+	expanding map[string]bool // $refs currently being inlined
+}
+
+func (c *schemaConverter) convert(input map[string]interface{}) *gemini.GeminiParameterSchema {
+	if ref, ok := input["$ref"].(string); ok {
+		return c.convertRef(ref, input)
+	}
+
+	if allOf, ok := input["allOf"].([]interface{}); ok {
+		return c.convertAllOf(allOf, input)
+	}
+
+	nullable, _ := input["nullable"].(bool)
+	types := schemaTypes(input["type"])
+	if nonNull := withoutNull(types); len(nonNull) != len(types) {
+		nullable = true
+		types = nonNull
+	}
+
+	var branches []interface{}
+	if anyOf, ok := input["anyOf"].([]interface{}); ok {
+		branches = anyOf
+	} else if oneOf, ok := input["oneOf"].([]interface{}); ok {
+		branches = oneOf
+	}
+	if len(branches) > 0 {
+		var kept []map[string]interface{}
+		for _, b := range branches {
+			bm, ok := b.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if t := schemaTypes(bm["type"]); len(t) == 1 && t[0] == "null" {
+				nullable = true
+				continue
+			}
+			kept = append(kept, bm)
+		}
+
+		// Branches that only add constraints (e.g. alternative "required" sets) have no Gemini equivalent
+		if describesValues(kept) {
+			rest := withoutKeys(input, "anyOf", "oneOf")
+			if len(kept) == 1 {
+				// The parent's keywords (usually the description) win over the branch's
+				output := c.convert(mergeSchemas(kept[0], rest))
+				output.Nullable = output.Nullable || nullable
+				return output
+			}
+			output := c.convertScalar(rest, "")
+			for _, bm := range kept {
+				output.AnyOf = append(output.AnyOf, c.convert(bm))
+			}
+			output.Nullable = output.Nullable || nullable
+			return output
+		}
+	}
+
+	if len(types) > 1 {
+		// Several types without a schema per type; offer each of them
+		output := c.convertScalar(input, "")
+		for _, t := range types {
+			output.AnyOf = append(output.AnyOf, c.convertScalar(input, t))
+		}
+		output.Nullable = output.Nullable || nullable
+		return output
+	}
+
+	typ := ""
+	if len(types) == 1 {
+		typ = types[0]
+	}
+	output := c.convertScalar(input, typ)
+	output.Nullable = output.Nullable || nullable
+
+	if p, ok := input["properties"].(map[string]interface{}); ok {
+		output.Properties = make(map[string]*gemini.GeminiParameterSchema)
+		for k, v := range p {
+			if vMap, ok := v.(map[string]interface{}); ok {
+				output.Properties[k] = c.convert(vMap)
+			}
+		}
+	}
+
+	if r, ok := input["required"].([]interface{}); ok {
+		for _, v := range r {
+			s, ok := v.(string)
+			if !ok {
+				continue
+			}
+			// Gemini rejects required names that are not declared as properties
+			if output.Properties != nil && output.Properties[s] == nil {
+				continue
+			}
+			output.Required = append(output.Required, s)
+		}
+	}
+
+	switch items := input["items"].(type) {
+	case map[string]interface{}:
+		output.Items = c.convert(items)
+	case []interface{}:
+		// Tuple validation; use the first item schema
+		if len(items) > 0 {
+			if first, ok := items[0].(map[string]interface{}); ok {
+				output.Items = c.convert(first)
+			}
+		}
+	}
+
+	return output
+}
+
+// convertScalar converts the keywords of input that do not contain subschemas.
+// typ overrides the schema type; an empty typ leaves the type unset.
+func (c *schemaConverter) convertScalar(input map[string]interface{}, typ string) *gemini.GeminiParameterSchema {
+	output := &gemini.GeminiParameterSchema{Type: strings.ToUpper(typ)}
+	if t, ok := input["title"].(string); ok {
+		output.Title = t
+	}
+	if d, ok := input["description"].(string); ok {
+		output.Description = d
+	}
+	if f, ok := input["format"].(string); ok && geminiSchemaFormats[output.Type][f] {
+		output.Format = f
+	}
+	if d, ok := input["default"]; ok && d != nil {
+		output.Default = d
+	}
+
+	var values []interface{}
+	if e, ok := input["enum"].([]interface{}); ok {
+		values = e
+	} else if v, ok := input["const"]; ok {
+		values = []interface{}{v}
+	}
+	if len(values) > 0 {
+		// A null member makes the value nullable rather than a string "null"
+		nonNull := values[:0:0]
+		for _, v := range values {
+			if v == nil {
+				output.Nullable = true
+				continue
+			}
+			nonNull = append(nonNull, v)
+		}
+		values = nonNull
+	}
+	if len(values) > 0 {
+		if enum, ok := stringValues(values); ok && (output.Type == "" || output.Type == "STRING") {
+			output.Type = "STRING"
+			output.Enum = enum
+		} else {
+			// Gemini only supports string enums; describe the allowed values instead
+			hint := "Allowed values: " + joinJSON(values)
+			if output.Description != "" {
+				hint = output.Description + " (" + hint + ")"
+			}
+			output.Description = hint
+		}
+	}
+
+	output.Minimum = numberField(input, "minimum")
+	output.Maximum = numberField(input, "maximum")
+	output.MinLength = intField(input, "minLength")
+	output.MaxLength = intField(input, "maxLength")
+	output.MinItems = intField(input, "minItems")
+	output.MaxItems = intField(input, "maxItems")
+	output.MinProperties = intField(input, "minProperties")
+	output.MaxProperties = intField(input, "maxProperties")
+	if p, ok := input["pattern"].(string); ok {
+		output.Pattern = p
+	}
+
+	return output
+}
+
+// convertRef inlines a local $ref. Keywords next to the $ref (e.g. a description) override the target's.
+func (c *schemaConverter) convertRef(ref string, input map[string]interface{}) *gemini.GeminiParameterSchema {
+	siblings := withoutKeys(input, "$ref")
+
+	target := c.lookup(ref)
+	if target == nil {
+		logger.Get().Warn().Str("ref", ref).Msg("Dropping unresolvable $ref in tool schema")
+		return c.convert(siblings)
+	}
+	if c.expanding[ref] {
+		// Recursive schema; stop expanding and accept any object here
+		return c.convertScalar(mergeSchemas(withoutKeys(target, "enum", "const"), siblings), "object")
+	}
+
+	c.expanding[ref] = true
+	defer delete(c.expanding, ref)
+	return c.convert(mergeSchemas(target, siblings))
+}
+
+// convertAllOf merges all branches (inlining their $refs) and converts the result.
+func (c *schemaConverter) convertAllOf(allOf []interface{}, input map[string]interface{}) *gemini.GeminiParameterSchema {
+	merged := map[string]interface{}{}
+	for _, b := range allOf {
+		bm, ok := b.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if ref, ok := bm["$ref"].(string); ok {
+			if c.expanding[ref] {
+				continue
+			}
+			if target := c.lookup(ref); target != nil {
+				c.expanding[ref] = true
+				defer delete(c.expanding, ref)
+				bm = mergeSchemas(target, withoutKeys(bm, "$ref"))
+			}
+		}
+		merged = mergeSchemas(merged, bm)
+	}
+	return c.convert(mergeSchemas(merged, withoutKeys(input, "allOf")))
+}
+
+// lookup resolves a local JSON pointer such as "#/$defs/Item" against the root schema.
+func (c *schemaConverter) lookup(ref string) map[string]interface{} {
+	if ref == "#" {
+		return c.root
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil
+	}
+	var current interface{} = c.root
+	for _, token := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[token]
+	}
+	target, _ := current.(map[string]interface{})
+	return target
+}
+
+// mergeSchemas returns base overlaid with overlay. Properties are merged and required lists combined.
+func mergeSchemas(base, overlay map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(overlay))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range overlay {
+		switch k {
+		case "properties":
+			props := map[string]interface{}{}
+			if bp, ok := merged[k].(map[string]interface{}); ok {
+				for pk, pv := range bp {
+					props[pk] = pv
+				}
+			}
+			if op, ok := v.(map[string]interface{}); ok {
+				for pk, pv := range op {
+					props[pk] = pv
+				}
+			}
+			merged[k] = props
+		case "required":
+			var required []interface{}
+			seen := map[interface{}]bool{}
+			for _, list := range []interface{}{merged[k], v} {
+				items, _ := list.([]interface{})
+				for _, r := range items {
+					if !seen[r] {
+						seen[r] = true
+						required = append(required, r)
+					}
+				}
+			}
+			merged[k] = required
+		default:
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// describesValues reports whether anyOf/oneOf branches describe alternative values
+// rather than only adding constraints to the parent.
+func describesValues(branches []map[string]interface{}) bool {
+	if len(branches) == 0 {
+		return false
+	}
+	for _, b := range branches {
+		for _, k := range []string{"type", "$ref", "properties", "items", "enum", "const", "anyOf", "oneOf", "allOf"} {
+			if _, ok := b[k]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func schemaTypes(v interface{}) []string {
+	switch t := v.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		var types []string
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func withoutNull(types []string) []string {
+	var out []string
+	for _, t := range types {
+		if t != "null" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func withoutKeys(m map[string]interface{}, keys ...string) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	for _, k := range keys {
+		delete(out, k)
+	}
+	return out
+}
+
+func stringValues(values []interface{}) ([]string, bool) {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			return nil, false
+		}
+		out = append(out, s)
+	}
+	return out, true
+}
+
+func joinJSON(values []interface{}) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		b, err := json.Marshal(v)
+		if err != nil {
+			b = []byte(fmt.Sprint(v))
+		}
+		parts = append(parts, string(b))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func numberField(m map[string]interface{}, key string) *float64 {
+	switch v := m[key].(type) {
+	case float64:
+		return &v
+	case int:
+		f := float64(v)
+		return &f
+	}
+	return nil
+}
+
+func intField(m map[string]interface{}, key string) *int64 {
+	if f := numberField(m, key); f != nil {
+		i := int64(*f)
+		return &i
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package transform
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func int64Ptr(v int64) *int64 { return &v }
+
+func convertSchemaJSON(t *testing.T, schema string) string {
+	t.Helper()
+	var input map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(schema), &input))
+	out, err := json.Marshal(convertToGeminiSchema(input))
+	require.NoError(t, err)
+	return string(out)
+}
+
+func TestConvertToGeminiSchema_RefsAndDefs(t *testing.T) {
+	got := convertSchemaJSON(t, `{
+		"type": "object",
+		"properties": {
+			"owner": {"$ref": "#/$defs/User", "description": "Who owns it"},
+			"tags": {"type": "array", "items": {"$ref": "#/definitions/Tag"}}
+		},
+		"required": ["owner", "missing"],
+		"$defs": {
+			"User": {"type": "object", "description": "A user", "properties": {"name": {"type": "string"}}, "required": ["name"]}
+		},
+		"definitions": {
+			"Tag": {"type": "string", "minLength": 1}
+		}
+	}`)
+
+	assert.JSONEq(t, `{
+		"type": "OBJECT",
+		"properties": {
+			"owner": {"type": "OBJECT", "description": "Who owns it", "properties": {"name": {"type": "STRING"}}, "required": ["name"]},
+			"tags": {"type": "ARRAY", "items": {"type": "STRING", "minLength": 1}}
+		},
+		"required": ["owner"]
+	}`, got)
+}
+
+func TestConvertToGeminiSchema_RecursiveRef(t *testing.T) {
+	got := convertSchemaJSON(t, `{
+		"type": "object",
+		"properties": {"root": {"$ref": "#/$defs/Node"}},
+		"$defs": {
+			"Node": {
+				"type": "object",
+				"description": "A tree node",
+				"properties": {
+					"value": {"type": "string"},
+					"children": {"type": "array", "items": {"$ref": "#/$defs/Node"}}
+				}
+			}
+		}
+	}`)
+
+	assert.JSONEq(t, `{
+		"type": "OBJECT",
+		"properties": {
+			"root": {
+				"type": "OBJECT",
+				"description": "A tree node",
+				"properties": {
+					"value": {"type": "STRING"},
+					"children": {"type": "ARRAY", "items": {"type": "OBJECT", "description": "A tree node"}}
+				}
+			}
+		}
+	}`, got)
+}
+
+func TestConvertToGeminiSchema_Nullable(t *testing.T) {
+	got := convertSchemaJSON(t, `{
+		"type": "object",
+		"properties": {
+			"a": {"type": ["string", "null"], "format": "date-time"},
+			"b": {"anyOf": [{"type": "integer", "minimum": 1}, {"type": "null"}], "description": "Count"},
+			"c": {"enum": ["x", "y", null]},
+			"d": {"type": ["string", "number"]}
+		}
+	}`)
+
+	assert.JSONEq(t, `{
+		"type": "OBJECT",
+		"properties": {
+			"a": {"type": "STRING", "format": "date-time", "nullable": true},
+			"b": {"type": "INTEGER", "description": "Count", "minimum": 1, "nullable": true},
+			"c": {"type": "STRING", "enum": ["x", "y"], "nullable": true},
+			"d": {"anyOf": [{"type": "STRING"}, {"type": "NUMBER"}]}
+		}
+	}`, got)
+}
+
+func TestConvertToGeminiSchema_AllOfConstAndEnums(t *testing.T) {
+	got := convertSchemaJSON(t, `{
+		"allOf": [
+			{"$ref": "#/$defs/Base"},
+			{"properties": {"kind": {"const": "file"}, "level": {"type": "integer", "enum": [1, 2, 3], "description": "Depth"}}, "required": ["kind"]}
+		],
+		"$defs": {
+			"Base": {"type": "object", "properties": {"path": {"type": "string", "format": "uri", "pattern": "^/"}}, "required": ["path"]}
+		}
+	}`)
+
+	assert.JSONEq(t, `{
+		"type": "OBJECT",
+		"properties": {
+			"path": {"type": "STRING", "pattern": "^/"},
+			"kind": {"type": "STRING", "enum": ["file"]},
+			"level": {"type": "INTEGER", "description": "Depth (Allowed values: 1, 2, 3)"}
+		},
+		"required": ["path", "kind"]
+	}`, got)
+}
+
+func TestConvertToGeminiSchema_ConstraintOnlyAnyOf(t *testing.T) {
+	got := convertSchemaJSON(t, `{
+		"type": "object",
+		"properties": {"id": {"type": "string"}, "name": {"type": "string"}},
+		"anyOf": [{"required": ["id"]}, {"required": ["name"]}],
+		"additionalProperties": false,
+		"minProperties": 1
+	}`)
+
+	assert.JSONEq(t, `{
+		"type": "OBJECT",
+		"properties": {"id": {"type": "STRING"}, "name": {"type": "STRING"}},
+		"minProperties": 1
+	}`, got)
+}
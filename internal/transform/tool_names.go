@@ -0,0 +1,197 @@
+package transform
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/logger"
+)
+
+// maxToolNameLength is the longest function name Gemini accepts.
+const maxToolNameLength = 64
+
+// validToolName is the conservative subset of function names every Gemini model accepts.
+var validToolName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,63}$`)
+
+var invalidToolNameChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// ToolNameMap maps client tool names to Gemini-safe function names and back.
+// A nil map leaves every name unchanged.
+type ToolNameMap struct {
+	toGemini map[string]string
+	toClient map[string]string
+}
+
+// GeminiName returns the function name sent to Gemini for a client tool name.
+func (m *ToolNameMap) GeminiName(name string) string {
+	if m == nil {
+		return name
+	}
+	if alias, ok := m.toGemini[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// ClientName returns the client tool name for a function name returned by Gemini.
+func (m *ToolNameMap) ClientName(name string) string {
+	if m == nil {
+		return name
+	}
+	if original, ok := m.toClient[name]; ok {
+		return original
+	}
+	return name
+}
+
+// SanitizeToolNames rewrites function names Gemini would reject into safe aliases, everywhere
+// they appear in the request: declarations, functionCall/functionResponse parts in the history
+// and allowedFunctionNames. It returns the mapping used to restore the original names in
+// responses, or nil when all names are valid.
+func SanitizeToolNames(req *gemini.GeminiInternalRequest) *ToolNameMap {
+	if req == nil {
+		return nil
+	}
+
+	// Valid names keep their name, so collect them first to avoid handing them out as aliases
+	var names []string
+	for _, tool := range req.Tools {
+		for _, fn := range tool.FunctionDeclarations {
+			names = append(names, fn.Name)
+		}
+	}
+	for _, content := range req.Contents {
+		for _, part := range content.Parts {
+			if part.FunctionCall != nil {
+				names = append(names, part.FunctionCall.Name)
+			}
+			if part.FunctionResponse != nil {
+				names = append(names, part.FunctionResponse.Name)
+			}
+		}
+	}
+
+	m := newToolNameMap(names)
+	if m == nil {
+		return nil
+	}
+
+	for ti := range req.Tools {
+		for fi := range req.Tools[ti].FunctionDeclarations {
+			fn := &req.Tools[ti].FunctionDeclarations[fi]
+			fn.Name = m.GeminiName(fn.Name)
+		}
+	}
+	for ci := range req.Contents {
+		for pi := range req.Contents[ci].Parts {
+			part := &req.Contents[ci].Parts[pi]
+			if part.FunctionCall != nil {
+				part.FunctionCall.Name = m.GeminiName(part.FunctionCall.Name)
+			}
+			if part.FunctionResponse != nil {
+				part.FunctionResponse.Name = m.GeminiName(part.FunctionResponse.Name)
+			}
+		}
+	}
+	if req.ToolConfig != nil && req.ToolConfig.FunctionCallingConfig != nil {
+		allowed := req.ToolConfig.FunctionCallingConfig.AllowedFunctionNames
+		for i, name := range allowed {
+			allowed[i] = m.GeminiName(name)
+		}
+	}
+
+	return m
+}
+
+// RestoreResponse rewrites functionCall names in a raw Gemini response back to the client names.
+func (m *ToolNameMap) RestoreResponse(resp map[string]interface{}) {
+	if m == nil || resp == nil {
+		return
+	}
+	candidates, _ := resp["candidates"].([]interface{})
+	for _, c := range candidates {
+		cand, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var parts []interface{}
+		if content, ok := cand["content"].(map[string]interface{}); ok {
+			parts, _ = content["parts"].([]interface{})
+		}
+		if len(parts) == 0 {
+			parts, _ = cand["parts"].([]interface{})
+		}
+		for _, p := range parts {
+			part, ok := p.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if fc, ok := part["functionCall"].(map[string]interface{}); ok {
+				if name, ok := fc["name"].(string); ok {
+					fc["name"] = m.ClientName(name)
+				}
+			}
+		}
+	}
+}
+
+// newToolNameMap assigns aliases to the invalid names in names. Aliases are deterministic:
+// the name with invalid characters replaced by "_", shortened with a hash suffix when too
+// long, and numbered on collisions.
+func newToolNameMap(names []string) *ToolNameMap {
+	used := map[string]bool{}
+	var invalid []string
+	for _, name := range names {
+		if validToolName.MatchString(name) {
+			used[name] = true
+		} else if name != "" {
+			invalid = append(invalid, name)
+		}
+	}
+	if len(invalid) == 0 {
+		return nil
+	}
+
+	m := &ToolNameMap{toGemini: map[string]string{}, toClient: map[string]string{}}
+	for _, name := range invalid {
+		if _, done := m.toGemini[name]; done {
+			continue
+		}
+		base := sanitizeToolName(name)
+		alias := base
+		for i := 2; used[alias]; i++ {
+			suffix := fmt.Sprintf("_%d", i)
+			alias = truncateToolName(base, maxToolNameLength-len(suffix)) + suffix
+		}
+		used[alias] = true
+		m.toGemini[name] = alias
+		m.toClient[alias] = name
+		logger.Get().Debug().Str("name", name).Str("alias", alias).Msg("Renamed tool for Gemini")
+	}
+	return m
+}
+
+func sanitizeToolName(name string) string {
+	safe := invalidToolNameChars.ReplaceAllString(name, "_")
+	if safe == "" || (safe[0] >= '0' && safe[0] <= '9') {
+		safe = "_" + safe
+	}
+	if len(safe) <= maxToolNameLength {
+		return safe
+	}
+	// Keep names that share a long prefix apart
+	sum := sha1.Sum([]byte(name))
+	suffix := "_" + hex.EncodeToString(sum[:])[:8]
+	return truncateToolName(safe, maxToolNameLength-len(suffix)) + suffix
+}
+
+func truncateToolName(name string, n int) string {
+	if len(name) <= n {
+		return name
+	}
+	return strings.TrimRight(name[:n], "_")
+}
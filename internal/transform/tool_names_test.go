@@ -0,0 +1,121 @@
+package transform
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/gemini"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func toolNamesRequest(names ...string) *gemini.GeminiInternalRequest {
+	var decls []gemini.FunctionDeclaration
+	for _, n := range names {
+		decls = append(decls, gemini.FunctionDeclaration{Name: n})
+	}
+	return &gemini.GeminiInternalRequest{Tools: []gemini.Tool{{FunctionDeclarations: decls}}}
+}
+
+func declaredNames(req *gemini.GeminiInternalRequest) []string {
+	var names []string
+	for _, fn := range req.Tools[0].FunctionDeclarations {
+		names = append(names, fn.Name)
+	}
+	return names
+}
+
+func TestSanitizeToolNames_ValidNamesUnchanged(t *testing.T) {
+	req := toolNamesRequest("get_weather", "_private", "ReadFile2")
+	assert.Nil(t, SanitizeToolNames(req))
+	assert.Equal(t, []string{"get_weather", "_private", "ReadFile2"}, declaredNames(req))
+}
+
+func TestSanitizeToolNames_RewritesInvalidNames(t *testing.T) {
+	long := strings.Repeat("a", 70) + ".tool"
+	req := toolNamesRequest("mcp__github.create-issue", "files/read", "1st tool", long)
+
+	m := SanitizeToolNames(req)
+	require.NotNil(t, m)
+
+	names := declaredNames(req)
+	assert.Equal(t, "mcp__github_create_issue", names[0])
+	assert.Equal(t, "files_read", names[1])
+	assert.Equal(t, "_1st_tool", names[2])
+	assert.Len(t, names[3], maxToolNameLength)
+	for _, n := range names {
+		assert.Regexp(t, validToolName, n)
+	}
+
+	// Round trip back to the client names
+	assert.Equal(t, "mcp__github.create-issue", m.ClientName(names[0]))
+	assert.Equal(t, "files/read", m.ClientName(names[1]))
+	assert.Equal(t, "1st tool", m.ClientName(names[2]))
+	assert.Equal(t, long, m.ClientName(names[3]))
+	assert.Equal(t, "unknown", m.ClientName("unknown"))
+}
+
+func TestSanitizeToolNames_Collisions(t *testing.T) {
+	// "a_b" is valid and keeps its name; both invalid names would otherwise become "a_b" too
+	req := toolNamesRequest("a.b", "a_b", "a-b")
+
+	m := SanitizeToolNames(req)
+	require.NotNil(t, m)
+	assert.Equal(t, []string{"a_b_2", "a_b", "a_b_3"}, declaredNames(req))
+	assert.Equal(t, "a.b", m.ClientName("a_b_2"))
+	assert.Equal(t, "a_b", m.ClientName("a_b"))
+	assert.Equal(t, "a-b", m.ClientName("a_b_3"))
+
+	// Long names sharing a prefix stay distinct
+	prefix := strings.Repeat("x", 80)
+	req = toolNamesRequest(prefix+".one", prefix+".two")
+	SanitizeToolNames(req)
+	names := declaredNames(req)
+	assert.NotEqual(t, names[0], names[1])
+}
+
+func TestSanitizeToolNames_HistoryAndToolConfig(t *testing.T) {
+	req := toolNamesRequest("fs.read")
+	req.Contents = []gemini.Content{
+		{Role: "model", Parts: []gemini.ContentPart{{FunctionCall: &gemini.FunctionCall{Name: "fs.read"}}}},
+		{Role: "user", Parts: []gemini.ContentPart{{FunctionResponse: &gemini.FunctionResponse{Name: "fs.read"}}}},
+	}
+	req.ToolConfig = &gemini.ToolConfig{FunctionCallingConfig: &gemini.FunctionCallingConfig{
+		Mode:                 "ANY",
+		AllowedFunctionNames: []string{"fs.read"},
+	}}
+
+	m := SanitizeToolNames(req)
+	require.NotNil(t, m)
+	assert.Equal(t, "fs_read", req.Contents[0].Parts[0].FunctionCall.Name)
+	assert.Equal(t, "fs_read", req.Contents[1].Parts[0].FunctionResponse.Name)
+	assert.Equal(t, []string{"fs_read"}, req.ToolConfig.FunctionCallingConfig.AllowedFunctionNames)
+}
+
+func TestToolNameMap_RestoreResponse(t *testing.T) {
+	m := SanitizeToolNames(toolNamesRequest("fs.read"))
+	require.NotNil(t, m)
+
+	resp := &gemini.GenerateContentResponse{Response: map[string]interface{}{
+		"candidates": []interface{}{
+			map[string]interface{}{
+				"content": map[string]interface{}{
+					"parts": []interface{}{
+						map[string]interface{}{"functionCall": map[string]interface{}{"name": "fs_read", "args": map[string]interface{}{"path": "a.txt"}}},
+					},
+				},
+			},
+		},
+	}}
+	m.RestoreResponse(resp.Response)
+
+	openAIResp, err := ToOpenAIChatCompletionResponse(resp, "gemini-2.5-pro")
+	require.NoError(t, err)
+	require.Len(t, openAIResp.Choices[0].Message.ToolCalls, 1)
+	assert.Equal(t, "fs.read", openAIResp.Choices[0].Message.ToolCalls[0].Function.Name)
+
+	// A nil map is a no-op
+	var none *ToolNameMap
+	none.RestoreResponse(resp.Response)
+	assert.Equal(t, "x", none.GeminiName("x"))
+}
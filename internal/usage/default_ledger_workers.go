@@ -0,0 +1,12 @@
+//go:build js && wasm
+
+package usage
+
+// workersMaxRecords bounds the ledger of a Workers isolate.
+const workersMaxRecords = 10000
+
+// NewDefaultLedger returns the ledger for this platform. Workers have no local disk, so each
+// isolate keeps its recent records in memory.
+func NewDefaultLedger() (Ledger, error) {
+	return NewMemoryLedger(workersMaxRecords), nil
+}
@@ -0,0 +1,100 @@
+//go:build !js || !wasm
+
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/env"
+	"github.com/dvcrn/gemini-code-assist-proxy/internal/logger"
+)
+
+// FileLedger appends records as JSON lines to a file readable only by the owner.
+type FileLedger struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileLedger creates a ledger backed by the file at path. The file is created on the first append.
+func NewFileLedger(path string) *FileLedger {
+	return &FileLedger{path: path}
+}
+
+// NewDefaultLedger returns the ledger for this platform: a file at USAGE_LEDGER_PATH,
+// defaulting to ~/.gemini/proxy_usage.jsonl.
+func NewDefaultLedger() (Ledger, error) {
+	if path, ok := env.Get("USAGE_LEDGER_PATH"); ok {
+		return NewFileLedger(path), nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return NewFileLedger(filepath.Join(homeDir, ".gemini", "proxy_usage.jsonl")), nil
+}
+
+func (f *FileLedger) Append(rec Record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage record: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create directory for usage ledger: %w", err)
+	}
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open usage ledger: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to append to usage ledger: %w", err)
+	}
+	return nil
+}
+
+func (f *FileLedger) Query(filter Filter) ([]Record, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.Open(f.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage ledger: %w", err)
+	}
+	defer file.Close()
+
+	var out []Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// A torn last line (e.g. after a crash) must not hide the rest of the ledger
+			logger.Get().Warn().Err(err).Int("line", line).Str("path", f.path).Msg("Skipping invalid usage record")
+			continue
+		}
+		if filter.Match(rec) {
+			out = append(out, rec)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read usage ledger: %w", err)
+	}
+	return out, nil
+}
+
+func (f *FileLedger) Name() string {
+	return fmt.Sprintf("FileLedger(%s)", f.path)
+}
@@ -0,0 +1,61 @@
+//go:build !js || !wasm
+
+package usage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileLedger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	ledger := NewFileLedger(path)
+
+	records, err := ledger.Query(Filter{})
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	for _, rec := range testRecords() {
+		require.NoError(t, ledger.Append(rec))
+	}
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	records, err = ledger.Query(Filter{})
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, testRecords()[1], records[1])
+
+	day2 := time.Date(2025, 7, 15, 0, 0, 0, 0, time.UTC)
+	records, err = ledger.Query(Filter{From: day2})
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
+
+	records, err = ledger.Query(Filter{To: day2, Model: "gemini-2.5-pro"})
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
+}
+
+func TestFileLedgerSkipsTornLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	ledger := NewFileLedger(path)
+	require.NoError(t, ledger.Append(testRecords()[0]))
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"time": "2025-07-14T1` + "\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.NoError(t, ledger.Append(testRecords()[1]))
+
+	records, err := ledger.Query(Filter{})
+	require.NoError(t, err)
+	assert.Len(t, records, 2)
+}
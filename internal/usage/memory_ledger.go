@@ -0,0 +1,43 @@
+package usage
+
+import "sync"
+
+// MemoryLedger keeps the most recent records in memory, dropping the oldest beyond maxRecords.
+type MemoryLedger struct {
+	mu         sync.Mutex
+	records    []Record
+	maxRecords int
+}
+
+// NewMemoryLedger creates a ledger holding up to maxRecords records.
+func NewMemoryLedger(maxRecords int) *MemoryLedger {
+	return &MemoryLedger{maxRecords: maxRecords}
+}
+
+func (m *MemoryLedger) Append(rec Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.records = append(m.records, rec)
+	if m.maxRecords > 0 && len(m.records) > m.maxRecords {
+		m.records = append([]Record(nil), m.records[len(m.records)-m.maxRecords:]...)
+	}
+	return nil
+}
+
+func (m *MemoryLedger) Query(filter Filter) ([]Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var out []Record
+	for _, rec := range m.records {
+		if filter.Match(rec) {
+			out = append(out, rec)
+		}
+	}
+	return out, nil
+}
+
+func (m *MemoryLedger) Name() string {
+	return "MemoryLedger"
+}
@@ -0,0 +1,151 @@
+// Package usage records every inference request in an append-only ledger and summarizes
+// the ledger by day, model or client key.
+package usage
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Record is one request in the ledger.
+type Record struct {
+	Time           time.Time `json:"time"`
+	KeyID          string    `json:"key_id"`
+	KeyName        string    `json:"key_name,omitempty"`
+	Route          string    `json:"route"`
+	RequestedModel string    `json:"requested_model,omitempty"`
+	Model          string    `json:"model,omitempty"` // normalized model sent upstream
+	Stream         bool      `json:"stream"`
+	Status         int       `json:"status"`
+	LatencyMs      int64     `json:"latency_ms"`
+	PromptTokens   int64     `json:"prompt_tokens"`
+	OutputTokens   int64     `json:"output_tokens"`
+	ThoughtsTokens int64     `json:"thoughts_tokens"`
+	CachedTokens   int64     `json:"cached_tokens"`
+	TotalTokens    int64     `json:"total_tokens"`
+}
+
+// Filter selects records. Zero fields match everything; To is exclusive.
+type Filter struct {
+	From  time.Time
+	To    time.Time
+	KeyID string
+	Model string
+}
+
+// Match reports whether rec is selected by f.
+func (f Filter) Match(rec Record) bool {
+	if !f.From.IsZero() && rec.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !rec.Time.Before(f.To) {
+		return false
+	}
+	if f.KeyID != "" && rec.KeyID != f.KeyID {
+		return false
+	}
+	if f.Model != "" && rec.Model != f.Model && rec.RequestedModel != f.Model {
+		return false
+	}
+	return true
+}
+
+// Ledger stores usage records. Records are only ever appended.
+type Ledger interface {
+	// Append adds a record
+	Append(rec Record) error
+
+	// Query returns the records matching filter, oldest first
+	Query(filter Filter) ([]Record, error)
+
+	// Name returns the name of the ledger for logging
+	Name() string
+}
+
+// GroupBy is a dimension of the summaries.
+type GroupBy string
+
+const (
+	GroupByDay   GroupBy = "day"
+	GroupByModel GroupBy = "model"
+	GroupByKey   GroupBy = "key"
+)
+
+// ParseGroupBy parses a GroupBy, defaulting to GroupByDay for "".
+func ParseGroupBy(s string) (GroupBy, error) {
+	switch g := GroupBy(s); g {
+	case "":
+		return GroupByDay, nil
+	case GroupByDay, GroupByModel, GroupByKey:
+		return g, nil
+	default:
+		return "", fmt.Errorf("unknown group_by %q, expected day, model or key", s)
+	}
+}
+
+// Summary aggregates the records of one group.
+type Summary struct {
+	Group          string `json:"group"`
+	Name           string `json:"name,omitempty"` // key name when grouped by key
+	Requests       int64  `json:"requests"`
+	Errors         int64  `json:"errors"` // status >= 400
+	PromptTokens   int64  `json:"prompt_tokens"`
+	OutputTokens   int64  `json:"output_tokens"`
+	ThoughtsTokens int64  `json:"thoughts_tokens"`
+	CachedTokens   int64  `json:"cached_tokens"`
+	TotalTokens    int64  `json:"total_tokens"`
+	AvgLatencyMs   int64  `json:"avg_latency_ms"`
+
+	latencySum int64
+}
+
+func (s *Summary) add(rec Record) {
+	s.Requests++
+	if rec.Status >= 400 {
+		s.Errors++
+	}
+	s.PromptTokens += rec.PromptTokens
+	s.OutputTokens += rec.OutputTokens
+	s.ThoughtsTokens += rec.ThoughtsTokens
+	s.CachedTokens += rec.CachedTokens
+	s.TotalTokens += rec.TotalTokens
+	s.latencySum += rec.LatencyMs
+	s.AvgLatencyMs = s.latencySum / s.Requests
+}
+
+// Summarize aggregates records by group and returns the groups sorted by name, plus the total
+// of all records. Days are UTC.
+func Summarize(records []Record, by GroupBy) ([]Summary, Summary) {
+	groups := make(map[string]*Summary)
+	total := Summary{Group: "total"}
+	for _, rec := range records {
+		var group, name string
+		switch by {
+		case GroupByModel:
+			group = rec.Model
+		case GroupByKey:
+			group, name = rec.KeyID, rec.KeyName
+		default:
+			group = rec.Time.UTC().Format("2006-01-02")
+		}
+
+		s, ok := groups[group]
+		if !ok {
+			s = &Summary{Group: group}
+			groups[group] = s
+		}
+		if name != "" {
+			s.Name = name
+		}
+		s.add(rec)
+		total.add(rec)
+	}
+
+	summaries := make([]Summary, 0, len(groups))
+	for _, s := range groups {
+		summaries = append(summaries, *s)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Group < summaries[j].Group })
+	return summaries, total
+}
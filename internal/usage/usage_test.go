@@ -0,0 +1,69 @@
+package usage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRecords() []Record {
+	day1 := time.Date(2025, 7, 14, 10, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	return []Record{
+		{Time: day1, KeyID: "key_a", KeyName: "agent-a", Model: "gemini-2.5-pro", Status: 200, LatencyMs: 100, PromptTokens: 10, OutputTokens: 5, TotalTokens: 15},
+		{Time: day1.Add(time.Hour), KeyID: "key_b", KeyName: "agent-b", Model: "gemini-2.5-flash", Status: 200, LatencyMs: 300, PromptTokens: 20, OutputTokens: 10, ThoughtsTokens: 4, TotalTokens: 34},
+		{Time: day2, KeyID: "key_a", KeyName: "agent-a", Model: "gemini-2.5-pro", Status: 429, LatencyMs: 20},
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	records := testRecords()
+
+	byDay, total := Summarize(records, GroupByDay)
+	require.Len(t, byDay, 2)
+	assert.Equal(t, "2025-07-14", byDay[0].Group)
+	assert.Equal(t, int64(2), byDay[0].Requests)
+	assert.Equal(t, int64(49), byDay[0].TotalTokens)
+	assert.Equal(t, int64(200), byDay[0].AvgLatencyMs)
+	assert.Equal(t, int64(1), byDay[1].Errors)
+	assert.Equal(t, int64(3), total.Requests)
+	assert.Equal(t, int64(49), total.TotalTokens)
+
+	byKey, _ := Summarize(records, GroupByKey)
+	require.Len(t, byKey, 2)
+	assert.Equal(t, "key_a", byKey[0].Group)
+	assert.Equal(t, "agent-a", byKey[0].Name)
+	assert.Equal(t, int64(2), byKey[0].Requests)
+
+	byModel, _ := Summarize(records, GroupByModel)
+	require.Len(t, byModel, 2)
+	assert.Equal(t, "gemini-2.5-flash", byModel[0].Group)
+	assert.Equal(t, int64(4), byModel[0].ThoughtsTokens)
+}
+
+func TestParseGroupBy(t *testing.T) {
+	g, err := ParseGroupBy("")
+	require.NoError(t, err)
+	assert.Equal(t, GroupByDay, g)
+
+	_, err = ParseGroupBy("week")
+	assert.Error(t, err)
+}
+
+func TestMemoryLedger(t *testing.T) {
+	ledger := NewMemoryLedger(2)
+	for _, rec := range testRecords() {
+		require.NoError(t, ledger.Append(rec))
+	}
+
+	all, err := ledger.Query(Filter{})
+	require.NoError(t, err)
+	require.Len(t, all, 2, "the oldest record is dropped")
+	assert.Equal(t, "key_b", all[0].KeyID)
+
+	filtered, err := ledger.Query(Filter{KeyID: "key_a"})
+	require.NoError(t, err)
+	assert.Len(t, filtered, 1)
+}